@@ -0,0 +1,182 @@
+// Package client delivers and collects virtual files as OFTP2 initiator.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/partner"
+	"github.com/elgohr/go-oftp2/session"
+	"net"
+	"time"
+)
+
+// Config describes the local station. Received files are kept in its Store.
+type Config = session.Config
+
+// VirtualFile is a local file to be sent to the partner.
+type VirtualFile = session.VirtualFile
+
+// Session is a session with a partner, started by this station.
+type Session struct {
+	connection net.Conn
+	session    *session.Session
+}
+
+// Dial connects to the partner over plain TCP and runs the start session phase.
+// The session starts as speaker, ready to Send.
+func Dial(ctx context.Context, address string, config Config) (*Session, error) {
+	var dialer net.Dialer
+	connection, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return start(ctx, connection, config)
+}
+
+// DialTLS connects to the partner over TLS, usually on port 6619, and runs the start session phase.
+// tlsConfig sets the trusted CAs and the client certificate for mutual authentication.
+// TLS 1.2 is the lowest version, unless tlsConfig sets another one.
+func DialTLS(ctx context.Context, address string, tlsConfig *tls.Config, config Config) (*Session, error) {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+	dialer := tls.Dialer{Config: tlsConfig}
+	connection, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return start(ctx, connection, config)
+}
+
+// DialPartner connects to a partner of the registry by its name, over TLS if the partner is set up for it.
+// The session config of the partner is based on config.
+func DialPartner(ctx context.Context, registry *partner.Registry, name string, config Config) (*Session, error) {
+	p, err := registry.Named(name)
+	if err != nil {
+		return nil, err
+	} else if p.Address == "" {
+		return nil, fmt.Errorf("missing address of partner: %v", name)
+	}
+	config = registry.Config(p, config)
+	if p.TLS == nil {
+		return Dial(ctx, p.Address, config)
+	}
+	tlsConfig, err := p.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return DialTLS(ctx, p.Address, tlsConfig, config)
+}
+
+func start(ctx context.Context, connection net.Conn, config Config) (*Session, error) {
+	s := &Session{connection: connection}
+	err := s.within(ctx, func() error {
+		var err error
+		s.session, err = session.Initiate(connection, config)
+		return err
+	})
+	if err != nil {
+		_ = connection.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Send transmits a file to the partner.
+// A file the partner refuses is returned as oftp2.StartFileError or oftp2.EndFileError and doesn't end the session.
+func (s *Session) Send(ctx context.Context, file VirtualFile) error {
+	return s.within(ctx, func() error {
+		return s.session.Send(file)
+	})
+}
+
+// Receive hands over to the partner and receives its files, until the partner hands back or ends the session.
+// It returns the files that were stored completely.
+func (s *Session) Receive(ctx context.Context) ([]oftp2.StartFileCmd, error) {
+	var received []oftp2.StartFileCmd
+	err := s.within(ctx, func() error {
+		var err error
+		received, err = s.session.Receive()
+		return err
+	})
+	return received, err
+}
+
+// Receipts are the end to end responses received from the partner, as EndToEndResponseCmd or NegativeEndResponseCmd.
+func (s *Session) Receipts() []oftp2.Message {
+	return s.session.Receipts()
+}
+
+// InvalidReceipts are the end to end responses received from the partner, that failed the check of their signature.
+func (s *Session) InvalidReceipts() []session.ReceiptError {
+	return s.session.InvalidReceipts()
+}
+
+// Digest is the hash of a file sent in this session as it was transmitted, if the file asked for a signed receipt.
+// Keeping it checks a signed EERP, that arrives in a later session, by SentDigest of the config.
+func (s *Session) Digest(sfid oftp2.StartFileCmd) []byte {
+	return s.session.Digest(sfid)
+}
+
+// Remote is the SSID the partner answered with.
+func (s *Session) Remote() oftp2.StartSessionCmd {
+	return s.session.Remote()
+}
+
+// Negotiated is what both SSIDs agreed on.
+func (s *Session) Negotiated() oftp2.NegotiatedSession {
+	return s.session.Negotiated()
+}
+
+// MustChangeDirection tells whether the partner asked to become speaker after a file.
+// Receive hands over to the partner, before further files can be sent.
+func (s *Session) MustChangeDirection() bool {
+	return s.session.MustChangeDirection()
+}
+
+// Ended tells whether either side ended the session.
+func (s *Session) Ended() bool {
+	return s.session.Ended()
+}
+
+// Close ends the session with ESID, after sending pending end to end responses, and closes the connection.
+func (s *Session) Close() error {
+	err := s.session.End()
+	if closeErr := s.connection.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// within runs f, interrupting the connection once ctx is done.
+func (s *Session) within(ctx context.Context, f func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.connection.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.connection.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	err := f()
+	close(done)
+	_ = s.connection.SetDeadline(time.Time{})
+	if err == nil {
+		return nil
+	} else if ctx.Err() != nil {
+		return ctx.Err()
+	} else if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		// the connection deadline may pass before the context notices
+		return context.DeadlineExceeded
+	}
+	return err
+}
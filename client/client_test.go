@@ -0,0 +1,809 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/elgohr/go-oftp2/client"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/fileservices"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/partner"
+	"github.com/elgohr/go-oftp2/record"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/elgohr/go-oftp2/storage"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	partnerStore := newMemoryStore()
+	address, done := responder(t, session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		BufferCompression:      true,
+		Store:                  partnerStore,
+	}, virtualFile(t, "FROM_PARTNER", "PARTNER", "CLIENT", "FIRST\nSECOND\n"))
+
+	localStore := newMemoryStore()
+	ctx := context.Background()
+	s, err := client.Dial(ctx, address, client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 1024,
+		Credit:                 10,
+		BufferCompression:      true,
+		Store:                  localStore,
+		Authenticate: func(ssid oftp2.StartSessionCmd) error {
+			if !bytes.Equal(ssid.IdentificationCode(), testkeys.IdentificationCode(t, "PARTNER")) {
+				return oftp2.NewEndSessionError(oftp2.EndSessionUserCodeNotKnown, errors.New("unexpected partner"))
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	content := strings.Repeat("A RECORD WITH    SPACES\n", 20)
+	require.NoError(t, s.Send(ctx, virtualFile(t, "FROM_CLIENT", "CLIENT", "PARTNER", content)))
+	// the partner has a file to send, so that it asks to become speaker
+	require.True(t, s.MustChangeDirection())
+	require.EqualError(t, s.Send(ctx, virtualFile(t, "REFUSED", "CLIENT", "PARTNER", content)), "partner asked to change direction")
+
+	received, err := s.Receive(ctx)
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	require.Equal(t, "FROM_PARTNER", received[0].Name())
+	require.Equal(t, "FIRST\nSECOND\n", localStore.content("FROM_PARTNER"))
+
+	require.Len(t, s.Receipts(), 1)
+	eerp := s.Receipts()[0].(oftp2.EndToEndResponseCmd)
+	require.Equal(t, "FROM_CLIENT", eerp.Name())
+
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+	require.Equal(t, content, partnerStore.content("FROM_CLIENT"))
+}
+
+func TestSession_RefusedFile(t *testing.T) {
+	address, done := responder(t, session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+	})
+	ctx := context.Background()
+	s, err := client.Dial(ctx, address, client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+	})
+	require.NoError(t, err)
+
+	err = s.Send(ctx, virtualFile(t, "REFUSED", "CLIENT", "PARTNER", "CONTENT\n"))
+	require.EqualError(t, err, "file refused by partner with reason 14: files aren't accepted")
+	var startFileErr oftp2.StartFileError
+	require.True(t, errors.As(err, &startFileErr))
+	require.Equal(t, oftp2.AnswerFileDirectionRefused, startFileErr.Reason)
+	require.False(t, s.Ended())
+
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+}
+
+func TestSession_RefusedQueuedFile(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	accepted := make(chan *session.Session, 1)
+	done := make(chan error, 1)
+	go func() {
+		defer listener.Close()
+		connection, err := listener.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer connection.Close()
+		s, err := session.Accept(connection, session.Config{
+			IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+			Password:               "PARTNER",
+			DataExchangeBufferSize: 128,
+			Credit:                 1,
+		})
+		if err != nil {
+			done <- err
+			return
+		}
+		s.Queue(virtualFile(t, "REFUSED", "PARTNER", "CLIENT", "CONTENT\n"))
+		done <- s.Run()
+		accepted <- s
+	}()
+	ctx := context.Background()
+	s, err := client.Dial(ctx, listener.Addr().String(), client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+	})
+	require.NoError(t, err)
+	received, err := s.Receive(ctx)
+	require.NoError(t, err)
+	require.Empty(t, received)
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+
+	refusals := (<-accepted).Refusals()
+	require.Len(t, refusals, 1)
+	require.Equal(t, "REFUSED", refusals[0].StartFile.Name)
+	require.EqualError(t, refusals[0], "REFUSED refused: file refused by partner with reason 14: files aren't accepted")
+	var startFileErr oftp2.StartFileError
+	require.True(t, errors.As(refusals[0], &startFileErr))
+	require.Equal(t, oftp2.AnswerFileDirectionRefused, startFileErr.Reason)
+}
+
+func TestSession_Refused(t *testing.T) {
+	address, done := responder(t, session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Authenticate: func(ssid oftp2.StartSessionCmd) error {
+			return oftp2.NewEndSessionError(oftp2.EndSessionInvalidPassword, errors.New("invalid password"))
+		},
+	})
+	s, err := client.Dial(context.Background(), address, client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+	})
+	require.EqualError(t, err, "session ended by partner with reason 4: invalid password")
+	require.Nil(t, s)
+	require.EqualError(t, <-done, "invalid password")
+}
+
+func TestSession_Canceled(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		// accepts, but never sends SSRM
+		connection, err := listener.Accept()
+		if err == nil {
+			defer connection.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s, err := client.Dial(ctx, listener.Addr().String(), client.Config{})
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Nil(t, s)
+}
+
+func TestSession_Restart(t *testing.T) {
+	partnerStore := newMemoryStore()
+	partner := session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Restart:                true,
+		Store:                  partnerStore,
+	}
+	local := client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Restart:                true,
+	}
+	content := strings.Repeat("A RECORD\n", 100)
+	ctx := context.Background()
+
+	address, done := responder(t, partner)
+	s, err := client.Dial(ctx, address, local)
+	require.NoError(t, err)
+	interrupted := virtualFile(t, "RESTARTED", "CLIENT", "PARTNER", "")
+	interrupted.Content = io.MultiReader(strings.NewReader(content[:450]), iotest.ErrReader(errors.New("disk failure")))
+	err = s.Send(ctx, interrupted)
+	var interruptedErr session.InterruptedError
+	require.True(t, errors.As(err, &interruptedErr))
+	require.Equal(t, int64(50), interruptedErr.Position)
+	require.NoError(t, s.Close())
+	require.EqualError(t, <-done, "session ended by partner with reason 8: disk failure")
+
+	address, done = responder(t, partner)
+	s, err = client.Dial(ctx, address, local)
+	require.NoError(t, err)
+	restarted := virtualFile(t, "RESTARTED", "CLIENT", "PARTNER", content)
+	restarted.StartFile.RestartPosition = interruptedErr.Position
+	require.NoError(t, s.Send(ctx, restarted))
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+	require.Equal(t, content, partnerStore.content("RESTARTED"))
+	require.Greater(t, partnerStore.resumedAt("RESTARTED"), int64(0))
+}
+
+func TestSession_RestartAfterCrash(t *testing.T) {
+	directory := t.TempDir()
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+	partner := session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Restart:                true,
+		Store:                  crashingStore{Storage: store},
+	}
+	local := client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Restart:                true,
+	}
+	content := strings.Repeat("A RECORD\n", 100)
+	ctx := context.Background()
+
+	address, done := responder(t, partner)
+	s, err := client.Dial(ctx, address, local)
+	require.NoError(t, err)
+	interrupted := virtualFile(t, "CRASHED", "CLIENT", "PARTNER", "")
+	interrupted.Content = io.MultiReader(strings.NewReader(content[:450]), iotest.ErrReader(errors.New("disk failure")))
+	err = s.Send(ctx, interrupted)
+	var interruptedErr session.InterruptedError
+	require.True(t, errors.As(err, &interruptedErr))
+	require.NoError(t, s.Close())
+	require.Error(t, <-done)
+
+	// the partner restarts without the files of the crashed process being suspended
+	restarted := virtualFile(t, "CRASHED", "CLIENT", "PARTNER", content)
+	restarted.StartFile.RestartPosition = interruptedErr.Position
+	sfid, err := oftp2.NewStartFile(restarted.StartFile)
+	require.NoError(t, err)
+	id := storage.IdentityOf(oftp2.StartFileCmd(sfid))
+	store, err = storage.NewFilesystem(directory)
+	require.NoError(t, err)
+	file, checkpoint, err := store.Resume(id)
+	require.NoError(t, err)
+	require.Greater(t, checkpoint.Position, int64(0))
+	require.LessOrEqual(t, checkpoint.Position, interruptedErr.Position)
+	require.NoError(t, file.Suspend(checkpoint))
+
+	partner.Store = store
+	address, done = responder(t, partner)
+	s, err = client.Dial(ctx, address, local)
+	require.NoError(t, err)
+	require.NoError(t, s.Send(ctx, restarted))
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+	r, err := store.Open(id)
+	require.NoError(t, err)
+	defer r.Close()
+	received, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, content, string(received))
+}
+
+func TestSession_TLS(t *testing.T) {
+	certificate, pool := selfSigned(t)
+	partnerStore := newMemoryStore()
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address, done := serve(t, tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{certificate}}), session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Store:                  partnerStore,
+	})
+
+	ctx := context.Background()
+	s, err := client.DialTLS(ctx, address, &tls.Config{RootCAs: pool}, client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.Send(ctx, virtualFile(t, "ENCRYPTED", "CLIENT", "PARTNER", "CONTENT\n")))
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+	require.Equal(t, "CONTENT\n", partnerStore.content("ENCRYPTED"))
+}
+
+func TestSession_UntrustedTLS(t *testing.T) {
+	certificate, _ := selfSigned(t)
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address, _ := serve(t, tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{certificate}}), session.Config{})
+
+	s, err := client.DialTLS(context.Background(), address, nil, client.Config{})
+	var unknownAuthority x509.UnknownAuthorityError
+	require.True(t, errors.As(err, &unknownAuthority))
+	require.Nil(t, s)
+}
+
+func TestSession_FileServices(t *testing.T) {
+	clientKey, clientCertificate := testkeys.RSA(t, "CLIENT")
+	partnerKey, partnerCertificate := testkeys.RSA(t, "PARTNER")
+	partnerStore := newMemoryStore()
+	address, done := responder(t, session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Store:                  partnerStore,
+		KeyStore: cms.StaticKeyStore{
+			PrivateKey:     partnerKey,
+			OwnCertificate: partnerCertificate,
+			Partners:       map[string]*x509.Certificate{"O0177CLIENT": clientCertificate},
+		},
+	})
+
+	ctx := context.Background()
+	s, err := client.Dial(ctx, address, client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		KeyStore: cms.StaticKeyStore{
+			PrivateKey:     clientKey,
+			OwnCertificate: clientCertificate,
+			Partners:       map[string]*x509.Certificate{"O0177PARTNER": partnerCertificate},
+		},
+	})
+	require.NoError(t, err)
+	content := strings.Repeat("A SIGNED AND ENCRYPTED LINE\n", 20)
+	file := virtualFile(t, "SECURED", "CLIENT", "PARTNER", content)
+	file.StartFile.Format = oftp2.FileFormatText
+	file.StartFile.MaxRecordSize = 0
+	file.StartFile.Security = oftp2.SecurityEncryptedAndSigned
+	file.StartFile.Cipher = oftp2.CipherAes256Cbc
+	file.StartFile.Envelope = oftp2.EnvelopeCms
+	require.NoError(t, s.Send(ctx, file))
+	compressed := virtualFile(t, "COMPRESSED", "CLIENT", "PARTNER", content)
+	compressed.StartFile.Format = oftp2.FileFormatText
+	compressed.StartFile.MaxRecordSize = 0
+	compressed.StartFile.Security = oftp2.SecurityEncryptedAndSigned
+	compressed.StartFile.Cipher = oftp2.CipherAes256Cbc
+	compressed.StartFile.Envelope = oftp2.EnvelopeCms
+	compressed.StartFile.Compression = oftp2.CompressionZlib
+	require.NoError(t, s.Send(ctx, compressed))
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+	require.Equal(t, content, partnerStore.content("SECURED"))
+	require.Equal(t, content, partnerStore.content("COMPRESSED"))
+}
+
+func TestSession_SignedReceipt(t *testing.T) {
+	clientKey, clientCertificate := testkeys.RSA(t, "CLIENT")
+	partnerKey, partnerCertificate := testkeys.RSA(t, "PARTNER")
+	_, otherCertificate := testkeys.RSA(t, "PARTNER")
+	for _, scenario := range []struct {
+		with    string
+		partner *x509.Certificate
+		valid   bool
+	}{
+		{with: "the partner certificate", partner: partnerCertificate, valid: true},
+		{with: "another certificate", partner: otherCertificate, valid: false},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			address, done := responder(t, session.Config{
+				IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+				Password:               "PARTNER",
+				DataExchangeBufferSize: 128,
+				Credit:                 1,
+				Store:                  newMemoryStore(),
+				KeyStore: cms.StaticKeyStore{
+					PrivateKey:     partnerKey,
+					OwnCertificate: partnerCertificate,
+					Partners:       map[string]*x509.Certificate{"O0177CLIENT": clientCertificate},
+				},
+			})
+			ctx := context.Background()
+			s, err := client.Dial(ctx, address, client.Config{
+				IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+				Password:               "CLIENT",
+				DataExchangeBufferSize: 128,
+				Credit:                 1,
+				KeyStore: cms.StaticKeyStore{
+					PrivateKey:     clientKey,
+					OwnCertificate: clientCertificate,
+					Partners:       map[string]*x509.Certificate{"O0177PARTNER": scenario.partner},
+				},
+			})
+			require.NoError(t, err)
+			file := virtualFile(t, "RECEIPT", "CLIENT", "PARTNER", "FIRST\nSECOND\n")
+			file.StartFile.Cipher = oftp2.CipherAes256Cbc
+			file.StartFile.SignedReceipt = true
+			require.NoError(t, s.Send(ctx, file))
+			_, err = s.Receive(ctx)
+			require.NoError(t, err)
+			require.NoError(t, s.Close())
+			require.NoError(t, <-done)
+
+			if !scenario.valid {
+				require.Empty(t, s.Receipts())
+				require.Len(t, s.InvalidReceipts(), 1)
+				require.Contains(t, s.InvalidReceipts()[0].Error(), "invalid receipt for RECEIPT")
+				return
+			}
+			require.Empty(t, s.InvalidReceipts())
+			require.Len(t, s.Receipts(), 1)
+			eerp := s.Receipts()[0].(oftp2.EndToEndResponseCmd)
+			hash := sha1.Sum([]byte("FIRSTSECOND"))
+			require.Equal(t, hash[:], eerp.Hash())
+			sfid, err := oftp2.NewStartFile(file.StartFile)
+			require.NoError(t, err)
+			require.Equal(t, hash[:], s.Digest(oftp2.StartFileCmd(sfid)))
+			require.NotEmpty(t, eerp.Signature())
+		})
+	}
+}
+
+func TestSession_SignedReceiptWithFileServices(t *testing.T) {
+	clientKey, clientCertificate := testkeys.RSA(t, "CLIENT")
+	partnerKey, partnerCertificate := testkeys.RSA(t, "PARTNER")
+	partnerStore := newMemoryStore()
+	address, done := responder(t, session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Store:                  partnerStore,
+		KeyStore: cms.StaticKeyStore{
+			PrivateKey:     partnerKey,
+			OwnCertificate: partnerCertificate,
+			Partners:       map[string]*x509.Certificate{"O0177CLIENT": clientCertificate},
+		},
+	})
+	ctx := context.Background()
+	s, err := client.Dial(ctx, address, client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		KeyStore: cms.StaticKeyStore{
+			PrivateKey:     clientKey,
+			OwnCertificate: clientCertificate,
+			Partners:       map[string]*x509.Certificate{"O0177PARTNER": partnerCertificate},
+		},
+	})
+	require.NoError(t, err)
+	content := strings.Repeat("A SIGNED AND ENCRYPTED LINE\n", 20)
+	secured := virtualFile(t, "SECURED", "CLIENT", "PARTNER", content)
+	secured.StartFile.Format = oftp2.FileFormatText
+	secured.StartFile.MaxRecordSize = 0
+	secured.StartFile.Security = oftp2.SecurityEncryptedAndSigned
+	secured.StartFile.Cipher = oftp2.CipherAes256Cbc
+	secured.StartFile.Envelope = oftp2.EnvelopeCms
+	secured.StartFile.SignedReceipt = true
+	require.NoError(t, s.Send(ctx, secured))
+	compressed := virtualFile(t, "COMPRESSED", "CLIENT", "PARTNER", content)
+	compressed.StartFile.Format = oftp2.FileFormatUnstructured
+	compressed.StartFile.MaxRecordSize = 0
+	compressed.StartFile.Cipher = oftp2.CipherAes256Cbc
+	compressed.StartFile.Compression = oftp2.CompressionZlib
+	compressed.StartFile.SignedReceipt = true
+	require.NoError(t, s.Send(ctx, compressed))
+	_, err = s.Receive(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+
+	require.Equal(t, content, partnerStore.content("SECURED"))
+	require.Equal(t, content, partnerStore.content("COMPRESSED"))
+	require.Empty(t, s.InvalidReceipts())
+	require.Len(t, s.Receipts(), 2)
+	// the hash covers the file as it was transmitted, not its original content
+	original := sha1.Sum([]byte(content))
+	var transmitted bytes.Buffer
+	require.NoError(t, fileservices.Compress(&transmitted, strings.NewReader(content)))
+	hash := sha1.Sum(transmitted.Bytes())
+	for _, receipt := range s.Receipts() {
+		eerp := receipt.(oftp2.EndToEndResponseCmd)
+		require.NotEqual(t, original[:], eerp.Hash())
+		require.NotEmpty(t, eerp.Signature())
+		if eerp.Name() == "COMPRESSED" {
+			require.Equal(t, hash[:], eerp.Hash())
+		}
+	}
+}
+
+func TestDialPartner(t *testing.T) {
+	partnerStore := newMemoryStore()
+	responderRegistry, err := partner.Parse(strings.NewReader(`{
+  "identificationCode": "O0177PARTNER",
+  "password": "PARTNER",
+  "partners": [{"identificationCode": "O0177CLIENT", "password": "CLIENT", "bufferSize": 256, "credit": 2, "direction": "receive"}]
+}`), "")
+	require.NoError(t, err)
+	base := session.Config{DataExchangeBufferSize: 4096, Credit: 64, Store: partnerStore}
+	base.Profile = responderRegistry.Profile(base)
+	address, done := responder(t, base)
+
+	registry, err := partner.Parse(strings.NewReader(`{
+  "identificationCode": "O0177CLIENT",
+  "password": "CLIENT",
+  "partners": [{"name": "partner", "identificationCode": "O0177PARTNER", "password": "PARTNER", "address": "`+address+`", "bufferSize": 1024, "credit": 10}]
+}`), "")
+	require.NoError(t, err)
+	ctx := context.Background()
+	s, err := client.DialPartner(ctx, registry, "partner", client.Config{})
+	require.NoError(t, err)
+	require.Equal(t, 256, s.Remote().DataExchangeBufferSize())
+	require.Equal(t, oftp2.CapabilityReceive, s.Remote().Capabilities())
+	require.Equal(t, oftp2.NegotiatedSession{DataExchangeBufferSize: 256, Credit: 2, Send: true}, s.Negotiated())
+	require.NoError(t, s.Send(ctx, virtualFile(t, "FROM_CLIENT", "CLIENT", "PARTNER", "CONTENT\n")))
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+	require.Equal(t, "CONTENT\n", partnerStore.content("FROM_CLIENT"))
+
+	_, err = client.DialPartner(ctx, registry, "unknown", client.Config{})
+	require.EqualError(t, err, "unknown partner: unknown")
+}
+
+func TestSession_IncompatibleCapabilities(t *testing.T) {
+	address, done := responder(t, session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Capabilities:           oftp2.CapabilityReceive,
+	})
+	_, err := client.Dial(context.Background(), address, client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Capabilities:           oftp2.CapabilityReceive,
+	})
+	require.Error(t, err)
+	var endSessionErr oftp2.EndSessionError
+	require.True(t, errors.As(<-done, &endSessionErr))
+	require.Equal(t, oftp2.EndSessionModeOrCapabilitiesIncompatible, endSessionErr.Reason)
+}
+
+func TestSession_CompressedFile(t *testing.T) {
+	partnerStore := newMemoryStore()
+	address, done := responder(t, session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Store:                  partnerStore,
+	})
+	ctx := context.Background()
+	s, err := client.Dial(ctx, address, client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+	})
+	require.NoError(t, err)
+	content := strings.Repeat("A COMPRESSED LINE\n", 1000)
+	file := virtualFile(t, "COMPRESSED", "CLIENT", "PARTNER", content)
+	file.StartFile.Format = oftp2.FileFormatText
+	file.StartFile.MaxRecordSize = 0
+	file.StartFile.Compression = oftp2.CompressionZlib
+	require.NoError(t, s.Send(ctx, file))
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+	require.Equal(t, content, partnerStore.content("COMPRESSED"))
+}
+
+func TestSession_RefusedEncryptedFile(t *testing.T) {
+	_, partnerCertificate := testkeys.RSA(t, "PARTNER")
+	address, done := responder(t, session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Store:                  newMemoryStore(),
+	})
+	ctx := context.Background()
+	s, err := client.Dial(ctx, address, client.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+		Password:               "CLIENT",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		KeyStore:               cms.StaticKeyStore{Partners: map[string]*x509.Certificate{"O0177PARTNER": partnerCertificate}},
+	})
+	require.NoError(t, err)
+	file := virtualFile(t, "ENCRYPTED", "CLIENT", "PARTNER", "CONTENT")
+	file.StartFile.Format = oftp2.FileFormatUnstructured
+	file.StartFile.MaxRecordSize = 0
+	file.StartFile.Security = oftp2.SecurityEncrypted
+	file.StartFile.Cipher = oftp2.Cipher3DesEdeCbc3Key
+	file.StartFile.Envelope = oftp2.EnvelopeCms
+	err = s.Send(ctx, file)
+	var startFileErr oftp2.StartFileError
+	require.True(t, errors.As(err, &startFileErr))
+	require.Equal(t, oftp2.AnswerEncryptedFileNotAllowed, startFileErr.Reason)
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+}
+
+// responder accepts a single session, which sends the queued files.
+func responder(t *testing.T, config session.Config, files ...session.VirtualFile) (string, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	return serve(t, listener, config, files...)
+}
+
+// serve accepts a single session on listener, which sends the queued files.
+func serve(t *testing.T, listener net.Listener, config session.Config, files ...session.VirtualFile) (string, <-chan error) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		defer listener.Close()
+		connection, err := listener.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer connection.Close()
+		s, err := session.Accept(connection, config)
+		if err != nil {
+			done <- err
+			return
+		}
+		s.Queue(files...)
+		done <- s.Run()
+	}()
+	return listener.Addr().String(), done
+}
+
+// selfSigned is a certificate for localhost and the pool trusting it.
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func virtualFile(t *testing.T, name, origin, destination, content string) session.VirtualFile {
+	t.Helper()
+	stamp, err := oftp2.NewTimeStamp([]byte("20200102030405060708"))
+	require.NoError(t, err)
+	originSid, err := oftp2.NewSid(oftp2.SidInput{CodeDesignator: "0177", OrganisationCode: origin})
+	require.NoError(t, err)
+	destinationSid, err := oftp2.NewSid(oftp2.SidInput{CodeDesignator: "0177", OrganisationCode: destination})
+	require.NoError(t, err)
+	return session.VirtualFile{
+		StartFile: oftp2.StartFileInput{
+			Name:          name,
+			Date:          stamp,
+			Destination:   destinationSid,
+			Origin:        originSid,
+			Format:        oftp2.FileFormatVariable,
+			MaxRecordSize: 80,
+			Security:      oftp2.SecurityNoServices,
+			Cipher:        oftp2.NoCipher,
+			Compression:   oftp2.NoCompression,
+			Envelope:      oftp2.NoEnvelope,
+		},
+		LocalForm: record.LocalFormLF,
+		Content:   strings.NewReader(content),
+	}
+}
+
+// crashingStore keeps files as they are, when they are interrupted, like a process that crashed.
+type crashingStore struct {
+	storage.Storage
+}
+
+func (c crashingStore) Create(id storage.Identity) (storage.Writer, error) {
+	file, err := c.Storage.Create(id)
+	if err != nil {
+		return nil, err
+	}
+	return crashingWriter{Writer: file}, nil
+}
+
+func (c crashingStore) Resume(id storage.Identity) (storage.Writer, record.Checkpoint, error) {
+	file, checkpoint, err := c.Storage.Resume(id)
+	if err != nil {
+		return nil, checkpoint, err
+	}
+	return crashingWriter{Writer: file}, checkpoint, nil
+}
+
+type crashingWriter struct {
+	storage.Writer
+}
+
+func (crashingWriter) Suspend(record.Checkpoint) error {
+	return nil
+}
+
+func (crashingWriter) Abort() error {
+	return nil
+}
+
+// memoryStore keeps received files in memory and records their identities and where they were resumed.
+type memoryStore struct {
+	*storage.Memory
+	mutex      sync.Mutex
+	identities map[string]storage.Identity
+	resumed    map[string]int64
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{Memory: storage.NewMemory(), identities: map[string]storage.Identity{}, resumed: map[string]int64{}}
+}
+
+func (m *memoryStore) Create(id storage.Identity) (storage.Writer, error) {
+	m.mutex.Lock()
+	m.identities[id.Name] = id
+	m.mutex.Unlock()
+	return m.Memory.Create(id)
+}
+
+func (m *memoryStore) Resume(id storage.Identity) (storage.Writer, record.Checkpoint, error) {
+	file, checkpoint, err := m.Memory.Resume(id)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.identities[id.Name] = id
+	m.resumed[id.Name] = checkpoint.Position
+	return file, checkpoint, err
+}
+
+func (m *memoryStore) resumedAt(name string) int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.resumed[name]
+}
+
+func (m *memoryStore) content(name string) string {
+	m.mutex.Lock()
+	id := m.identities[name]
+	m.mutex.Unlock()
+	r, err := m.Open(id)
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+	content, _ := io.ReadAll(r)
+	return string(content)
+}
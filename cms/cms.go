@@ -0,0 +1,145 @@
+// Package cms encodes the Cryptographic Message Syntax used by OFTP2 for secure authentication and file services.
+//
+// https://datatracker.ietf.org/doc/html/rfc5652
+package cms
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/asn1"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidDESEDE3CBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA1          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA512        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// Symmetric is a content encryption algorithm.
+type Symmetric struct {
+	OID       asn1.ObjectIdentifier
+	KeySize   int
+	NewCipher func(key []byte) (cipher.Block, error)
+}
+
+// Hash is a digest algorithm.
+type Hash struct {
+	OID  asn1.ObjectIdentifier
+	Hash crypto.Hash
+}
+
+// SymmetricCiphers are the supported content encryption algorithms by their name in oftp2.KnownCiphers.
+var SymmetricCiphers = map[string]Symmetric{
+	"3DES_EDE_CBC_3KEY": {OID: oidDESEDE3CBC, KeySize: 24, NewCipher: des.NewTripleDESCipher},
+	"AES_256_CBC":       {OID: oidAES256CBC, KeySize: 32, NewCipher: aes.NewCipher},
+}
+
+// Hashes are the supported digest algorithms by their name in oftp2.KnownCiphers.
+var Hashes = map[string]Hash{
+	"SHA-1":   {OID: oidSHA1, Hash: crypto.SHA1},
+	"SHA-256": {OID: oidSHA256, Hash: crypto.SHA256},
+	"SHA-512": {OID: oidSHA512, Hash: crypto.SHA512},
+}
+
+// rsaPKCS1v15 is the only supported asymmetric algorithm.
+const rsaPKCS1v15 = "RSA_PKCS1_15"
+
+// Suite are the algorithms of an OFTP2 cipher suite.
+type Suite struct {
+	Symmetric Symmetric
+	Hash      Hash
+}
+
+// SuiteOf resolves the algorithms of a cipher suite of oftp2.KnownCiphers.
+func SuiteOf(c oftp2.Cipher) (Suite, error) {
+	mapping, ok := oftp2.KnownCiphers[c]
+	if !ok || c == oftp2.NoCipher {
+		return Suite{}, fmt.Errorf("unsupported cipher suite: %d", c)
+	}
+	symmetric, ok := SymmetricCiphers[mapping.Symmetric]
+	if !ok {
+		return Suite{}, fmt.Errorf("unsupported symmetric cipher: %v", mapping.Symmetric)
+	}
+	hash, ok := Hashes[mapping.Hashing]
+	if !ok || !hash.Hash.Available() {
+		return Suite{}, fmt.Errorf("unsupported hashing: %v", mapping.Hashing)
+	}
+	if mapping.Asymmetric != rsaPKCS1v15 {
+		return Suite{}, fmt.Errorf("unsupported asymmetric cipher: %v", mapping.Asymmetric)
+	}
+	return Suite{Symmetric: symmetric, Hash: hash}, nil
+}
+
+func symmetricByOID(oid asn1.ObjectIdentifier) (Symmetric, error) {
+	for _, symmetric := range SymmetricCiphers {
+		if symmetric.OID.Equal(oid) {
+			return symmetric, nil
+		}
+	}
+	return Symmetric{}, fmt.Errorf("unsupported content encryption: %v", oid)
+}
+
+func hashByOID(oid asn1.ObjectIdentifier) (Hash, error) {
+	for _, hash := range Hashes {
+		if hash.OID.Equal(oid) && hash.Hash.Available() {
+			return hash, nil
+		}
+	}
+	return Hash{}, fmt.Errorf("unsupported digest: %v", oid)
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber asn1.RawValue
+}
+
+// wrap puts content of a type into ContentInfo.
+func wrap(contentType asn1.ObjectIdentifier, content interface{}) ([]byte, error) {
+	inner, err := asn1.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: contentType,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: inner, IsCompound: true},
+	})
+}
+
+// unwrap reads ContentInfo of the expected type.
+func unwrap(contentType asn1.ObjectIdentifier, der []byte, content interface{}) error {
+	var info contentInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil {
+		return fmt.Errorf("invalid content info: %w", err)
+	} else if len(rest) > 0 {
+		return fmt.Errorf("invalid content info: %d trailing octets", len(rest))
+	} else if !info.ContentType.Equal(contentType) {
+		return fmt.Errorf("unexpected content type: %v", info.ContentType)
+	}
+	if _, err := asn1.Unmarshal(info.Content.Bytes, content); err != nil {
+		return fmt.Errorf("invalid content: %w", err)
+	}
+	return nil
+}
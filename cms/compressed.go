@@ -0,0 +1,62 @@
+package cms
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/asn1"
+	"fmt"
+	"io"
+)
+
+var (
+	oidCompressedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 9}
+	oidZlib           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 8}
+)
+
+type compressedData struct {
+	Version              int
+	CompressionAlgorithm algorithmIdentifier
+	ContentInfo          encapsulatedContentInfo
+}
+
+// Compress wraps content into CMS CompressedData with zlib.
+//
+// https://datatracker.ietf.org/doc/html/rfc3274
+func Compress(content []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	z := zlib.NewWriter(&compressed)
+	if _, err := z.Write(content); err != nil {
+		return nil, err
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return wrap(oidCompressedData, compressedData{
+		Version:              0,
+		CompressionAlgorithm: algorithmIdentifier{Algorithm: oidZlib},
+		ContentInfo: encapsulatedContentInfo{
+			ContentType: oidData,
+			Content:     compressed.Bytes(),
+		},
+	})
+}
+
+// Decompress returns the content of CMS CompressedData.
+func Decompress(compressed []byte) ([]byte, error) {
+	var data compressedData
+	if err := unwrap(oidCompressedData, compressed, &data); err != nil {
+		return nil, err
+	}
+	if !data.CompressionAlgorithm.Algorithm.Equal(oidZlib) {
+		return nil, fmt.Errorf("unsupported compression: %v", data.CompressionAlgorithm.Algorithm)
+	}
+	z, err := zlib.NewReader(bytes.NewReader(data.ContentInfo.Content))
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(z)
+	if err != nil {
+		return nil, err
+	}
+	return content, z.Close()
+}
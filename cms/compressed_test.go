@@ -0,0 +1,21 @@
+package cms_test
+
+import (
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	content := strings.Repeat("COMPRESSIBLE CONTENT ", 100)
+	compressed, err := cms.Compress([]byte(content))
+	require.NoError(t, err)
+	require.Less(t, len(compressed), len(content))
+	decompressed, err := cms.Decompress(compressed)
+	require.NoError(t, err)
+	require.Equal(t, content, string(decompressed))
+
+	_, err = cms.Decompress([]byte(content))
+	require.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package cms
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"io"
+)
+
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []keyTransRecipientInfo `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type keyTransRecipientInfo struct {
+	Version                int
+	Recipient              issuerAndSerialNumber
+	KeyEncryptionAlgorithm algorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm algorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+// Encrypt envelopes content for the certificate of the recipient, using the symmetric cipher of the suite.
+// The content encryption key is transported with RSA PKCS #1 v1.5.
+func Encrypt(content []byte, recipient *x509.Certificate, c oftp2.Cipher) ([]byte, error) {
+	suite, err := SuiteOf(c)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := recipient.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key: %T", recipient.PublicKey)
+	}
+	key := make([]byte, suite.Symmetric.KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	block, err := suite.Symmetric.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, block.BlockSize())
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+	encrypted := pad(content, block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+	encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	parameters, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	return wrap(oidEnvelopedData, envelopedData{
+		Version: 0,
+		RecipientInfos: []keyTransRecipientInfo{{
+			Version:                0,
+			Recipient:              recipientOf(recipient),
+			KeyEncryptionAlgorithm: algorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			EncryptedKey:           encryptedKey,
+		}},
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: algorithmIdentifier{Algorithm: suite.Symmetric.OID, Parameters: asn1.RawValue{FullBytes: parameters}},
+			EncryptedContent:           encrypted,
+		},
+	})
+}
+
+// Decrypt opens content that was enveloped for the certificate with its private key.
+func Decrypt(enveloped []byte, key *rsa.PrivateKey, certificate *x509.Certificate) ([]byte, error) {
+	var data envelopedData
+	if err := unwrap(oidEnvelopedData, enveloped, &data); err != nil {
+		return nil, err
+	}
+	recipient, err := data.recipient(certificate)
+	if err != nil {
+		return nil, err
+	}
+	contentKey, err := rsa.DecryptPKCS1v15(rand.Reader, key, recipient.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt the content encryption key: %w", err)
+	}
+	algorithm := data.EncryptedContentInfo.ContentEncryptionAlgorithm
+	symmetric, err := symmetricByOID(algorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	block, err := symmetric.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &iv); err != nil || len(iv) != block.BlockSize() {
+		return nil, errors.New("invalid initialization vector")
+	}
+	content := data.EncryptedContentInfo.EncryptedContent
+	if len(content) == 0 || len(content)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("invalid encrypted content length: %d", len(content))
+	}
+	decrypted := make([]byte, len(content))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, content)
+	return unpad(decrypted, block.BlockSize())
+}
+
+// recipient finds the key of the certificate. A single recipient is used, even if it names another certificate.
+func (e envelopedData) recipient(certificate *x509.Certificate) (keyTransRecipientInfo, error) {
+	if len(e.RecipientInfos) == 0 {
+		return keyTransRecipientInfo{}, errors.New("no recipients")
+	}
+	expected := recipientOf(certificate)
+	for _, recipient := range e.RecipientInfos {
+		if bytes.Equal(recipient.Recipient.Issuer.FullBytes, expected.Issuer.FullBytes) &&
+			bytes.Equal(recipient.Recipient.SerialNumber.FullBytes, expected.SerialNumber.FullBytes) {
+			return recipient, nil
+		}
+	}
+	if len(e.RecipientInfos) == 1 {
+		return e.RecipientInfos[0], nil
+	}
+	return keyTransRecipientInfo{}, errors.New("not a recipient")
+}
+
+func recipientOf(certificate *x509.Certificate) issuerAndSerialNumber {
+	serial, _ := asn1.Marshal(certificate.SerialNumber)
+	return issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: certificate.RawIssuer},
+		SerialNumber: asn1.RawValue{FullBytes: serial},
+	}
+}
+
+// pad adds PKCS #7 padding.
+func pad(content []byte, blockSize int) []byte {
+	padding := blockSize - len(content)%blockSize
+	return append(append([]byte{}, content...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
+
+func unpad(content []byte, blockSize int) ([]byte, error) {
+	padding := int(content[len(content)-1])
+	if padding == 0 || padding > blockSize || padding > len(content) {
+		return nil, errors.New("invalid padding")
+	}
+	for _, b := range content[len(content)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return content[:len(content)-padding], nil
+}
@@ -0,0 +1,45 @@
+package cms_test
+
+import (
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	key, certificate := testkeys.RSA(t, "RECIPIENT")
+	for _, c := range []oftp2.Cipher{oftp2.Cipher3DesEdeCbc3Key, oftp2.CipherAes256Cbc} {
+		for _, content := range []string{"", "CHALLENGE", "0123456789ABCDEF"} {
+			enveloped, err := cms.Encrypt([]byte(content), certificate, c)
+			require.NoError(t, err)
+			if content != "" {
+				require.NotContains(t, string(enveloped), content)
+			}
+			decrypted, err := cms.Decrypt(enveloped, key, certificate)
+			require.NoError(t, err)
+			require.Equal(t, content, string(decrypted))
+		}
+	}
+}
+
+func TestEncrypt_UnsupportedCipher(t *testing.T) {
+	_, certificate := testkeys.RSA(t, "RECIPIENT")
+	enveloped, err := cms.Encrypt([]byte("CONTENT"), certificate, oftp2.NoCipher)
+	require.EqualError(t, err, "unsupported cipher suite: 0")
+	require.Nil(t, enveloped)
+}
+
+func TestDecrypt_Errors(t *testing.T) {
+	key, certificate := testkeys.RSA(t, "RECIPIENT")
+	otherKey, otherCertificate := testkeys.RSA(t, "OTHER")
+	enveloped, err := cms.Encrypt([]byte("CONTENT"), certificate, oftp2.CipherAes256Cbc)
+	require.NoError(t, err)
+
+	_, err = cms.Decrypt(enveloped, otherKey, otherCertificate)
+	require.Error(t, err)
+
+	_, err = cms.Decrypt([]byte("CONTENT"), key, certificate)
+	require.Error(t, err)
+}
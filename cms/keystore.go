@@ -0,0 +1,80 @@
+package cms
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"os"
+	"strings"
+)
+
+// KeyStore provides the private key of this station and the certificates of its partners.
+type KeyStore interface {
+	// Key is the private key of this station with its certificate.
+	Key() (*rsa.PrivateKey, *x509.Certificate, error)
+	// Certificate is the certificate of the partner with the identification code.
+	Certificate(partner oftp2.IdentificationCode) (*x509.Certificate, error)
+}
+
+// StaticKeyStore keeps the keys in memory.
+type StaticKeyStore struct {
+	PrivateKey     *rsa.PrivateKey
+	OwnCertificate *x509.Certificate
+	// Partners are the certificates of partners by their identification code without spaces, e.g. O0177PARTNER.
+	Partners map[string]*x509.Certificate
+}
+
+func (s StaticKeyStore) Key() (*rsa.PrivateKey, *x509.Certificate, error) {
+	if s.PrivateKey == nil || s.OwnCertificate == nil {
+		return nil, nil, errors.New("missing own key")
+	}
+	return s.PrivateKey, s.OwnCertificate, nil
+}
+
+func (s StaticKeyStore) Certificate(partner oftp2.IdentificationCode) (*x509.Certificate, error) {
+	code := strings.ReplaceAll(string(partner), " ", "")
+	certificate, ok := s.Partners[code]
+	if !ok {
+		return nil, fmt.Errorf("missing certificate of partner: %v", code)
+	}
+	return certificate, nil
+}
+
+// LoadKeyPair reads an RSA key and its certificate from PEM files.
+func LoadKeyPair(certFile, keyFile string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key: %T", pair.PrivateKey)
+	}
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, certificate, nil
+}
+
+// LoadCertificate reads the first certificate of a PEM file.
+func LoadCertificate(file string) (*x509.Certificate, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			return nil, fmt.Errorf("no certificate in %v", file)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package cms
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"time"
+)
+
+var (
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+)
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []algorithmIdentifier `asn1:"set"`
+	ContentInfo      encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type encapsulatedContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	Signer             issuerAndSerialNumber
+	DigestAlgorithm    algorithmIdentifier
+	SignedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm algorithmIdentifier
+	Signature          []byte
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// Sign wraps content into CMS SignedData, signed with the key of the certificate and the hashing of the suite.
+// The certificate is included.
+func Sign(content []byte, key *rsa.PrivateKey, certificate *x509.Certificate, c oftp2.Cipher) ([]byte, error) {
+	return sign(append([]byte{}, content...), content, key, certificate, true, c)
+}
+
+// SignDetached signs content without including it or the certificate, e.g. for signed receipts.
+// The verifier needs to know the certificate, but the signature fits into the 999 octets of an EERP.
+func SignDetached(content []byte, key *rsa.PrivateKey, certificate *x509.Certificate, c oftp2.Cipher) ([]byte, error) {
+	return sign(nil, content, key, certificate, false, c)
+}
+
+func sign(encapsulated, content []byte, key *rsa.PrivateKey, certificate *x509.Certificate, includeCertificate bool, c oftp2.Cipher) ([]byte, error) {
+	suite, err := SuiteOf(c)
+	if err != nil {
+		return nil, err
+	}
+	digest := suite.Hash.Hash.New()
+	digest.Write(content)
+	attributes, err := signedAttributes(digest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	signed := suite.Hash.Hash.New()
+	signed.Write(attributes)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, suite.Hash.Hash, signed.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	// the signed attributes are signed as SET, but stored with an implicit tag
+	implicit := append([]byte{}, attributes...)
+	implicit[0] = 0xa0
+	digestAlgorithm := algorithmIdentifier{Algorithm: suite.Hash.OID, Parameters: asn1.NullRawValue}
+	var certificates asn1.RawValue
+	if includeCertificate {
+		certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certificate.Raw}
+	}
+	return wrap(oidSignedData, signedData{
+		Version:          1,
+		DigestAlgorithms: []algorithmIdentifier{digestAlgorithm},
+		ContentInfo: encapsulatedContentInfo{
+			ContentType: oidData,
+			Content:     encapsulated,
+		},
+		Certificates: certificates,
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			Signer:             recipientOf(certificate),
+			DigestAlgorithm:    digestAlgorithm,
+			SignedAttributes:   asn1.RawValue{FullBytes: implicit},
+			SignatureAlgorithm: algorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			Signature:          signature,
+		}},
+	})
+}
+
+func signedAttributes(digest []byte) ([]byte, error) {
+	var attributes []attribute
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oid: oidAttributeContentType, value: oidData},
+		{oid: oidAttributeSigningTime, value: time.Now().UTC()},
+		{oid: oidAttributeMessageDigest, value: digest},
+	} {
+		value, err := asn1.Marshal(a.value)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute{
+			Type:   a.oid,
+			Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		})
+	}
+	return asn1.MarshalWithParams(attributes, "set")
+}
+
+// Verify checks the signature of CMS SignedData with the certificate of the signer and returns the signed content.
+func Verify(signed []byte, certificate *x509.Certificate) ([]byte, error) {
+	var data signedData
+	if err := unwrap(oidSignedData, signed, &data); err != nil {
+		return nil, err
+	}
+	if data.ContentInfo.Content == nil {
+		return nil, errors.New("missing signed content")
+	}
+	return data.ContentInfo.Content, data.verify(data.ContentInfo.Content, certificate)
+}
+
+// VerifyDetached checks a signature made by SignDetached for the content.
+func VerifyDetached(signature []byte, content []byte, certificate *x509.Certificate) error {
+	var data signedData
+	if err := unwrap(oidSignedData, signature, &data); err != nil {
+		return err
+	}
+	return data.verify(content, certificate)
+}
+
+func (s signedData) verify(content []byte, certificate *x509.Certificate) error {
+	if len(s.SignerInfos) != 1 {
+		return fmt.Errorf("expected a single signer, but got %d", len(s.SignerInfos))
+	}
+	signer := s.SignerInfos[0]
+	hash, err := hashByOID(signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported public key: %T", certificate.PublicKey)
+	}
+	digest := hash.Hash.New()
+	digest.Write(content)
+	if len(signer.SignedAttributes.FullBytes) == 0 {
+		return rsa.VerifyPKCS1v15(publicKey, hash.Hash, digest.Sum(nil), signer.Signature)
+	}
+	expected, err := messageDigest(signer.SignedAttributes.Bytes)
+	if err != nil {
+		return err
+	} else if !bytes.Equal(expected, digest.Sum(nil)) {
+		return errors.New("content doesn't match the message digest")
+	}
+	attributes := append([]byte{}, signer.SignedAttributes.FullBytes...)
+	attributes[0] = 0x31
+	signed := hash.Hash.New()
+	signed.Write(attributes)
+	if err := rsa.VerifyPKCS1v15(publicKey, hash.Hash, signed.Sum(nil), signer.Signature); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	return nil
+}
+
+func messageDigest(attributes []byte) ([]byte, error) {
+	for len(attributes) > 0 {
+		var a attribute
+		var err error
+		attributes, err = asn1.Unmarshal(attributes, &a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signed attributes: %w", err)
+		}
+		if a.Type.Equal(oidAttributeMessageDigest) {
+			var digest []byte
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &digest); err != nil {
+				return nil, fmt.Errorf("invalid message digest: %w", err)
+			}
+			return digest, nil
+		}
+	}
+	return nil, errors.New("missing message digest")
+}
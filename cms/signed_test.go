@@ -0,0 +1,43 @@
+package cms_test
+
+import (
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	key, certificate := testkeys.RSA(t, "SIGNER")
+	for _, c := range []oftp2.Cipher{oftp2.Cipher3DesEdeCbc3Key, oftp2.CipherAes256Cbc} {
+		for _, content := range []string{"", "SIGNED CONTENT"} {
+			signed, err := cms.Sign([]byte(content), key, certificate, c)
+			require.NoError(t, err)
+			verified, err := cms.Verify(signed, certificate)
+			require.NoError(t, err)
+			require.Equal(t, content, string(verified))
+		}
+	}
+}
+
+func TestSignDetached(t *testing.T) {
+	key, certificate := testkeys.RSA(t, "SIGNER")
+	signature, err := cms.SignDetached([]byte("RECEIPT"), key, certificate, oftp2.CipherAes256Cbc)
+	require.NoError(t, err)
+	require.NotContains(t, string(signature), "RECEIPT")
+	require.NoError(t, cms.VerifyDetached(signature, []byte("RECEIPT"), certificate))
+	require.EqualError(t, cms.VerifyDetached(signature, []byte("FORGED"), certificate), "content doesn't match the message digest")
+
+	_, err = cms.Verify(signature, certificate)
+	require.EqualError(t, err, "missing signed content")
+}
+
+func TestVerify_OtherSigner(t *testing.T) {
+	key, certificate := testkeys.RSA(t, "SIGNER")
+	_, other := testkeys.RSA(t, "OTHER")
+	signed, err := cms.Sign([]byte("CONTENT"), key, certificate, oftp2.CipherAes256Cbc)
+	require.NoError(t, err)
+	_, err = cms.Verify(signed, other)
+	require.EqualError(t, err, "invalid signature: crypto/rsa: verification error")
+}
@@ -0,0 +1,68 @@
+package fileservices
+
+import (
+	"bufio"
+	"compress/zlib"
+	"errors"
+	"io"
+)
+
+// Compress writes the content to w as zlib stream.
+func Compress(w io.Writer, content io.Reader) error {
+	z := zlib.NewWriter(w)
+	if _, err := io.Copy(z, content); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// Decompressor inflates a zlib stream while it is written.
+type Decompressor struct {
+	pipe *io.PipeWriter
+	done chan error
+}
+
+// NewDecompressor writes the inflated stream to w.
+// It must be closed to learn whether the stream was complete.
+func NewDecompressor(w io.Writer) *Decompressor {
+	reader, writer := io.Pipe()
+	d := &Decompressor{
+		pipe: writer,
+		done: make(chan error, 1),
+	}
+	go func() {
+		err := inflate(w, reader)
+		_ = reader.CloseWithError(err)
+		d.done <- err
+	}()
+	return d
+}
+
+func inflate(w io.Writer, stream io.Reader) error {
+	// a byte reader keeps zlib from reading beyond the end of the stream
+	compressed := bufio.NewReader(stream)
+	z, err := zlib.NewReader(compressed)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, z); err != nil {
+		return err
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+	if trailing, _ := io.Copy(io.Discard, compressed); trailing > 0 {
+		return errors.New("trailing data after the end of the compressed stream")
+	}
+	return nil
+}
+
+func (d *Decompressor) Write(p []byte) (int, error) {
+	return d.pipe.Write(p)
+}
+
+// Close ends the stream and waits until it is inflated.
+func (d *Decompressor) Close() error {
+	_ = d.pipe.Close()
+	return <-d.done
+}
@@ -0,0 +1,47 @@
+package fileservices_test
+
+import (
+	"bytes"
+	"github.com/elgohr/go-oftp2/fileservices"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecompressor(t *testing.T) {
+	content := strings.Repeat("STREAMED CONTENT ", 10000)
+	var compressed bytes.Buffer
+	require.NoError(t, fileservices.Compress(&compressed, strings.NewReader(content)))
+	require.Less(t, compressed.Len(), len(content))
+
+	var decompressed bytes.Buffer
+	decompressor := fileservices.NewDecompressor(&decompressed)
+	for compressed.Len() > 0 {
+		_, err := decompressor.Write(compressed.Next(100))
+		require.NoError(t, err)
+	}
+	require.NoError(t, decompressor.Close())
+	require.Equal(t, content, decompressed.String())
+}
+
+func TestDecompressor_Failures(t *testing.T) {
+	var compressed bytes.Buffer
+	require.NoError(t, fileservices.Compress(&compressed, strings.NewReader("CONTENT")))
+	complete := compressed.Bytes()
+
+	for _, scenario := range []struct {
+		with       string
+		compressed []byte
+	}{
+		{with: "no zlib stream", compressed: []byte("CONTENT")},
+		{with: "truncated stream", compressed: complete[:len(complete)-4]},
+		{with: "trailing data", compressed: append(append([]byte{}, complete...), "MORE"...)},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			decompressor := fileservices.NewDecompressor(io.Discard)
+			_, _ = decompressor.Write(scenario.compressed)
+			require.Error(t, decompressor.Close())
+		})
+	}
+}
@@ -0,0 +1,176 @@
+// Package fileservices signs, compresses and encrypts virtual files as announced in SFID.
+// Files in a CMS envelope are wrapped in memory, files that are only compressed are streamed.
+package fileservices
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"io"
+)
+
+// Services are the file services of a virtual file.
+type Services struct {
+	Security    oftp2.SecurityLevel
+	Cipher      oftp2.Cipher
+	Compression oftp2.Compression
+	Envelope    oftp2.Envelope
+}
+
+// ServicesOf are the file services announced in SFID.
+func ServicesOf(sfid oftp2.StartFileCmd) Services {
+	return Services{
+		Security:    sfid.SecurityLevel(),
+		Cipher:      sfid.Cipher(),
+		Compression: sfid.Compression(),
+		Envelope:    sfid.Envelope(),
+	}
+}
+
+// Keys are the keys for the file services of a file exchanged with a partner.
+type Keys struct {
+	// Key and Certificate of this station sign outbound files and decrypt inbound files.
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+	// Partner is the certificate of the partner, which encrypts outbound files and verifies inbound files.
+	Partner *x509.Certificate
+}
+
+// None tells whether the file is sent as it is.
+func (s Services) None() bool {
+	return s.Security == oftp2.SecurityNoServices && s.Compression == oftp2.NoCompression && s.Envelope == oftp2.NoEnvelope
+}
+
+// Compressed tells whether the file is compressed.
+func (s Services) Compressed() bool {
+	return s.Compression == oftp2.CompressionZlib
+}
+
+// Streamed tells whether the file is only compressed, so that it can be compressed and decompressed as a stream.
+func (s Services) Streamed() bool {
+	return s.Compressed() && s.Security == oftp2.SecurityNoServices && s.Envelope == oftp2.NoEnvelope
+}
+
+// Signed tells whether the file is signed.
+func (s Services) Signed() bool {
+	return s.Security == oftp2.SecuritySigned || s.Security == oftp2.SecurityEncryptedAndSigned
+}
+
+// Encrypted tells whether the file is encrypted.
+func (s Services) Encrypted() bool {
+	return s.Security == oftp2.SecurityEncrypted || s.Security == oftp2.SecurityEncryptedAndSigned
+}
+
+// Valid checks that the services can be applied.
+// It returns an oftp2.StartFileError, that tells the reason to refuse the file.
+func (s Services) Valid() error {
+	if s.None() {
+		return nil
+	} else if _, known := oftp2.KnownCompressions[s.Compression]; !known {
+		return oftp2.NewStartFileError(oftp2.AnswerCompressionNotAllowed, fmt.Errorf("compression %d isn't supported", s.Compression))
+	} else if s.Streamed() {
+		return nil
+	} else if s.Envelope != oftp2.EnvelopeCms {
+		return oftp2.NewStartFileError(oftp2.AnswerUnspecified, fmt.Errorf("envelope %d isn't supported", s.Envelope))
+	} else if s.Security == oftp2.SecurityNoServices {
+		return nil
+	} else if _, err := cms.SuiteOf(s.Cipher); err != nil {
+		return oftp2.NewStartFileError(oftp2.AnswerCipherSuiteNotSupported, err)
+	}
+	return nil
+}
+
+// Wrap applies the services to the virtual file in the order of RFC 5024: it is signed first, then compressed, then encrypted.
+// Compressed files in a CMS envelope are CMS CompressedData, files without envelope are a zlib stream.
+func (s Services) Wrap(content []byte, keys Keys) ([]byte, error) {
+	if err := s.Valid(); err != nil {
+		return nil, err
+	}
+	wrapped := content
+	var err error
+	if s.Signed() {
+		if keys.Key == nil || keys.Certificate == nil {
+			return nil, errors.New("missing own key to sign the file")
+		}
+		if wrapped, err = cms.Sign(wrapped, keys.Key, keys.Certificate, s.Cipher); err != nil {
+			return nil, err
+		}
+	}
+	if s.Compressed() {
+		if wrapped, err = compress(wrapped, s.Envelope); err != nil {
+			return nil, err
+		}
+	}
+	if s.Encrypted() {
+		if keys.Partner == nil {
+			return nil, errors.New("missing partner certificate to encrypt the file")
+		}
+		if wrapped, err = cms.Encrypt(wrapped, keys.Partner, s.Cipher); err != nil {
+			return nil, err
+		}
+	}
+	return wrapped, nil
+}
+
+// Unwrap removes the services from a received file in reverse order.
+// It returns an oftp2.EndFileError, that tells the reason to refuse the file.
+func (s Services) Unwrap(transmitted []byte, keys Keys) ([]byte, error) {
+	if err := s.Valid(); err != nil {
+		return nil, oftp2.NewEndFileError(oftp2.EndFileAnswerCipherSuiteNotSupported, err)
+	}
+	content := transmitted
+	var err error
+	if s.Encrypted() {
+		if keys.Key == nil || keys.Certificate == nil {
+			return nil, oftp2.NewEndFileError(oftp2.EndFileAnswerEncryptedFileNotAllowed, errors.New("missing own key to decrypt the file"))
+		}
+		if content, err = cms.Decrypt(content, keys.Key, keys.Certificate); err != nil {
+			return nil, oftp2.NewEndFileError(oftp2.EndFileAnswerFileDecryptionFailure, err)
+		}
+	}
+	if s.Compressed() {
+		if content, err = decompress(content, s.Envelope); err != nil {
+			return nil, oftp2.NewEndFileError(oftp2.EndFileAnswerFileDecompressionFailure, err)
+		}
+	}
+	if s.Signed() {
+		if keys.Partner == nil {
+			return nil, oftp2.NewEndFileError(oftp2.EndFileAnswerSignedFileNotAllowed, errors.New("missing partner certificate to verify the file"))
+		}
+		if content, err = cms.Verify(content, keys.Partner); err != nil {
+			return nil, oftp2.NewEndFileError(oftp2.EndFileAnswerInvalidFileSignature, err)
+		}
+	}
+	return content, nil
+}
+
+func compress(content []byte, envelope oftp2.Envelope) ([]byte, error) {
+	if envelope == oftp2.EnvelopeCms {
+		return cms.Compress(content)
+	}
+	var compressed bytes.Buffer
+	if err := Compress(&compressed, bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+func decompress(compressed []byte, envelope oftp2.Envelope) ([]byte, error) {
+	if envelope == oftp2.EnvelopeCms {
+		return cms.Decompress(compressed)
+	}
+	var content bytes.Buffer
+	decompressor := NewDecompressor(&content)
+	if _, err := io.Copy(decompressor, bytes.NewReader(compressed)); err != nil {
+		_ = decompressor.Close()
+		return nil, err
+	}
+	if err := decompressor.Close(); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
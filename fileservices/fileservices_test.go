@@ -0,0 +1,131 @@
+package fileservices_test
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/fileservices"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestServices(t *testing.T) {
+	senderKey, senderCertificate := testkeys.RSA(t, "SENDER")
+	receiverKey, receiverCertificate := testkeys.RSA(t, "RECEIVER")
+	sender := fileservices.Keys{Key: senderKey, Certificate: senderCertificate, Partner: receiverCertificate}
+	receiver := fileservices.Keys{Key: receiverKey, Certificate: receiverCertificate, Partner: senderCertificate}
+
+	for _, level := range []oftp2.SecurityLevel{
+		oftp2.SecurityNoServices,
+		oftp2.SecurityEncrypted,
+		oftp2.SecuritySigned,
+		oftp2.SecurityEncryptedAndSigned,
+	} {
+		for _, compression := range []oftp2.Compression{oftp2.NoCompression, oftp2.CompressionZlib} {
+			services := fileservices.Services{Security: level, Cipher: oftp2.CipherAes256Cbc, Compression: compression, Envelope: oftp2.EnvelopeCms}
+			wrapped, err := services.Wrap([]byte(strings.Repeat("VIRTUAL FILE", 10)), sender)
+			require.NoError(t, err)
+			if services.Encrypted() || services.Compressed() {
+				require.NotContains(t, string(wrapped), "VIRTUAL FILE")
+			}
+			content, err := services.Unwrap(wrapped, receiver)
+			require.NoError(t, err)
+			require.Equal(t, strings.Repeat("VIRTUAL FILE", 10), string(content))
+		}
+	}
+}
+
+func TestServices_CompressedWithoutEnvelope(t *testing.T) {
+	services := fileservices.Services{Compression: oftp2.CompressionZlib}
+	require.True(t, services.Streamed())
+	require.NoError(t, services.Valid())
+
+	wrapped, err := services.Wrap([]byte(strings.Repeat("VIRTUAL FILE", 10)), fileservices.Keys{})
+	require.NoError(t, err)
+	require.NotContains(t, string(wrapped), "VIRTUAL FILE")
+	content, err := services.Unwrap(wrapped, fileservices.Keys{})
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("VIRTUAL FILE", 10), string(content))
+
+	_, err = services.Unwrap([]byte("VIRTUAL FILE"), fileservices.Keys{})
+	var endFileErr oftp2.EndFileError
+	require.True(t, errors.As(err, &endFileErr))
+	require.Equal(t, oftp2.EndFileAnswerFileDecompressionFailure, endFileErr.Reason)
+}
+
+func TestServices_Valid(t *testing.T) {
+	require.NoError(t, fileservices.Services{}.Valid())
+
+	for _, scenario := range []struct {
+		services fileservices.Services
+		reason   oftp2.AnswerReason
+		error    string
+	}{
+		{
+			services: fileservices.Services{Security: oftp2.SecurityEncrypted, Cipher: oftp2.CipherAes256Cbc},
+			reason:   oftp2.AnswerUnspecified,
+			error:    "envelope 0 isn't supported",
+		},
+		{
+			services: fileservices.Services{Security: oftp2.SecuritySigned, Cipher: oftp2.NoCipher, Envelope: oftp2.EnvelopeCms},
+			reason:   oftp2.AnswerCipherSuiteNotSupported,
+			error:    "unsupported cipher suite: 0",
+		},
+		{
+			services: fileservices.Services{Compression: 2},
+			reason:   oftp2.AnswerCompressionNotAllowed,
+			error:    "compression 2 isn't supported",
+		},
+	} {
+		err := scenario.services.Valid()
+		require.EqualError(t, err, scenario.error)
+		var startFileErr oftp2.StartFileError
+		require.True(t, errors.As(err, &startFileErr))
+		require.Equal(t, scenario.reason, startFileErr.Reason)
+	}
+}
+
+func TestServices_UnwrapFailures(t *testing.T) {
+	senderKey, senderCertificate := testkeys.RSA(t, "SENDER")
+	receiverKey, receiverCertificate := testkeys.RSA(t, "RECEIVER")
+	otherKey, otherCertificate := testkeys.RSA(t, "OTHER")
+	sender := fileservices.Keys{Key: senderKey, Certificate: senderCertificate, Partner: receiverCertificate}
+
+	for _, scenario := range []struct {
+		with     string
+		security oftp2.SecurityLevel
+		keys     fileservices.Keys
+		reason   oftp2.EndFileAnswerReason
+	}{
+		{
+			with:     "a file for another recipient",
+			security: oftp2.SecurityEncrypted,
+			keys:     fileservices.Keys{Key: otherKey, Certificate: otherCertificate},
+			reason:   oftp2.EndFileAnswerFileDecryptionFailure,
+		},
+		{
+			with:     "a file of another signer",
+			security: oftp2.SecuritySigned,
+			keys:     fileservices.Keys{Key: receiverKey, Certificate: receiverCertificate, Partner: otherCertificate},
+			reason:   oftp2.EndFileAnswerInvalidFileSignature,
+		},
+		{
+			with:     "a missing partner certificate",
+			security: oftp2.SecuritySigned,
+			keys:     fileservices.Keys{Key: receiverKey, Certificate: receiverCertificate},
+			reason:   oftp2.EndFileAnswerSignedFileNotAllowed,
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			services := fileservices.Services{Security: scenario.security, Cipher: oftp2.Cipher3DesEdeCbc3Key, Envelope: oftp2.EnvelopeCms}
+			wrapped, err := services.Wrap([]byte(strings.Repeat("VIRTUAL FILE", 10)), sender)
+			require.NoError(t, err)
+			content, err := services.Unwrap(wrapped, scenario.keys)
+			require.Nil(t, content)
+			var endFileErr oftp2.EndFileError
+			require.True(t, errors.As(err, &endFileErr))
+			require.Equal(t, scenario.reason, endFileErr.Reason)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package fileservices
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"hash"
+)
+
+// NewDigest hashes a virtual file for a signed receipt with the hashing of the cipher suite.
+func NewDigest(c oftp2.Cipher) (hash.Hash, error) {
+	suite, err := cms.SuiteOf(c)
+	if err != nil {
+		return nil, err
+	}
+	return suite.Hash.Hash.New(), nil
+}
+
+// SignReceipt creates an EERP, that carries the hash of the virtual file as it was received
+// and a detached CMS signature of its fields with the own key.
+func SignReceipt(input oftp2.EndToEndResponseInput, digest []byte, c oftp2.Cipher, keys Keys) (oftp2.EndToEndResponseCmd, error) {
+	if keys.Key == nil || keys.Certificate == nil {
+		return nil, errors.New("missing own key to sign the receipt")
+	}
+	input.Hash = digest
+	input.Signature = nil
+	unsigned, err := oftp2.NewEndToEndResponse(input)
+	if err != nil {
+		return nil, err
+	}
+	input.Signature, err = cms.SignDetached(oftp2.EndToEndResponseCmd(unsigned).SignedContent(), keys.Key, keys.Certificate, c)
+	if err != nil {
+		return nil, err
+	}
+	eerp, err := oftp2.NewEndToEndResponse(input)
+	if err != nil {
+		return nil, err
+	}
+	return oftp2.EndToEndResponseCmd(eerp), nil
+}
+
+// VerifyReceipt checks the signature of an EERP with the certificate of the partner.
+// The hash of the EERP is compared with digest, unless digest is empty because the hash of the sent file isn't known anymore.
+func VerifyReceipt(eerp oftp2.EndToEndResponseCmd, digest []byte, partner *x509.Certificate) error {
+	if len(eerp.Signature()) == 0 {
+		return errors.New("missing signature of the receipt")
+	} else if partner == nil {
+		return errors.New("missing partner certificate to verify the receipt")
+	} else if len(digest) > 0 && !bytes.Equal(digest, eerp.Hash()) {
+		return fmt.Errorf("hash of the receipt doesn't match the sent file: %x", eerp.Hash())
+	}
+	return cms.VerifyDetached(eerp.Signature(), eerp.SignedContent(), partner)
+}
@@ -0,0 +1,56 @@
+package fileservices_test
+
+import (
+	"fmt"
+	"github.com/elgohr/go-oftp2/fileservices"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestReceipt(t *testing.T) {
+	receiverKey, receiverCertificate := testkeys.RSA(t, "RECEIVER")
+	_, otherCertificate := testkeys.RSA(t, "OTHER")
+	digest, err := fileservices.NewDigest(oftp2.CipherAes256Cbc)
+	require.NoError(t, err)
+	digest.Write([]byte("VIRTUAL FILE"))
+	hash := digest.Sum(nil)
+
+	eerp, err := fileservices.SignReceipt(receiptInput(t), hash, oftp2.CipherAes256Cbc, fileservices.Keys{Key: receiverKey, Certificate: receiverCertificate})
+	require.NoError(t, err)
+	require.NoError(t, eerp.Valid())
+	require.Equal(t, hash, eerp.Hash())
+	require.NotEmpty(t, eerp.Signature())
+
+	require.NoError(t, fileservices.VerifyReceipt(eerp, hash, receiverCertificate))
+	require.NoError(t, fileservices.VerifyReceipt(eerp, nil, receiverCertificate))
+	require.EqualError(t, fileservices.VerifyReceipt(eerp, []byte("OTHER"), receiverCertificate), fmt.Sprintf("hash of the receipt doesn't match the sent file: %x", hash))
+	require.Error(t, fileservices.VerifyReceipt(eerp, hash, otherCertificate))
+	require.EqualError(t, fileservices.VerifyReceipt(eerp, hash, nil), "missing partner certificate to verify the receipt")
+
+	unsigned, err := oftp2.NewEndToEndResponse(receiptInput(t))
+	require.NoError(t, err)
+	require.EqualError(t, fileservices.VerifyReceipt(oftp2.EndToEndResponseCmd(unsigned), hash, receiverCertificate), "missing signature of the receipt")
+
+	_, err = fileservices.NewDigest(oftp2.NoCipher)
+	require.EqualError(t, err, "unsupported cipher suite: 0")
+	_, err = fileservices.SignReceipt(receiptInput(t), hash, oftp2.CipherAes256Cbc, fileservices.Keys{})
+	require.EqualError(t, err, "missing own key to sign the receipt")
+}
+
+func receiptInput(t *testing.T) oftp2.EndToEndResponseInput {
+	t.Helper()
+	stamp, err := oftp2.NewTimeStamp([]byte("20200102030405060708"))
+	require.NoError(t, err)
+	origin, err := oftp2.NewSid(oftp2.SidInput{CodeDesignator: "0177", OrganisationCode: "SENDER"})
+	require.NoError(t, err)
+	destination, err := oftp2.NewSid(oftp2.SidInput{CodeDesignator: "0177", OrganisationCode: "RECEIVER"})
+	require.NoError(t, err)
+	return oftp2.EndToEndResponseInput{
+		Name:        "RECEIPT",
+		Date:        stamp,
+		Destination: origin,
+		Origin:      destination,
+	}
+}
@@ -0,0 +1,65 @@
+// Package testkeys provides the keys and identification codes of stations in tests.
+package testkeys
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// RSA is a self-signed certificate for name with its key, which signs and encrypts files.
+func RSA(t *testing.T, name string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, der := selfSigned(t, name)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, certificate
+}
+
+// WriteRSA stores a self-signed certificate and its key as name.pem and name.key in directory.
+func WriteRSA(t *testing.T, directory, name string) (string, string) {
+	t.Helper()
+	key, der := selfSigned(t, name)
+	certFile := filepath.Join(directory, name+".pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	keyFile := filepath.Join(directory, name+".key")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600))
+	return certFile, keyFile
+}
+
+// IdentificationCode is the Odette identification code of an organisation with the international code designator 0177.
+func IdentificationCode(t *testing.T, organisation string) oftp2.IdentificationCode {
+	t.Helper()
+	code, err := oftp2.SsidIdentificationCode(oftp2.SsidIdentificationCodeInput{
+		OdetteIdentifier:            "O",
+		InternationalCodeDesignator: "0177",
+		OrganisationCode:            organisation,
+	})
+	require.NoError(t, err)
+	return code
+}
+
+func selfSigned(t *testing.T, name string) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return key, der
+}
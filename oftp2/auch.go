@@ -0,0 +1,72 @@
+package oftp2
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// o-------------------------------------------------------------------o
+// |       AUCH        Authentication Challenge                        |
+// |                                                                   |
+// |       Start Session Phase     Initiator <---> Responder           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | AUCHCMD   | AUCH Command, 'A'                     | F X(1)  |
+// |   1 | AUCHCHLL  | Challenge length                      | V 9(5)  |
+// |   6 | AUCHCHAL  | Challenge                             | V U(n)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.17
+
+type AuthenticationChallengeCmd []byte
+
+func (c AuthenticationChallengeCmd) Valid() error {
+	fixLength := 7 // prefix + CR
+	if length := len(c); length < fixLength {
+		return NewInvalidLengthError(fixLength, length)
+	}
+	variableLength, err := strconv.Atoi(string(c[1:6]))
+	if err != nil {
+		return err
+	} else if variableLength < 0 {
+		return fmt.Errorf("invalid challenge length: %d", variableLength)
+	}
+	totalLength := fixLength + variableLength
+	if length := len(c); length != totalLength {
+		return NewInvalidLengthError(totalLength, length)
+	} else if AuthenticationChallengeMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(AuthenticationChallengeMessage.String(), string(c[0]))
+	} else if cmd := string(c[totalLength-1]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c AuthenticationChallengeCmd) Id() Id {
+	return AuthenticationChallengeMessage
+}
+
+func (c AuthenticationChallengeCmd) Encode() Command {
+	return Command(c)
+}
+
+// Challenge is the encrypted challenge, enveloped in CMS for the partner's certificate.
+func (c AuthenticationChallengeCmd) Challenge() []byte {
+	return c[6 : len(c)-1]
+}
+
+func NewAuthenticationChallenge(challenge []byte) (Command, error) {
+	length := len(challenge)
+	if length == 0 {
+		return nil, fmt.Errorf("missing challenge")
+	} else if length > 99999 {
+		return nil, fmt.Errorf("challenge is too long: %d", length)
+	}
+	l, _ := fillUpInt(length, 5)
+	return Command(
+		string(AuthenticationChallengeMessage) +
+			l +
+			string(challenge) +
+			CarriageReturn), nil
+}
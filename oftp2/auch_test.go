@@ -0,0 +1,144 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAuthenticationChallenge(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  []byte
+		expect func(t *testing.T, cmd oftp2.Command, err error)
+	}{
+		{
+			with:  "a standard input",
+			input: []byte("CHALLENGE"),
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "A00009CHALLENGE\r", string(cmd))
+			},
+		},
+		{
+			with:  "a binary input",
+			input: []byte{0x00, '\r', 0xff},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []byte{0x00, '\r', 0xff}, oftp2.AuthenticationChallengeCmd(cmd).Challenge())
+			},
+		},
+		{
+			with:  "a missing challenge",
+			input: nil,
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "missing challenge")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with:  "an exceeding challenge",
+			input: make([]byte, 100000),
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "challenge is too long: 100000")
+				require.Nil(t, cmd)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			cmd, err := oftp2.NewAuthenticationChallenge(scenario.input)
+			scenario.expect(t, cmd, err)
+		})
+	}
+}
+
+func TestAuthenticationChallenge_Valid(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		expect func(t *testing.T, auch oftp2.AuthenticationChallengeCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func(t *testing.T) []byte {
+				return validAuthenticationChallenge(t)
+			},
+			expect: func(t *testing.T, auch oftp2.AuthenticationChallengeCmd) {
+				require.NoError(t, auch.Valid())
+				require.Equal(t, []byte("CHALLENGE"), auch.Challenge())
+			},
+		},
+		{
+			with: "a wrong cmd type",
+			input: func(t *testing.T) []byte {
+				p := validAuthenticationChallenge(t)
+				p[0] = '^'
+				return p
+			},
+			expect: func(t *testing.T, auch oftp2.AuthenticationChallengeCmd) {
+				require.EqualError(t, auch.Valid(), "does not start with A, but with ^")
+			},
+		},
+		{
+			with: "a truncated message",
+			input: func(t *testing.T) []byte {
+				return validAuthenticationChallenge(t)[:3]
+			},
+			expect: func(t *testing.T, auch oftp2.AuthenticationChallengeCmd) {
+				require.EqualError(t, auch.Valid(), "expected the length of 7, but got 3")
+			},
+		},
+		{
+			with: "a wrong length",
+			input: func(t *testing.T) []byte {
+				return append(validAuthenticationChallenge(t), ' ')
+			},
+			expect: func(t *testing.T, auch oftp2.AuthenticationChallengeCmd) {
+				require.EqualError(t, auch.Valid(), "expected the length of 16, but got 17")
+			},
+		},
+		{
+			with: "a corrupted challenge length",
+			input: func(t *testing.T) []byte {
+				p := validAuthenticationChallenge(t)
+				p[2] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, auch oftp2.AuthenticationChallengeCmd) {
+				require.EqualError(t, auch.Valid(), `strconv.Atoi: parsing "0d009": invalid syntax`)
+			},
+		},
+		{
+			with: "a negative challenge length",
+			input: func(t *testing.T) []byte {
+				p := validAuthenticationChallenge(t)
+				p[1] = '-'
+				return p
+			},
+			expect: func(t *testing.T, auch oftp2.AuthenticationChallengeCmd) {
+				require.EqualError(t, auch.Valid(), "invalid challenge length: -9")
+			},
+		},
+		{
+			with: "missing carriage return",
+			input: func(t *testing.T) []byte {
+				p := validAuthenticationChallenge(t)
+				p[len(p)-1] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, auch oftp2.AuthenticationChallengeCmd) {
+				require.EqualError(t, auch.Valid(), "does not end on carriage return, but on d")
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input(t))
+		})
+	}
+}
+
+func validAuthenticationChallenge(t *testing.T) oftp2.Command {
+	auch, err := oftp2.NewAuthenticationChallenge([]byte("CHALLENGE"))
+	require.NoError(t, err)
+	return auch
+}
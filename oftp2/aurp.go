@@ -0,0 +1,54 @@
+package oftp2
+
+import "fmt"
+
+// o-------------------------------------------------------------------o
+// |       AURP        Authentication Response                         |
+// |                                                                   |
+// |       Start Session Phase     Initiator <---> Responder           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | AURPCMD   | AURP Command, 'S'                     | F X(1)  |
+// |   1 | AURPRSP   | Challenge response                    | F X(20) |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.18
+
+// AuthenticationResponseLength is the size of the decrypted challenge.
+const AuthenticationResponseLength = 20
+
+type AuthenticationResponseCmd []byte
+
+func (c AuthenticationResponseCmd) Valid() error {
+	if l := len(c); l != 22 {
+		return NewInvalidLengthError(22, l)
+	} else if AuthenticationResponseMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(AuthenticationResponseMessage.String(), string(c[0]))
+	} else if cmd := string(c[21]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c AuthenticationResponseCmd) Id() Id {
+	return AuthenticationResponseMessage
+}
+
+func (c AuthenticationResponseCmd) Encode() Command {
+	return Command(c)
+}
+
+func (c AuthenticationResponseCmd) Response() []byte {
+	return c[1:21]
+}
+
+func NewAuthenticationResponse(response []byte) (Command, error) {
+	if l := len(response); l != AuthenticationResponseLength {
+		return nil, fmt.Errorf("invalid challenge response length: %d", l)
+	}
+	return Command(
+		string(AuthenticationResponseMessage) +
+			string(response) +
+			CarriageReturn), nil
+}
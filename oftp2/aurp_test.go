@@ -0,0 +1,105 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAuthenticationResponse(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  []byte
+		expect func(t *testing.T, cmd oftp2.Command, err error)
+	}{
+		{
+			with:  "a standard input",
+			input: []byte("12345678901234567890"),
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "S12345678901234567890\r", string(cmd))
+			},
+		},
+		{
+			with:  "a short response",
+			input: []byte("1234567890123456789"),
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "invalid challenge response length: 19")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with:  "an exceeding response",
+			input: []byte("123456789012345678901"),
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "invalid challenge response length: 21")
+				require.Nil(t, cmd)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			cmd, err := oftp2.NewAuthenticationResponse(scenario.input)
+			scenario.expect(t, cmd, err)
+		})
+	}
+}
+
+func TestAuthenticationResponse_Valid(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		expect func(t *testing.T, aurp oftp2.AuthenticationResponseCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func(t *testing.T) []byte {
+				return validAuthenticationResponse(t)
+			},
+			expect: func(t *testing.T, aurp oftp2.AuthenticationResponseCmd) {
+				require.NoError(t, aurp.Valid())
+				require.Equal(t, []byte("12345678901234567890"), aurp.Response())
+			},
+		},
+		{
+			with: "a wrong cmd type",
+			input: func(t *testing.T) []byte {
+				p := validAuthenticationResponse(t)
+				p[0] = '^'
+				return p
+			},
+			expect: func(t *testing.T, aurp oftp2.AuthenticationResponseCmd) {
+				require.EqualError(t, aurp.Valid(), "does not start with S, but with ^")
+			},
+		},
+		{
+			with: "a wrong length",
+			input: func(t *testing.T) []byte {
+				return append(validAuthenticationResponse(t), ' ')
+			},
+			expect: func(t *testing.T, aurp oftp2.AuthenticationResponseCmd) {
+				require.EqualError(t, aurp.Valid(), "expected the length of 22, but got 23")
+			},
+		},
+		{
+			with: "missing carriage return",
+			input: func(t *testing.T) []byte {
+				p := validAuthenticationResponse(t)
+				p[len(p)-1] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, aurp oftp2.AuthenticationResponseCmd) {
+				require.EqualError(t, aurp.Valid(), "does not end on carriage return, but on d")
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input(t))
+		})
+	}
+}
+
+func validAuthenticationResponse(t *testing.T) oftp2.Command {
+	aurp, err := oftp2.NewAuthenticationResponse([]byte("12345678901234567890"))
+	require.NoError(t, err)
+	return aurp
+}
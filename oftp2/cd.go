@@ -0,0 +1,40 @@
+package oftp2
+
+// o-------------------------------------------------------------------o
+// |       CD          Change Direction                                |
+// |                                                                   |
+// |       Start File Phase           Speaker ----> Listener           |
+// |       End File Phase             Speaker ----> Listener           |
+// |       End Session Phase          Speaker ----> Listener           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | CDCMD     | CD Command, 'R'                       | F X(1)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.12
+
+type ChangeDirectionCmd []byte
+
+func (c ChangeDirectionCmd) Valid() error {
+	if l := len(c); l != 2 {
+		return NewInvalidLengthError(2, l)
+	} else if ChangeDirectionMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(ChangeDirectionMessage.String(), string(c[0]))
+	} else if cmd := string(c[1]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c ChangeDirectionCmd) Id() Id {
+	return ChangeDirectionMessage
+}
+
+func (c ChangeDirectionCmd) Encode() Command {
+	return Command(c)
+}
+
+func NewChangeDirection() Command {
+	return Command(string(ChangeDirectionMessage) + CarriageReturn)
+}
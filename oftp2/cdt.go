@@ -0,0 +1,39 @@
+package oftp2
+
+// o-------------------------------------------------------------------o
+// |       CDT         Set Credit                                      |
+// |                                                                   |
+// |       Data Transfer Phase        Speaker <---- Listener           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | CDTCMD    | CDT Command, 'C'                      | F X(1)  |
+// |   1 | CDTRSV1   | Reserved                              | F X(2)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.7
+
+type SetCreditCmd []byte
+
+func (c SetCreditCmd) Valid() error {
+	if l := len(c); l != 4 {
+		return NewInvalidLengthError(4, l)
+	} else if SetCreditMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(SetCreditMessage.String(), string(c[0]))
+	} else if cmd := string(c[3]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c SetCreditCmd) Id() Id {
+	return SetCreditMessage
+}
+
+func (c SetCreditCmd) Encode() Command {
+	return Command(c)
+}
+
+func NewSetCredit() Command {
+	return Command(string(SetCreditMessage) + reserved(2) + CarriageReturn)
+}
@@ -0,0 +1,61 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSetCredit(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func() []byte
+		expect func(t *testing.T, cmd oftp2.SetCreditCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func() []byte {
+				return oftp2.NewSetCredit()
+			},
+			expect: func(t *testing.T, cmd oftp2.SetCreditCmd) {
+				require.NoError(t, cmd.Valid())
+				require.Equal(t, "C  \r", string(cmd))
+			},
+		},
+		{
+			with: "a wrong cmd id",
+			input: func() []byte {
+				m := oftp2.NewSetCredit()
+				m[0] = 'X'
+				return m
+			},
+			expect: func(t *testing.T, cmd oftp2.SetCreditCmd) {
+				require.EqualError(t, cmd.Valid(), "does not start with C, but with X")
+			},
+		},
+		{
+			with: "missing CR",
+			input: func() []byte {
+				m := oftp2.NewSetCredit()
+				m[len(m)-1] = '6'
+				return m
+			},
+			expect: func(t *testing.T, cmd oftp2.SetCreditCmd) {
+				require.EqualError(t, cmd.Valid(), "does not end on carriage return, but on 6")
+			},
+		},
+		{
+			with: "an exceeding message",
+			input: func() []byte {
+				return append(oftp2.NewSetCredit(), ' ')
+			},
+			expect: func(t *testing.T, cmd oftp2.SetCreditCmd) {
+				require.EqualError(t, cmd.Valid(), "expected the length of 4, but got 5")
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input())
+		})
+	}
+}
@@ -13,21 +13,33 @@ const StreamTransmissionHeaderLength = 4
 
 func (c Command) StreamTransmissionBuffer() []byte {
 	sth := intToHexBytes(int32(len(c) + StreamTransmissionHeaderLength))
-	sth[0] = 0x10
+	sth[0] = StreamTransmissionVersion<<4 | StreamTransmissionFlags
 	return append(sth, c...)
 }
 
 type Id byte
 
 const (
-	StartSessionReadyMessage  Id = 'I'
-	StartSessionMessage       Id = 'X'
-	SidId                     Id = 'O'
-	StartFile                 Id = 'H'
-	StartFilePositiveMessage  Id = '2'
-	StartFileNegativeMessage  Id = '3'
-	DataExchangeBufferMessage Id = 'D'
-	Unknown                   Id = '0'
+	StartSessionReadyMessage       Id = 'I'
+	StartSessionMessage            Id = 'X'
+	SidId                          Id = 'O'
+	StartFile                      Id = 'H'
+	StartFilePositiveMessage       Id = '2'
+	StartFileNegativeMessage       Id = '3'
+	DataExchangeBufferMessage      Id = 'D'
+	EndFile                        Id = 'T'
+	EndFilePositiveMessage         Id = '4'
+	EndFileNegativeMessage         Id = '5'
+	EndSessionMessage              Id = 'F'
+	EndToEndResponseMessage        Id = 'E'
+	NegativeEndResponseMessage     Id = 'N'
+	SecurityChangeDirectionMessage Id = 'J'
+	AuthenticationChallengeMessage Id = 'A'
+	AuthenticationResponseMessage  Id = 'S'
+	ChangeDirectionMessage         Id = 'R'
+	SetCreditMessage               Id = 'C'
+	ReadyToReceiveMessage          Id = 'P'
+	Unknown                        Id = '0'
 )
 
 func (i Id) Byte() byte {
@@ -39,10 +51,24 @@ func (i Id) String() string {
 }
 
 var KnownIds = map[Id]struct{}{
-	StartSessionReadyMessage: {},
-	StartSessionMessage:      {},
-	StartFilePositiveMessage: {},
-	StartFileNegativeMessage: {},
+	StartSessionReadyMessage:       {},
+	StartSessionMessage:            {},
+	StartFile:                      {},
+	StartFilePositiveMessage:       {},
+	StartFileNegativeMessage:       {},
+	DataExchangeBufferMessage:      {},
+	EndFile:                        {},
+	EndFilePositiveMessage:         {},
+	EndFileNegativeMessage:         {},
+	EndSessionMessage:              {},
+	EndToEndResponseMessage:        {},
+	NegativeEndResponseMessage:     {},
+	SecurityChangeDirectionMessage: {},
+	AuthenticationChallengeMessage: {},
+	AuthenticationResponseMessage:  {},
+	ChangeDirectionMessage:         {},
+	SetCreditMessage:               {},
+	ReadyToReceiveMessage:          {},
 }
 
 func (c Command) Cmd() Id {
@@ -39,6 +39,18 @@ func TestCommandCmd(t *testing.T) {
 			},
 			expectedCmd: oftp2.StartSessionMessage,
 		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return validStartFile(t)
+			},
+			expectedCmd: oftp2.StartFile,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return oftp2.NewDataExchangeBuffer([]byte("DATA"))
+			},
+			expectedCmd: oftp2.DataExchangeBufferMessage,
+		},
 		{
 			cmd: func(t *testing.T) oftp2.Command {
 				return validStartFilePositive(t)
@@ -51,6 +63,78 @@ func TestCommandCmd(t *testing.T) {
 			},
 			expectedCmd: oftp2.StartFileNegativeMessage,
 		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return validEndFile(t)
+			},
+			expectedCmd: oftp2.EndFile,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return oftp2.NewEndFilePositiveAnswer(false)
+			},
+			expectedCmd: oftp2.EndFilePositiveMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return validEndFileNegative(t)
+			},
+			expectedCmd: oftp2.EndFileNegativeMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return validEndSession(t)
+			},
+			expectedCmd: oftp2.EndSessionMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return validEndToEndResponse(t)
+			},
+			expectedCmd: oftp2.EndToEndResponseMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return validNegativeEndResponse(t)
+			},
+			expectedCmd: oftp2.NegativeEndResponseMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return oftp2.NewSecurityChangeDirection()
+			},
+			expectedCmd: oftp2.SecurityChangeDirectionMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return validAuthenticationChallenge(t)
+			},
+			expectedCmd: oftp2.AuthenticationChallengeMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return validAuthenticationResponse(t)
+			},
+			expectedCmd: oftp2.AuthenticationResponseMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return oftp2.NewChangeDirection()
+			},
+			expectedCmd: oftp2.ChangeDirectionMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return oftp2.NewSetCredit()
+			},
+			expectedCmd: oftp2.SetCreditMessage,
+		},
+		{
+			cmd: func(t *testing.T) oftp2.Command {
+				return oftp2.NewReadyToReceive()
+			},
+			expectedCmd: oftp2.ReadyToReceiveMessage,
+		},
 		{
 			cmd: func(t *testing.T) oftp2.Command {
 				return []byte{}
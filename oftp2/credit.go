@@ -0,0 +1,49 @@
+package oftp2
+
+import "fmt"
+
+// CreditWindow counts the DATA buffers that may be sent before the listener
+// has to grant new credit with CDT (https://datatracker.ietf.org/doc/html/rfc5024#section-3.4.3).
+// The speaker consumes the window before sending a buffer and waits for CDT once it is exhausted,
+// the listener consumes it for every received buffer and answers with CDT once it is exhausted.
+type CreditWindow struct {
+	credit    int
+	remaining int
+}
+
+func NewCreditWindow(credit int) (*CreditWindow, error) {
+	if credit < 1 || credit > 999 {
+		return nil, fmt.Errorf("invalid credit: %d", credit)
+	}
+	return &CreditWindow{
+		credit:    credit,
+		remaining: credit,
+	}, nil
+}
+
+// Consume takes one DATA buffer from the window.
+// It fails with a protocol violation when no credit is left.
+func (w *CreditWindow) Consume() error {
+	if w.remaining == 0 {
+		return NewEndSessionError(EndSessionProtocolViolation, fmt.Errorf("credit of %d buffers exceeded", w.credit))
+	}
+	w.remaining--
+	return nil
+}
+
+func (w *CreditWindow) Exhausted() bool {
+	return w.remaining == 0
+}
+
+func (w *CreditWindow) Remaining() int {
+	return w.remaining
+}
+
+func (w *CreditWindow) Credit() int {
+	return w.credit
+}
+
+// Reset restores the full credit after CDT.
+func (w *CreditWindow) Reset() {
+	w.remaining = w.credit
+}
@@ -0,0 +1,67 @@
+package oftp2_test
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewCreditWindow(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  int
+		expect func(t *testing.T, w *oftp2.CreditWindow, err error)
+	}{
+		{
+			with:  "a standard credit",
+			input: 2,
+			expect: func(t *testing.T, w *oftp2.CreditWindow, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 2, w.Credit())
+				require.Equal(t, 2, w.Remaining())
+			},
+		},
+		{
+			with:  "no credit",
+			input: 0,
+			expect: func(t *testing.T, w *oftp2.CreditWindow, err error) {
+				require.EqualError(t, err, "invalid credit: 0")
+				require.Nil(t, w)
+			},
+		},
+		{
+			with:  "an exceeding credit",
+			input: 1000,
+			expect: func(t *testing.T, w *oftp2.CreditWindow, err error) {
+				require.EqualError(t, err, "invalid credit: 1000")
+				require.Nil(t, w)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			w, err := oftp2.NewCreditWindow(scenario.input)
+			scenario.expect(t, w, err)
+		})
+	}
+}
+
+func TestCreditWindow(t *testing.T) {
+	w, err := oftp2.NewCreditWindow(2)
+	require.NoError(t, err)
+
+	require.NoError(t, w.Consume())
+	require.False(t, w.Exhausted())
+	require.NoError(t, w.Consume())
+	require.True(t, w.Exhausted())
+
+	err = w.Consume()
+	require.EqualError(t, err, "credit of 2 buffers exceeded")
+	var endSessionErr oftp2.EndSessionError
+	require.True(t, errors.As(err, &endSessionErr))
+	require.Equal(t, oftp2.EndSessionProtocolViolation, endSessionErr.Reason)
+
+	w.Reset()
+	require.Equal(t, 2, w.Remaining())
+	require.NoError(t, w.Consume())
+}
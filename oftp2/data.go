@@ -20,12 +20,22 @@ func NewDataExchangeBuffer(data []byte) Command {
 }
 
 func (c DataExchangeBuffer) Valid() error {
-	if DataExchangeBufferMessage.Byte() != c[0] {
+	if l := len(c); l < 1 {
+		return NewInvalidLengthError(1, l)
+	} else if DataExchangeBufferMessage.Byte() != c[0] {
 		return NewInvalidPrefixError(DataExchangeBufferMessage.String(), string(c[0]))
 	}
 	return nil
 }
 
+func (c DataExchangeBuffer) Id() Id {
+	return DataExchangeBufferMessage
+}
+
+func (c DataExchangeBuffer) Encode() Command {
+	return Command(c)
+}
+
 func (c DataExchangeBuffer) Payload() []byte {
 	return c[1:]
 }
@@ -0,0 +1,192 @@
+package oftp2
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// o-------------------------------------------------------------------o
+// |       EERP        End to End Response                             |
+// |                                                                   |
+// |       Start File Phase           Speaker ----> Listener           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | EERPCMD   | EERP Command, 'E'                     | F X(1)  |
+// |   1 | EERPDSN   | Virtual File Dataset Name             | V X(26) |
+// |  27 | EERPRSV1  | Reserved                              | F X(3)  |
+// |  30 | EERPDATE  | Virtual File Date stamp, (CCYYMMDD)   | V 9(8)  |
+// |  38 | EERPTIME  | Virtual File Time stamp, (HHMMSScccc) | V 9(10) |
+// |  48 | EERPUSER  | User Data                             | V X(8)  |
+// |  56 | EERPDEST  | Destination                           | V X(25) |
+// |  81 | EERPORIG  | Originator                            | V X(25) |
+// | 106 | EERPHSHL  | Virtual File hash length              | V 9(2)  |
+// | 108 | EERPHSH   | Virtual File hash                     | V U(n)  |
+// |     | EERPSIGL  | EERP signature length                 | V 9(3)  |
+// |     | EERPSIG   | EERP signature                        | V U(n)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.13
+
+type EndToEndResponseCmd []byte
+
+func (c EndToEndResponseCmd) Valid() error {
+	fixLength := 112 // prefix + CR
+	if length := len(c); length < fixLength {
+		return NewInvalidLengthError(fixLength, length)
+	} else if EndToEndResponseMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(EndToEndResponseMessage.String(), string(c[0]))
+	} else if _, err := NewTimeStamp(c[30:48]); err != nil {
+		return err
+	} else if err := c.Destination().Valid(); err != nil {
+		return err
+	} else if err := c.Origin().Valid(); err != nil {
+		return err
+	}
+	hashLength, err := strconv.Atoi(string(c[106:108]))
+	if err != nil {
+		return err
+	} else if hashLength < 0 {
+		return fmt.Errorf("invalid hash length: %d", hashLength)
+	} else if length := len(c); length < fixLength+hashLength {
+		return NewInvalidLengthError(fixLength+hashLength, length)
+	}
+	signatureLength, err := strconv.Atoi(string(c[108+hashLength : 111+hashLength]))
+	if err != nil {
+		return err
+	} else if signatureLength < 0 {
+		return fmt.Errorf("invalid signature length: %d", signatureLength)
+	}
+	totalLength := fixLength + hashLength + signatureLength
+	if length := len(c); length != totalLength {
+		return NewInvalidLengthError(totalLength, length)
+	} else if cmd := string(c[totalLength-1]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c EndToEndResponseCmd) Id() Id {
+	return EndToEndResponseMessage
+}
+
+func (c EndToEndResponseCmd) Encode() Command {
+	return Command(c)
+}
+
+func (c EndToEndResponseCmd) Name() string {
+	return strings.TrimSpace(string(c[1:27]))
+}
+
+func (c EndToEndResponseCmd) Date() Timestamp {
+	t, err := NewTimeStamp(c[30:48])
+	if err != nil {
+		log.Println(err)
+	}
+	return t
+}
+
+func (c EndToEndResponseCmd) UserData() []byte {
+	return c[48:56]
+}
+
+// Destination is the originator of the acknowledged virtual file.
+func (c EndToEndResponseCmd) Destination() Sid {
+	return Sid(c[56:81])
+}
+
+// Origin is the destination of the acknowledged virtual file.
+func (c EndToEndResponseCmd) Origin() Sid {
+	return Sid(c[81:106])
+}
+
+func (c EndToEndResponseCmd) Hash() []byte {
+	l, _ := strconv.Atoi(string(c[106:108]))
+	return c[108 : 108+l]
+}
+
+func (c EndToEndResponseCmd) Signature() []byte {
+	offset := 108 + len(c.Hash())
+	l, _ := strconv.Atoi(string(c[offset : offset+3]))
+	return c[offset+3 : offset+3+l]
+}
+
+// SignedContent is what the signature of the response covers, the fields from EERPDSN to EERPHSH.
+func (c EndToEndResponseCmd) SignedContent() []byte {
+	return c[1 : 108+len(c.Hash())]
+}
+
+// Matches reports whether the response acknowledges the virtual file started by sfid.
+func (c EndToEndResponseCmd) Matches(sfid StartFileCmd) bool {
+	return c.Name() == sfid.Name() &&
+		bytes.Equal(c[30:48], sfid[30:48]) &&
+		bytes.Equal(c.UserData(), sfid.UserData()) &&
+		bytes.Equal(c.Destination(), sfid.Origin()) &&
+		bytes.Equal(c.Origin(), sfid.Destination())
+}
+
+// EndToEndResponseInputFor prepares the response for the virtual file started by sfid.
+// Destination and Origin are swapped, as the response travels back to the originator.
+func EndToEndResponseInputFor(sfid StartFileCmd) EndToEndResponseInput {
+	return EndToEndResponseInput{
+		Name:        sfid.Name(),
+		Date:        sfid.Date(),
+		UserData:    sfid.UserData(),
+		Destination: sfid.Origin(),
+		Origin:      sfid.Destination(),
+	}
+}
+
+func NewEndToEndResponse(input EndToEndResponseInput) (Command, error) {
+	if len(input.Name) > 26 {
+		return nil, fmt.Errorf("name is too long: %v", input.Name)
+	} else if len(input.UserData) > 8 {
+		return nil, fmt.Errorf("user data is too long: %v", string(input.UserData))
+	} else if err := input.Destination.Valid(); err != nil {
+		return nil, err
+	} else if err := input.Origin.Valid(); err != nil {
+		return nil, err
+	} else if length := len(input.Hash); length > 99 {
+		return nil, fmt.Errorf("hash is too long: %d", length)
+	} else if length := len(input.Signature); length > 999 {
+		return nil, fmt.Errorf("signature is too long: %d", length)
+	}
+
+	name, err := fillUpString(input.Name, 26)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := fillUpString(string(input.UserData), 8)
+	if err != nil {
+		return nil, err
+	}
+	hashLength, _ := fillUpInt(len(input.Hash), 2)
+	signatureLength, _ := fillUpInt(len(input.Signature), 3)
+
+	return Command(
+		string(EndToEndResponseMessage) +
+			name +
+			reserved(3) +
+			input.Date.ToString() +
+			userData +
+			string(input.Destination) +
+			string(input.Origin) +
+			hashLength +
+			string(input.Hash) +
+			signatureLength +
+			string(input.Signature) +
+			CarriageReturn), nil
+}
+
+type EndToEndResponseInput struct {
+	Name        string
+	Date        Timestamp
+	UserData    []byte
+	Destination Sid
+	Origin      Sid
+	Hash        []byte
+	Signature   []byte
+}
@@ -0,0 +1,262 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestEndToEndResponse(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) oftp2.EndToEndResponseInput
+		expect func(t *testing.T, cmd oftp2.Command, err error)
+	}{
+		{
+			with:  "a standard input",
+			input: validEndToEndResponseInput,
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Len(t, cmd, 112+3+9)
+			},
+		},
+		{
+			with: "an exceeding filename",
+			input: func(t *testing.T) oftp2.EndToEndResponseInput {
+				i := validEndToEndResponseInput(t)
+				i.Name = "123456789101112131415161718"
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "name is too long: 123456789101112131415161718")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an exceeding user data",
+			input: func(t *testing.T) oftp2.EndToEndResponseInput {
+				i := validEndToEndResponseInput(t)
+				i.UserData = []byte("123456789")
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "user data is too long: 123456789")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an invalid destination",
+			input: func(t *testing.T) oftp2.EndToEndResponseInput {
+				i := validEndToEndResponseInput(t)
+				i.Destination = []byte("!")
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "expected the length of 25, but got 1")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an invalid origin",
+			input: func(t *testing.T) oftp2.EndToEndResponseInput {
+				i := validEndToEndResponseInput(t)
+				i.Origin = []byte("!")
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "expected the length of 25, but got 1")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an exceeding hash",
+			input: func(t *testing.T) oftp2.EndToEndResponseInput {
+				i := validEndToEndResponseInput(t)
+				i.Hash = []byte(generateLongString(100))
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "hash is too long: 100")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an exceeding signature",
+			input: func(t *testing.T) oftp2.EndToEndResponseInput {
+				i := validEndToEndResponseInput(t)
+				i.Signature = []byte(generateLongString(1000))
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "signature is too long: 1000")
+				require.Nil(t, cmd)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			s, err := oftp2.NewEndToEndResponse(scenario.input(t))
+			scenario.expect(t, s, err)
+		})
+	}
+}
+
+func TestEndToEndResponse_Valid(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		expect func(t *testing.T, eerp oftp2.EndToEndResponseCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func(t *testing.T) []byte {
+				return validEndToEndResponse(t)
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.NoError(t, eerp.Valid())
+				sfid := oftp2.StartFileCmd(validStartFile(t))
+				require.Equal(t, "MY_FILE", eerp.Name())
+				require.Equal(t, sfid.Date(), eerp.Date())
+				require.Equal(t, []byte("        "), eerp.UserData())
+				require.Equal(t, sfid.Origin(), eerp.Destination())
+				require.Equal(t, sfid.Destination(), eerp.Origin())
+				require.Equal(t, []byte("HSH"), eerp.Hash())
+				require.Equal(t, []byte("SIGNATURE"), eerp.Signature())
+				require.True(t, eerp.Matches(sfid))
+				require.Equal(t, []byte(eerp[1:111]), eerp.SignedContent())
+				require.True(t, strings.HasSuffix(string(eerp.SignedContent()), "03HSH"))
+			},
+		},
+		{
+			with: "an empty hash and signature",
+			input: func(t *testing.T) []byte {
+				i := validEndToEndResponseInput(t)
+				i.Hash = nil
+				i.Signature = nil
+				eerp, err := oftp2.NewEndToEndResponse(i)
+				require.NoError(t, err)
+				return eerp
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.NoError(t, eerp.Valid())
+				require.Empty(t, eerp.Hash())
+				require.Empty(t, eerp.Signature())
+			},
+		},
+		{
+			with: "a different file",
+			input: func(t *testing.T) []byte {
+				i := validEndToEndResponseInput(t)
+				i.Name = "OTHER_FILE"
+				eerp, err := oftp2.NewEndToEndResponse(i)
+				require.NoError(t, err)
+				return eerp
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.NoError(t, eerp.Valid())
+				require.False(t, eerp.Matches(oftp2.StartFileCmd(validStartFile(t))))
+			},
+		},
+		{
+			with: "a wrong cmd type",
+			input: func(t *testing.T) []byte {
+				p := validEndToEndResponse(t)
+				p[0] = '^'
+				return p
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.EqualError(t, eerp.Valid(), "does not start with E, but with ^")
+			},
+		},
+		{
+			with: "a truncated message",
+			input: func(t *testing.T) []byte {
+				return validEndToEndResponse(t)[:100]
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.EqualError(t, eerp.Valid(), "expected the length of 112, but got 100")
+			},
+		},
+		{
+			with: "a wrong length",
+			input: func(t *testing.T) []byte {
+				return append(validEndToEndResponse(t), ' ')
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.EqualError(t, eerp.Valid(), "expected the length of 124, but got 125")
+			},
+		},
+		{
+			with: "an invalid destination",
+			input: func(t *testing.T) []byte {
+				p := validEndToEndResponse(t)
+				p[56] = '^'
+				return p
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.EqualError(t, eerp.Valid(), "does not start with O, but with ^")
+			},
+		},
+		{
+			with: "a corrupted hash length",
+			input: func(t *testing.T) []byte {
+				p := validEndToEndResponse(t)
+				p[106] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.EqualError(t, eerp.Valid(), `strconv.Atoi: parsing "d3": invalid syntax`)
+			},
+		},
+		{
+			with: "an exceeding hash length",
+			input: func(t *testing.T) []byte {
+				p := validEndToEndResponse(t)
+				p[106] = '9'
+				return p
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.EqualError(t, eerp.Valid(), "expected the length of 205, but got 124")
+			},
+		},
+		{
+			with: "a corrupted signature length",
+			input: func(t *testing.T) []byte {
+				p := validEndToEndResponse(t)
+				p[111] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.EqualError(t, eerp.Valid(), `strconv.Atoi: parsing "d09": invalid syntax`)
+			},
+		},
+		{
+			with: "missing carriage return",
+			input: func(t *testing.T) []byte {
+				p := validEndToEndResponse(t)
+				p[len(p)-1] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, eerp oftp2.EndToEndResponseCmd) {
+				require.EqualError(t, eerp.Valid(), "does not end on carriage return, but on d")
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input(t))
+		})
+	}
+}
+
+func validEndToEndResponse(t *testing.T) oftp2.Command {
+	eerp, err := oftp2.NewEndToEndResponse(validEndToEndResponseInput(t))
+	require.NoError(t, err)
+	return eerp
+}
+
+func validEndToEndResponseInput(t *testing.T) oftp2.EndToEndResponseInput {
+	input := oftp2.EndToEndResponseInputFor(oftp2.StartFileCmd(validStartFile(t)))
+	input.Hash = []byte("HSH")
+	input.Signature = []byte("SIGNATURE")
+	return input
+}
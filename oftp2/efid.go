@@ -0,0 +1,85 @@
+package oftp2
+
+import (
+	"errors"
+	"strconv"
+)
+
+// o-------------------------------------------------------------------o
+// |       EFID        End File                                        |
+// |                                                                   |
+// |       End File Phase             Speaker ----> Listener           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | EFIDCMD   | EFID Command, 'T'                     | F X(1)  |
+// |   1 | EFIDRCNT  | Record Count                          | V 9(17) |
+// |  18 | EFIDUCNT  | Unit Count                            | V 9(17) |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.8
+
+type EndFileCmd []byte
+
+func (c EndFileCmd) Valid() error {
+	if l := len(c); l != 36 {
+		return NewInvalidLengthError(36, l)
+	} else if EndFile.Byte() != c[0] {
+		return NewInvalidPrefixError(EndFile.String(), string(c[0]))
+	} else if cmd := string(c[35]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	} else if val, err := strconv.ParseInt(string(c[1:18]), 10, 64); err != nil {
+		return err
+	} else if val < 0 {
+		return errors.New("record count can't be negative")
+	} else if val, err := strconv.ParseInt(string(c[18:35]), 10, 64); err != nil {
+		return err
+	} else if val < 0 {
+		return errors.New("unit count can't be negative")
+	}
+	return nil
+}
+
+func (c EndFileCmd) Id() Id {
+	return EndFile
+}
+
+func (c EndFileCmd) Encode() Command {
+	return Command(c)
+}
+
+func (c EndFileCmd) RecordCount() int64 {
+	i, _ := strconv.ParseInt(string(c[1:18]), 10, 64)
+	return i
+}
+
+func (c EndFileCmd) UnitCount() int64 {
+	i, _ := strconv.ParseInt(string(c[18:35]), 10, 64)
+	return i
+}
+
+func NewEndFile(input EndFileInput) (Command, error) {
+	if input.RecordCount < 0 {
+		return nil, errors.New("record count can't be negative")
+	} else if input.UnitCount < 0 {
+		return nil, errors.New("unit count can't be negative")
+	}
+	records, err := fillUpInt(int(input.RecordCount), 17)
+	if err != nil {
+		return nil, err
+	}
+	units, err := fillUpInt(int(input.UnitCount), 17)
+	if err != nil {
+		return nil, err
+	}
+	return Command(
+		string(EndFile) +
+			records +
+			units +
+			CarriageReturn), nil
+}
+
+type EndFileInput struct {
+	RecordCount int64
+	UnitCount   int64
+}
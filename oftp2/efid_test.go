@@ -0,0 +1,184 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEndFile(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  oftp2.EndFileInput
+		expect func(t *testing.T, cmd oftp2.Command, err error)
+	}{
+		{
+			with: "a standard input",
+			input: oftp2.EndFileInput{
+				RecordCount: 1,
+				UnitCount:   2,
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "T0000000000000000100000000000000002\r", string(cmd))
+			},
+		},
+		{
+			with: "a negative record count",
+			input: oftp2.EndFileInput{
+				RecordCount: -1,
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "record count can't be negative")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "a negative unit count",
+			input: oftp2.EndFileInput{
+				UnitCount: -1,
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "unit count can't be negative")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an exceeding record count",
+			input: oftp2.EndFileInput{
+				RecordCount: 100000000000000000,
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "exceeded capacity: 100000000000000000 (17)")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an exceeding unit count",
+			input: oftp2.EndFileInput{
+				UnitCount: 100000000000000000,
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "exceeded capacity: 100000000000000000 (17)")
+				require.Nil(t, cmd)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			cmd, err := oftp2.NewEndFile(scenario.input)
+			scenario.expect(t, cmd, err)
+		})
+	}
+}
+
+func TestEndFile_Valid(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		expect func(t *testing.T, efid oftp2.EndFileCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func(t *testing.T) []byte {
+				return validEndFile(t)
+			},
+			expect: func(t *testing.T, efid oftp2.EndFileCmd) {
+				require.NoError(t, efid.Valid())
+				require.Equal(t, int64(10), efid.RecordCount())
+				require.Equal(t, int64(100), efid.UnitCount())
+			},
+		},
+		{
+			with: "a wrong cmd type",
+			input: func(t *testing.T) []byte {
+				p := validEndFile(t)
+				p[0] = '^'
+				return p
+			},
+			expect: func(t *testing.T, efid oftp2.EndFileCmd) {
+				require.EqualError(t, efid.Valid(), "does not start with T, but with ^")
+			},
+		},
+		{
+			with: "a wrong length",
+			input: func(t *testing.T) []byte {
+				return append(validEndFile(t), ' ')
+			},
+			expect: func(t *testing.T, efid oftp2.EndFileCmd) {
+				require.EqualError(t, efid.Valid(), "expected the length of 36, but got 37")
+			},
+		},
+		{
+			with: "missing carriage return",
+			input: func(t *testing.T) []byte {
+				p := validEndFile(t)
+				p[len(p)-1] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, efid oftp2.EndFileCmd) {
+				require.EqualError(t, efid.Valid(), "does not end on carriage return, but on d")
+			},
+		},
+		{
+			with: "corrupted record count",
+			input: func(t *testing.T) []byte {
+				p := validEndFile(t)
+				p[3] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, efid oftp2.EndFileCmd) {
+				require.EqualError(t, efid.Valid(), `strconv.ParseInt: parsing "00d00000000000010": invalid syntax`)
+				require.Equal(t, int64(0), efid.RecordCount())
+			},
+		},
+		{
+			with: "negative record count",
+			input: func(t *testing.T) []byte {
+				p := validEndFile(t)
+				p[1] = '-'
+				return p
+			},
+			expect: func(t *testing.T, efid oftp2.EndFileCmd) {
+				require.EqualError(t, efid.Valid(), "record count can't be negative")
+				require.Equal(t, int64(-10), efid.RecordCount())
+			},
+		},
+		{
+			with: "corrupted unit count",
+			input: func(t *testing.T) []byte {
+				p := validEndFile(t)
+				p[20] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, efid oftp2.EndFileCmd) {
+				require.EqualError(t, efid.Valid(), `strconv.ParseInt: parsing "00d00000000000100": invalid syntax`)
+				require.Equal(t, int64(0), efid.UnitCount())
+			},
+		},
+		{
+			with: "negative unit count",
+			input: func(t *testing.T) []byte {
+				p := validEndFile(t)
+				p[18] = '-'
+				return p
+			},
+			expect: func(t *testing.T, efid oftp2.EndFileCmd) {
+				require.EqualError(t, efid.Valid(), "unit count can't be negative")
+				require.Equal(t, int64(-100), efid.UnitCount())
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input(t))
+		})
+	}
+}
+
+func validEndFile(t *testing.T) oftp2.Command {
+	file, err := oftp2.NewEndFile(oftp2.EndFileInput{
+		RecordCount: 10,
+		UnitCount:   100,
+	})
+	require.NoError(t, err)
+	return file
+}
@@ -0,0 +1,137 @@
+package oftp2
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// o-------------------------------------------------------------------o
+// |       EFNA        End File Negative Answer                        |
+// |                                                                   |
+// |       End File Phase             Speaker <---- Listener           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | EFNACMD   | EFNA Command, '5'                     | F X(1)  |
+// |   1 | EFNAREAS  | Answer Reason                         | F 9(2)  |
+// |   3 | EFNAREASL | Answer Reason Text Length             | V 9(3)  |
+// |   6 | EFNAREAST | Answer Reason Text                    | V T(n)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.10
+
+type EndFileNegativeAnswerCmd []byte
+
+func (c EndFileNegativeAnswerCmd) Valid() error {
+	fixLength := 7 // prefix + CR
+	if length := len(c); length < fixLength {
+		return NewInvalidLengthError(fixLength, length)
+	}
+	variableLength, err := strconv.Atoi(string(c[3:6]))
+	if err != nil {
+		return err
+	}
+	totalLength := fixLength + variableLength
+	if length := len(c); length != totalLength {
+		return NewInvalidLengthError(totalLength, length)
+	} else if EndFileNegativeMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(EndFileNegativeMessage.String(), string(c[0]))
+	} else if _, exists := KnownEndFileAnswerReasons[c.ReasonCode()]; !exists {
+		return fmt.Errorf("invalid reason code")
+	} else if cmd := string(c[totalLength-1]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c EndFileNegativeAnswerCmd) Id() Id {
+	return EndFileNegativeMessage
+}
+
+func (c EndFileNegativeAnswerCmd) Encode() Command {
+	return Command(c)
+}
+
+func (c EndFileNegativeAnswerCmd) ReasonCode() EndFileAnswerReason {
+	i, _ := strconv.Atoi(string(c[1:3]))
+	return EndFileAnswerReason(i)
+}
+
+func (c EndFileNegativeAnswerCmd) ReasonText() string {
+	return string(c[6 : len(c)-1])
+}
+
+func NewEndFileNegativeAnswer(input NegativeEndFileInput) (Command, error) {
+	if _, exists := KnownEndFileAnswerReasons[input.Reason]; !exists {
+		return nil, fmt.Errorf("unknown answer reason: %d", input.Reason)
+	}
+	length := len(input.ReasonText)
+	if length > 999 {
+		return nil, fmt.Errorf("reason text is too long: %d", length)
+	}
+	r, _ := fillUpInt(int(input.Reason), 2)
+	l, _ := fillUpInt(length, 3)
+
+	return Command(
+		string(EndFileNegativeMessage) +
+			r +
+			l +
+			input.ReasonText +
+			CarriageReturn,
+	), nil
+}
+
+type NegativeEndFileInput struct {
+	Reason     EndFileAnswerReason
+	ReasonText string
+}
+
+type EndFileAnswerReason int
+
+var KnownEndFileAnswerReasons = map[EndFileAnswerReason]struct{}{
+	EndFileAnswerInvalidFilename:                 {},
+	EndFileAnswerInvalidDestination:              {},
+	EndFileAnswerInvalidOrigin:                   {},
+	EndFileAnswerStorageRecordFormatNotSupported: {},
+	EndFileAnswerMaximumRecordLengthNotSupported: {},
+	EndFileAnswerFilesizeTooBig:                  {},
+	EndFileAnswerInvalidRecordCount:              {},
+	EndFileAnswerInvalidByteCount:                {},
+	EndFileAnswerAccessMethodFailure:             {},
+	EndFileAnswerNonUniqueFile:                   {},
+	EndFileAnswerFileDirectionRefused:            {},
+	EndFileAnswerCipherSuiteNotSupported:         {},
+	EndFileAnswerEncryptedFileNotAllowed:         {},
+	EndFileAnswerUnencryptedFileNotAllowed:       {},
+	EndFileAnswerCompressionNotAllowed:           {},
+	EndFileAnswerSignedFileNotAllowed:            {},
+	EndFileAnswerUnsignedFileNotAllowed:          {},
+	EndFileAnswerInvalidFileSignature:            {},
+	EndFileAnswerFileDecryptionFailure:           {},
+	EndFileAnswerFileDecompressionFailure:        {},
+	EndFileAnswerUnspecified:                     {},
+}
+
+const (
+	EndFileAnswerInvalidFilename                 EndFileAnswerReason = 01
+	EndFileAnswerInvalidDestination              EndFileAnswerReason = 02
+	EndFileAnswerInvalidOrigin                   EndFileAnswerReason = 03
+	EndFileAnswerStorageRecordFormatNotSupported EndFileAnswerReason = 04
+	EndFileAnswerMaximumRecordLengthNotSupported EndFileAnswerReason = 05
+	EndFileAnswerFilesizeTooBig                  EndFileAnswerReason = 06
+	EndFileAnswerInvalidRecordCount              EndFileAnswerReason = 10
+	EndFileAnswerInvalidByteCount                EndFileAnswerReason = 11
+	EndFileAnswerAccessMethodFailure             EndFileAnswerReason = 12
+	EndFileAnswerNonUniqueFile                   EndFileAnswerReason = 13
+	EndFileAnswerFileDirectionRefused            EndFileAnswerReason = 14
+	EndFileAnswerCipherSuiteNotSupported         EndFileAnswerReason = 15
+	EndFileAnswerEncryptedFileNotAllowed         EndFileAnswerReason = 16
+	EndFileAnswerUnencryptedFileNotAllowed       EndFileAnswerReason = 17
+	EndFileAnswerCompressionNotAllowed           EndFileAnswerReason = 18
+	EndFileAnswerSignedFileNotAllowed            EndFileAnswerReason = 19
+	EndFileAnswerUnsignedFileNotAllowed          EndFileAnswerReason = 20
+	EndFileAnswerInvalidFileSignature            EndFileAnswerReason = 21
+	EndFileAnswerFileDecryptionFailure           EndFileAnswerReason = 22
+	EndFileAnswerFileDecompressionFailure        EndFileAnswerReason = 23
+	EndFileAnswerUnspecified                     EndFileAnswerReason = 99
+)
@@ -0,0 +1,155 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEndFileNegativeAnswer(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  oftp2.NegativeEndFileInput
+		expect func(t *testing.T, cmd oftp2.Command, err error)
+	}{
+		{
+			with: "a standard input",
+			input: oftp2.NegativeEndFileInput{
+				Reason:     oftp2.EndFileAnswerInvalidRecordCount,
+				ReasonText: "BECAUSE",
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "510007BECAUSE\r", string(cmd))
+			},
+		},
+		{
+			with: "an unknown reasonCode",
+			input: oftp2.NegativeEndFileInput{
+				Reason: 98,
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "unknown answer reason: 98")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "a reason text that is too long",
+			input: oftp2.NegativeEndFileInput{
+				Reason:     oftp2.EndFileAnswerUnspecified,
+				ReasonText: generateLongString(1000),
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "reason text is too long: 1000")
+				require.Nil(t, cmd)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			s, err := oftp2.NewEndFileNegativeAnswer(scenario.input)
+			scenario.expect(t, s, err)
+		})
+	}
+}
+
+func TestEndFileNegativeAnswer_Valid(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		expect func(t *testing.T, efna oftp2.EndFileNegativeAnswerCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func(t *testing.T) []byte {
+				file, err := oftp2.NewEndFileNegativeAnswer(oftp2.NegativeEndFileInput{
+					Reason:     oftp2.EndFileAnswerNonUniqueFile,
+					ReasonText: "MY_TEXT",
+				})
+				require.NoError(t, err)
+				return file
+			},
+			expect: func(t *testing.T, efna oftp2.EndFileNegativeAnswerCmd) {
+				require.NoError(t, efna.Valid())
+				require.Equal(t, oftp2.EndFileAnswerNonUniqueFile, efna.ReasonCode())
+				require.Equal(t, "MY_TEXT", efna.ReasonText())
+			},
+		},
+		{
+			with: "a wrong cmd type",
+			input: func(t *testing.T) []byte {
+				p := validEndFileNegative(t)
+				p[0] = '^'
+				return p
+			},
+			expect: func(t *testing.T, efna oftp2.EndFileNegativeAnswerCmd) {
+				require.EqualError(t, efna.Valid(), "does not start with 5, but with ^")
+				require.Equal(t, oftp2.EndFileAnswerInvalidByteCount, efna.ReasonCode())
+			},
+		},
+		{
+			with: "a wrong length",
+			input: func(t *testing.T) []byte {
+				return append(validEndFileNegative(t), ' ')
+			},
+			expect: func(t *testing.T, efna oftp2.EndFileNegativeAnswerCmd) {
+				require.EqualError(t, efna.Valid(), "expected the length of 7, but got 8")
+			},
+		},
+		{
+			with: "a truncated message",
+			input: func(t *testing.T) []byte {
+				return validEndFileNegative(t)[:3]
+			},
+			expect: func(t *testing.T, efna oftp2.EndFileNegativeAnswerCmd) {
+				require.EqualError(t, efna.Valid(), "expected the length of 7, but got 3")
+			},
+		},
+		{
+			with: "missing carriage return",
+			input: func(t *testing.T) []byte {
+				p := validEndFileNegative(t)
+				p[len(p)-1] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, efna oftp2.EndFileNegativeAnswerCmd) {
+				require.EqualError(t, efna.Valid(), "does not end on carriage return, but on d")
+			},
+		},
+		{
+			with: "unknown reason code",
+			input: func(t *testing.T) []byte {
+				p := validEndFileNegative(t)
+				p[1] = '9'
+				p[2] = '8'
+				return p
+			},
+			expect: func(t *testing.T, efna oftp2.EndFileNegativeAnswerCmd) {
+				require.EqualError(t, efna.Valid(), "invalid reason code")
+				require.Equal(t, oftp2.EndFileAnswerReason(98), efna.ReasonCode())
+			},
+		},
+		{
+			with: "corrupted reason length",
+			input: func(t *testing.T) []byte {
+				p := validEndFileNegative(t)
+				p[4] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, efna oftp2.EndFileNegativeAnswerCmd) {
+				require.EqualError(t, efna.Valid(), `strconv.Atoi: parsing "0d0": invalid syntax`)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input(t))
+		})
+	}
+}
+
+func validEndFileNegative(t *testing.T) oftp2.Command {
+	file, err := oftp2.NewEndFileNegativeAnswer(oftp2.NegativeEndFileInput{
+		Reason: oftp2.EndFileAnswerInvalidByteCount,
+	})
+	require.NoError(t, err)
+	return file
+}
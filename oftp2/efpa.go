@@ -0,0 +1,51 @@
+package oftp2
+
+import "fmt"
+
+// o-------------------------------------------------------------------o
+// |       EFPA        End File Positive Answer                        |
+// |                                                                   |
+// |       End File Phase             Speaker <---- Listener           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | EFPACMD   | EFPA Command, '4'                     | F X(1)  |
+// |   1 | EFPACD    | Change Direction Indicator, (Y/N)     | F X(1)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.9
+
+type EndFilePositiveAnswerCmd []byte
+
+func (c EndFilePositiveAnswerCmd) Valid() error {
+	if l := len(c); l != 3 {
+		return NewInvalidLengthError(3, l)
+	} else if EndFilePositiveMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(EndFilePositiveMessage.String(), string(c[0]))
+	} else if cd := string(c[1]); !isBool(cd) {
+		return fmt.Errorf("invalid change direction indicator: %s", cd)
+	} else if cmd := string(c[2]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c EndFilePositiveAnswerCmd) Id() Id {
+	return EndFilePositiveMessage
+}
+
+func (c EndFilePositiveAnswerCmd) Encode() Command {
+	return Command(c)
+}
+
+// ChangeDirection reports whether the listener asks to become the speaker.
+func (c EndFilePositiveAnswerCmd) ChangeDirection() bool {
+	return c[1] == 'Y'
+}
+
+func NewEndFilePositiveAnswer(changeDirection bool) Command {
+	return Command(
+		string(EndFilePositiveMessage) +
+			boolToString(changeDirection) +
+			CarriageReturn)
+}
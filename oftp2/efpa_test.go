@@ -0,0 +1,78 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEndFilePositiveAnswer(t *testing.T) {
+	require.Equal(t, "4Y\r", string(oftp2.NewEndFilePositiveAnswer(true)))
+	require.Equal(t, "4N\r", string(oftp2.NewEndFilePositiveAnswer(false)))
+}
+
+func TestEndFilePositiveAnswer_Valid(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func() []byte
+		expect func(t *testing.T, efpa oftp2.EndFilePositiveAnswerCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func() []byte {
+				return oftp2.NewEndFilePositiveAnswer(true)
+			},
+			expect: func(t *testing.T, efpa oftp2.EndFilePositiveAnswerCmd) {
+				require.NoError(t, efpa.Valid())
+				require.True(t, efpa.ChangeDirection())
+			},
+		},
+		{
+			with: "a wrong cmd type",
+			input: func() []byte {
+				p := oftp2.NewEndFilePositiveAnswer(true)
+				p[0] = '^'
+				return p
+			},
+			expect: func(t *testing.T, efpa oftp2.EndFilePositiveAnswerCmd) {
+				require.EqualError(t, efpa.Valid(), "does not start with 4, but with ^")
+			},
+		},
+		{
+			with: "a wrong length",
+			input: func() []byte {
+				return append(oftp2.NewEndFilePositiveAnswer(true), ' ')
+			},
+			expect: func(t *testing.T, efpa oftp2.EndFilePositiveAnswerCmd) {
+				require.EqualError(t, efpa.Valid(), "expected the length of 3, but got 4")
+			},
+		},
+		{
+			with: "an unknown change direction indicator",
+			input: func() []byte {
+				p := oftp2.NewEndFilePositiveAnswer(true)
+				p[1] = 'U'
+				return p
+			},
+			expect: func(t *testing.T, efpa oftp2.EndFilePositiveAnswerCmd) {
+				require.EqualError(t, efpa.Valid(), "invalid change direction indicator: U")
+				require.False(t, efpa.ChangeDirection())
+			},
+		},
+		{
+			with: "missing carriage return",
+			input: func() []byte {
+				p := oftp2.NewEndFilePositiveAnswer(false)
+				p[2] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, efpa oftp2.EndFilePositiveAnswerCmd) {
+				require.EqualError(t, efpa.Valid(), "does not end on carriage return, but on d")
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input())
+		})
+	}
+}
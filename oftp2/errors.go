@@ -53,3 +53,68 @@ type InvalidPrefixError struct {
 func (i InvalidPrefixError) Error() string {
 	return fmt.Sprintf("does not start with %v, but with %v", i.expectedPrefix, i.isPrefix)
 }
+
+func NewEndSessionError(reason EndSessionReason, err error) EndSessionError {
+	return EndSessionError{
+		Reason: reason,
+		err:    err,
+	}
+}
+
+// EndSessionError is an error that ends the session with the given ESID reason.
+type EndSessionError struct {
+	Reason EndSessionReason
+	err    error
+}
+
+func (e EndSessionError) Error() string {
+	return e.err.Error()
+}
+
+func (e EndSessionError) Unwrap() error {
+	return e.err
+}
+
+func NewStartFileError(reason AnswerReason, err error) StartFileError {
+	return StartFileError{
+		Reason: reason,
+		err:    err,
+	}
+}
+
+// StartFileError is an error that refuses a file with the given SFNA reason.
+type StartFileError struct {
+	Reason AnswerReason
+	// Retry tells the partner to send the file again later.
+	Retry bool
+	err   error
+}
+
+func (e StartFileError) Error() string {
+	return e.err.Error()
+}
+
+func (e StartFileError) Unwrap() error {
+	return e.err
+}
+
+func NewEndFileError(reason EndFileAnswerReason, err error) EndFileError {
+	return EndFileError{
+		Reason: reason,
+		err:    err,
+	}
+}
+
+// EndFileError is an error that refuses a received file with the given EFNA reason.
+type EndFileError struct {
+	Reason EndFileAnswerReason
+	err    error
+}
+
+func (e EndFileError) Error() string {
+	return e.err.Error()
+}
+
+func (e EndFileError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,126 @@
+package oftp2
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// o-------------------------------------------------------------------o
+// |       ESID        End Session                                     |
+// |                                                                   |
+// |       End Session Phase          Speaker ----> Listener           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | ESIDCMD   | ESID Command, 'F'                     | F X(1)  |
+// |   1 | ESIDREAS  | Reason Code                           | F 9(2)  |
+// |   3 | ESIDREASL | Reason Text Length                    | V 9(3)  |
+// |   6 | ESIDREAST | Reason Text                           | V T(n)  |
+// |     | ESIDCR    | Carriage Return                       | F X(1)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.11
+
+type EndSessionCmd []byte
+
+func (c EndSessionCmd) Valid() error {
+	fixLength := 7 // prefix + CR
+	if length := len(c); length < fixLength {
+		return NewInvalidLengthError(fixLength, length)
+	}
+	variableLength, err := strconv.Atoi(string(c[3:6]))
+	if err != nil {
+		return err
+	}
+	totalLength := fixLength + variableLength
+	if length := len(c); length != totalLength {
+		return NewInvalidLengthError(totalLength, length)
+	} else if EndSessionMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(EndSessionMessage.String(), string(c[0]))
+	} else if _, err := strconv.Atoi(string(c[1:3])); err != nil {
+		return fmt.Errorf("invalid reason code")
+	} else if _, exists := KnownEndSessionReasons[c.ReasonCode()]; !exists {
+		return fmt.Errorf("invalid reason code")
+	} else if cmd := string(c[totalLength-1]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c EndSessionCmd) Id() Id {
+	return EndSessionMessage
+}
+
+func (c EndSessionCmd) Encode() Command {
+	return Command(c)
+}
+
+func (c EndSessionCmd) ReasonCode() EndSessionReason {
+	i, _ := strconv.Atoi(string(c[1:3]))
+	return EndSessionReason(i)
+}
+
+func (c EndSessionCmd) ReasonText() string {
+	return string(c[6 : len(c)-1])
+}
+
+func NewEndSession(input EndSessionInput) (Command, error) {
+	if _, exists := KnownEndSessionReasons[input.Reason]; !exists {
+		return nil, fmt.Errorf("unknown end session reason: %d", input.Reason)
+	}
+	length := len(input.ReasonText)
+	if length > 999 {
+		return nil, fmt.Errorf("reason text is too long: %d", length)
+	}
+	r, _ := fillUpInt(int(input.Reason), 2)
+	l, _ := fillUpInt(length, 3)
+
+	return Command(
+		string(EndSessionMessage) +
+			r +
+			l +
+			input.ReasonText +
+			CarriageReturn,
+	), nil
+}
+
+type EndSessionInput struct {
+	Reason     EndSessionReason
+	ReasonText string
+}
+
+type EndSessionReason int
+
+var KnownEndSessionReasons = map[EndSessionReason]struct{}{
+	EndSessionNormalTermination:                {},
+	EndSessionCommandNotRecognised:             {},
+	EndSessionProtocolViolation:                {},
+	EndSessionUserCodeNotKnown:                 {},
+	EndSessionInvalidPassword:                  {},
+	EndSessionLocalSiteEmergencyCloseDown:      {},
+	EndSessionCommandContainedInvalidData:      {},
+	EndSessionExchangeBufferSizeError:          {},
+	EndSessionResourcesNotAvailable:            {},
+	EndSessionTimeOut:                          {},
+	EndSessionModeOrCapabilitiesIncompatible:   {},
+	EndSessionInvalidChallengeResponse:         {},
+	EndSessionSecureAuthenticationIncompatible: {},
+	EndSessionUnspecified:                      {},
+}
+
+const (
+	EndSessionNormalTermination                EndSessionReason = 00
+	EndSessionCommandNotRecognised             EndSessionReason = 01
+	EndSessionProtocolViolation                EndSessionReason = 02
+	EndSessionUserCodeNotKnown                 EndSessionReason = 03
+	EndSessionInvalidPassword                  EndSessionReason = 04
+	EndSessionLocalSiteEmergencyCloseDown      EndSessionReason = 05
+	EndSessionCommandContainedInvalidData      EndSessionReason = 06
+	EndSessionExchangeBufferSizeError          EndSessionReason = 07
+	EndSessionResourcesNotAvailable            EndSessionReason = 8
+	EndSessionTimeOut                          EndSessionReason = 9
+	EndSessionModeOrCapabilitiesIncompatible   EndSessionReason = 10
+	EndSessionInvalidChallengeResponse         EndSessionReason = 11
+	EndSessionSecureAuthenticationIncompatible EndSessionReason = 12
+	EndSessionUnspecified                      EndSessionReason = 99
+)
@@ -0,0 +1,182 @@
+package oftp2_test
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEndSession(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  oftp2.EndSessionInput
+		expect func(t *testing.T, cmd oftp2.Command, err error)
+	}{
+		{
+			with: "a normal termination",
+			input: oftp2.EndSessionInput{
+				Reason: oftp2.EndSessionNormalTermination,
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "F00000\r", string(cmd))
+			},
+		},
+		{
+			with: "a reason text",
+			input: oftp2.EndSessionInput{
+				Reason:     oftp2.EndSessionInvalidPassword,
+				ReasonText: "BECAUSE",
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "F04007BECAUSE\r", string(cmd))
+			},
+		},
+		{
+			with: "an unknown reasonCode",
+			input: oftp2.EndSessionInput{
+				Reason: 13,
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "unknown end session reason: 13")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "a reason text that is too long",
+			input: oftp2.EndSessionInput{
+				Reason:     oftp2.EndSessionUnspecified,
+				ReasonText: generateLongString(1000),
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "reason text is too long: 1000")
+				require.Nil(t, cmd)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			s, err := oftp2.NewEndSession(scenario.input)
+			scenario.expect(t, s, err)
+		})
+	}
+}
+
+func TestEndSession_Valid(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		expect func(t *testing.T, esid oftp2.EndSessionCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func(t *testing.T) []byte {
+				return validEndSession(t)
+			},
+			expect: func(t *testing.T, esid oftp2.EndSessionCmd) {
+				require.NoError(t, esid.Valid())
+				require.Equal(t, oftp2.EndSessionModeOrCapabilitiesIncompatible, esid.ReasonCode())
+				require.Equal(t, "MY_TEXT", esid.ReasonText())
+			},
+		},
+		{
+			with: "a wrong cmd type",
+			input: func(t *testing.T) []byte {
+				p := validEndSession(t)
+				p[0] = '^'
+				return p
+			},
+			expect: func(t *testing.T, esid oftp2.EndSessionCmd) {
+				require.EqualError(t, esid.Valid(), "does not start with F, but with ^")
+			},
+		},
+		{
+			with: "a wrong length",
+			input: func(t *testing.T) []byte {
+				return append(validEndSession(t), ' ')
+			},
+			expect: func(t *testing.T, esid oftp2.EndSessionCmd) {
+				require.EqualError(t, esid.Valid(), "expected the length of 14, but got 15")
+			},
+		},
+		{
+			with: "a truncated message",
+			input: func(t *testing.T) []byte {
+				return validEndSession(t)[:4]
+			},
+			expect: func(t *testing.T, esid oftp2.EndSessionCmd) {
+				require.EqualError(t, esid.Valid(), "expected the length of 7, but got 4")
+			},
+		},
+		{
+			with: "missing carriage return",
+			input: func(t *testing.T) []byte {
+				p := validEndSession(t)
+				p[len(p)-1] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, esid oftp2.EndSessionCmd) {
+				require.EqualError(t, esid.Valid(), "does not end on carriage return, but on d")
+			},
+		},
+		{
+			with: "corrupted reason code",
+			input: func(t *testing.T) []byte {
+				p := validEndSession(t)
+				p[2] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, esid oftp2.EndSessionCmd) {
+				require.EqualError(t, esid.Valid(), "invalid reason code")
+			},
+		},
+		{
+			with: "unknown reason code",
+			input: func(t *testing.T) []byte {
+				p := validEndSession(t)
+				p[2] = '3'
+				return p
+			},
+			expect: func(t *testing.T, esid oftp2.EndSessionCmd) {
+				require.EqualError(t, esid.Valid(), "invalid reason code")
+				require.Equal(t, oftp2.EndSessionReason(13), esid.ReasonCode())
+			},
+		},
+		{
+			with: "corrupted reason length",
+			input: func(t *testing.T) []byte {
+				p := validEndSession(t)
+				p[4] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, esid oftp2.EndSessionCmd) {
+				require.EqualError(t, esid.Valid(), `strconv.Atoi: parsing "0d7": invalid syntax`)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input(t))
+		})
+	}
+}
+
+func TestEndSessionError(t *testing.T) {
+	session := validSessionStart(t)
+	session[1] = '4'
+	err := oftp2.StartSessionCmd(session).Valid()
+	require.EqualError(t, err, "invalid protocol level: 52")
+
+	var endSessionErr oftp2.EndSessionError
+	require.True(t, errors.As(err, &endSessionErr))
+	require.Equal(t, oftp2.EndSessionModeOrCapabilitiesIncompatible, endSessionErr.Reason)
+}
+
+func validEndSession(t *testing.T) oftp2.Command {
+	esid, err := oftp2.NewEndSession(oftp2.EndSessionInput{
+		Reason:     oftp2.EndSessionModeOrCapabilitiesIncompatible,
+		ReasonText: "MY_TEXT",
+	})
+	require.NoError(t, err)
+	return esid
+}
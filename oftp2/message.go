@@ -0,0 +1,55 @@
+package oftp2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Message is a decoded command of any type.
+type Message interface {
+	Id() Id
+	Valid() error
+	Encode() Command
+}
+
+var decoders = map[Id]func(c []byte) Message{
+	StartSessionReadyMessage:       func(c []byte) Message { return StartSessionReadyMessageCmd(c) },
+	StartSessionMessage:            func(c []byte) Message { return StartSessionCmd(c) },
+	StartFile:                      func(c []byte) Message { return StartFileCmd(c) },
+	StartFilePositiveMessage:       func(c []byte) Message { return StartFilePositiveAnswerCmd(c) },
+	StartFileNegativeMessage:       func(c []byte) Message { return StartFileNegativeAnswerCmd(c) },
+	DataExchangeBufferMessage:      func(c []byte) Message { return DataExchangeBuffer(c) },
+	EndFile:                        func(c []byte) Message { return EndFileCmd(c) },
+	EndFilePositiveMessage:         func(c []byte) Message { return EndFilePositiveAnswerCmd(c) },
+	EndFileNegativeMessage:         func(c []byte) Message { return EndFileNegativeAnswerCmd(c) },
+	EndSessionMessage:              func(c []byte) Message { return EndSessionCmd(c) },
+	EndToEndResponseMessage:        func(c []byte) Message { return EndToEndResponseCmd(c) },
+	NegativeEndResponseMessage:     func(c []byte) Message { return NegativeEndResponseCmd(c) },
+	SecurityChangeDirectionMessage: func(c []byte) Message { return SecurityChangeDirectionCmd(c) },
+	AuthenticationChallengeMessage: func(c []byte) Message { return AuthenticationChallengeCmd(c) },
+	AuthenticationResponseMessage:  func(c []byte) Message { return AuthenticationResponseCmd(c) },
+	ChangeDirectionMessage:         func(c []byte) Message { return ChangeDirectionCmd(c) },
+	SetCreditMessage:               func(c []byte) Message { return SetCreditCmd(c) },
+	ReadyToReceiveMessage:          func(c []byte) Message { return ReadyToReceiveCmd(c) },
+}
+
+// Decode turns a received command into its typed message and validates it.
+// Errors are EndSessionErrors carrying the ESID reason for the partner.
+func Decode(c []byte) (Message, error) {
+	if len(c) == 0 {
+		return nil, NewEndSessionError(EndSessionCommandNotRecognised, errors.New("empty command"))
+	}
+	decode, exists := decoders[Id(c[0])]
+	if !exists {
+		return nil, NewEndSessionError(EndSessionCommandNotRecognised, fmt.Errorf("unknown command: %v", string(c[0])))
+	}
+	message := decode(c)
+	if err := message.Valid(); err != nil {
+		var endSessionErr EndSessionError
+		if errors.As(err, &endSessionErr) {
+			return nil, err
+		}
+		return nil, NewEndSessionError(EndSessionCommandContainedInvalidData, err)
+	}
+	return message, nil
+}
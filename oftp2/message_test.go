@@ -0,0 +1,171 @@
+package oftp2_test
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		expect oftp2.Message
+	}{
+		{
+			with:   "SSRM",
+			input:  func(t *testing.T) []byte { return oftp2.NewStartSessionReadyMessage() },
+			expect: oftp2.StartSessionReadyMessageCmd{},
+		},
+		{
+			with:   "SSID",
+			input:  func(t *testing.T) []byte { return validSessionStart(t) },
+			expect: oftp2.StartSessionCmd{},
+		},
+		{
+			with:   "SFID",
+			input:  func(t *testing.T) []byte { return validStartFile(t) },
+			expect: oftp2.StartFileCmd{},
+		},
+		{
+			with:   "SFPA",
+			input:  func(t *testing.T) []byte { return validStartFilePositive(t) },
+			expect: oftp2.StartFilePositiveAnswerCmd{},
+		},
+		{
+			with:   "SFNA",
+			input:  func(t *testing.T) []byte { return validStartFileNegative(t) },
+			expect: oftp2.StartFileNegativeAnswerCmd{},
+		},
+		{
+			with:   "DATA",
+			input:  func(t *testing.T) []byte { return oftp2.NewDataExchangeBuffer([]byte("DATA")) },
+			expect: oftp2.DataExchangeBuffer{},
+		},
+		{
+			with:   "EFID",
+			input:  func(t *testing.T) []byte { return validEndFile(t) },
+			expect: oftp2.EndFileCmd{},
+		},
+		{
+			with:   "EFPA",
+			input:  func(t *testing.T) []byte { return oftp2.NewEndFilePositiveAnswer(true) },
+			expect: oftp2.EndFilePositiveAnswerCmd{},
+		},
+		{
+			with:   "EFNA",
+			input:  func(t *testing.T) []byte { return validEndFileNegative(t) },
+			expect: oftp2.EndFileNegativeAnswerCmd{},
+		},
+		{
+			with:   "ESID",
+			input:  func(t *testing.T) []byte { return validEndSession(t) },
+			expect: oftp2.EndSessionCmd{},
+		},
+		{
+			with:   "EERP",
+			input:  func(t *testing.T) []byte { return validEndToEndResponse(t) },
+			expect: oftp2.EndToEndResponseCmd{},
+		},
+		{
+			with:   "NERP",
+			input:  func(t *testing.T) []byte { return validNegativeEndResponse(t) },
+			expect: oftp2.NegativeEndResponseCmd{},
+		},
+		{
+			with:   "SECD",
+			input:  func(t *testing.T) []byte { return oftp2.NewSecurityChangeDirection() },
+			expect: oftp2.SecurityChangeDirectionCmd{},
+		},
+		{
+			with:   "AUCH",
+			input:  func(t *testing.T) []byte { return validAuthenticationChallenge(t) },
+			expect: oftp2.AuthenticationChallengeCmd{},
+		},
+		{
+			with:   "AURP",
+			input:  func(t *testing.T) []byte { return validAuthenticationResponse(t) },
+			expect: oftp2.AuthenticationResponseCmd{},
+		},
+		{
+			with:   "CD",
+			input:  func(t *testing.T) []byte { return oftp2.NewChangeDirection() },
+			expect: oftp2.ChangeDirectionCmd{},
+		},
+		{
+			with:   "CDT",
+			input:  func(t *testing.T) []byte { return oftp2.NewSetCredit() },
+			expect: oftp2.SetCreditCmd{},
+		},
+		{
+			with:   "RTR",
+			input:  func(t *testing.T) []byte { return oftp2.NewReadyToReceive() },
+			expect: oftp2.ReadyToReceiveCmd{},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			input := scenario.input(t)
+			msg, err := oftp2.Decode(input)
+			require.NoError(t, err)
+			require.IsType(t, scenario.expect, msg)
+			require.Equal(t, oftp2.Id(input[0]), msg.Id())
+			require.Equal(t, oftp2.Command(input), msg.Encode())
+		})
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		error  string
+		reason oftp2.EndSessionReason
+	}{
+		{
+			with:   "an empty command",
+			input:  func(t *testing.T) []byte { return []byte{} },
+			error:  "empty command",
+			reason: oftp2.EndSessionCommandNotRecognised,
+		},
+		{
+			with:   "an unknown command",
+			input:  func(t *testing.T) []byte { return []byte("O") },
+			error:  "unknown command: O",
+			reason: oftp2.EndSessionCommandNotRecognised,
+		},
+		{
+			with: "an invalid command",
+			input: func(t *testing.T) []byte {
+				return append(oftp2.NewChangeDirection(), ' ')
+			},
+			error:  "expected the length of 2, but got 3",
+			reason: oftp2.EndSessionCommandContainedInvalidData,
+		},
+		{
+			with:   "a truncated command",
+			input:  func(t *testing.T) []byte { return []byte("3") },
+			error:  "expected the length of 8, but got 1",
+			reason: oftp2.EndSessionCommandContainedInvalidData,
+		},
+		{
+			with: "an invalid buffer size",
+			input: func(t *testing.T) []byte {
+				session := validSessionStart(t)
+				session[35] = 'x'
+				return session
+			},
+			error:  `invalid DataExchangeBufferSize: strconv.Atoi: parsing "x9999": invalid syntax`,
+			reason: oftp2.EndSessionExchangeBufferSizeError,
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			msg, err := oftp2.Decode(scenario.input(t))
+			require.EqualError(t, err, scenario.error)
+			require.Nil(t, msg)
+			var endSessionErr oftp2.EndSessionError
+			require.True(t, errors.As(err, &endSessionErr))
+			require.Equal(t, scenario.reason, endSessionErr.Reason)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package oftp2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NegotiatedSession is what both sides agreed on in their SSIDs, seen from the local side.
+type NegotiatedSession struct {
+	// DataExchangeBufferSize and Credit are the lower ones of both sides.
+	DataExchangeBufferSize int
+	Credit                 int
+	// BufferCompression, Restart, SpecialLogic and SecureAuthentication apply only if both sides asked for them.
+	BufferCompression    bool
+	Restart              bool
+	SpecialLogic         bool
+	SecureAuthentication bool
+	// Send and Receive tell whether the local side may send or receive files.
+	Send    bool
+	Receive bool
+}
+
+// Negotiate agrees on the parameters of a session from the SSID of the local side and the SSID of the remote side.
+// The responder answers with the negotiated parameters, so that the initiator gets the same result from both SSIDs.
+// Sides that can't agree are returned as EndSessionError with the reason to end the session.
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.2
+func Negotiate(local, remote StartSessionCmd) (NegotiatedSession, error) {
+	l, r := local.Capabilities(), remote.Capabilities()
+	if (l == CapabilitySend && r == CapabilitySend) || (l == CapabilityReceive && r == CapabilityReceive) {
+		return NegotiatedSession{}, NewEndSessionError(EndSessionModeOrCapabilitiesIncompatible, fmt.Errorf("capabilities %v and %v are incompatible", l, r))
+	} else if local.Authentication() != remote.Authentication() {
+		return NegotiatedSession{}, NewEndSessionError(EndSessionSecureAuthenticationIncompatible, errors.New("secure authentication wasn't agreed"))
+	} else if credit := lower(local.Credit(), remote.Credit()); credit < 1 {
+		// without credit no side could ever send a data buffer
+		return NegotiatedSession{}, NewEndSessionError(EndSessionCommandContainedInvalidData, fmt.Errorf("invalid credit: %d", credit))
+	}
+	return NegotiatedSession{
+		DataExchangeBufferSize: lower(local.DataExchangeBufferSize(), remote.DataExchangeBufferSize()),
+		Credit:                 lower(local.Credit(), remote.Credit()),
+		BufferCompression:      local.BufferCompression() && remote.BufferCompression(),
+		Restart:                local.Restart() && remote.Restart(),
+		SpecialLogic:           local.SpecialLogic() && remote.SpecialLogic(),
+		SecureAuthentication:   local.Authentication(),
+		Send:                   l != CapabilityReceive && r != CapabilitySend,
+		Receive:                l != CapabilitySend && r != CapabilityReceive,
+	}, nil
+}
+
+// Capabilities are the capabilities of the local side in the session, which the responder answers with.
+func (n NegotiatedSession) Capabilities() SsidCapability {
+	switch {
+	case n.Send && !n.Receive:
+		return CapabilitySend
+	case n.Receive && !n.Send:
+		return CapabilityReceive
+	}
+	return CapabilityBoth
+}
+
+func lower(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,165 @@
+package oftp2_test
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		local  func(i *oftp2.StartSessionInput)
+		remote func(i *oftp2.StartSessionInput)
+		expect oftp2.NegotiatedSession
+	}{
+		{
+			with:   "equal offers",
+			local:  func(i *oftp2.StartSessionInput) {},
+			remote: func(i *oftp2.StartSessionInput) {},
+			expect: oftp2.NegotiatedSession{
+				DataExchangeBufferSize: 99999,
+				Credit:                 999,
+				BufferCompression:      true,
+				Restart:                true,
+				SpecialLogic:           true,
+				SecureAuthentication:   true,
+				Send:                   true,
+				Receive:                true,
+			},
+		},
+		{
+			with: "lower values of the remote side",
+			local: func(i *oftp2.StartSessionInput) {
+				i.BufferCompression = false
+				i.SpecialLogic = false
+			},
+			remote: func(i *oftp2.StartSessionInput) {
+				i.DataExchangeBufferSize = 128
+				i.Credit = 1
+				i.Restart = false
+			},
+			expect: oftp2.NegotiatedSession{
+				DataExchangeBufferSize: 128,
+				Credit:                 1,
+				SecureAuthentication:   true,
+				Send:                   true,
+				Receive:                true,
+			},
+		},
+		{
+			with:  "a remote side, that only sends",
+			local: func(i *oftp2.StartSessionInput) {},
+			remote: func(i *oftp2.StartSessionInput) {
+				i.Capabilities = oftp2.CapabilitySend
+			},
+			expect: oftp2.NegotiatedSession{
+				DataExchangeBufferSize: 99999,
+				Credit:                 999,
+				BufferCompression:      true,
+				Restart:                true,
+				SpecialLogic:           true,
+				SecureAuthentication:   true,
+				Receive:                true,
+			},
+		},
+		{
+			with: "a local side, that only sends",
+			local: func(i *oftp2.StartSessionInput) {
+				i.Capabilities = oftp2.CapabilitySend
+			},
+			remote: func(i *oftp2.StartSessionInput) {
+				i.Capabilities = oftp2.CapabilityReceive
+			},
+			expect: oftp2.NegotiatedSession{
+				DataExchangeBufferSize: 99999,
+				Credit:                 999,
+				BufferCompression:      true,
+				Restart:                true,
+				SpecialLogic:           true,
+				SecureAuthentication:   true,
+				Send:                   true,
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			negotiated, err := oftp2.Negotiate(sessionStartWith(t, scenario.local), sessionStartWith(t, scenario.remote))
+			require.NoError(t, err)
+			require.Equal(t, scenario.expect, negotiated)
+		})
+	}
+}
+
+func TestNegotiate_Errors(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		local  func(i *oftp2.StartSessionInput)
+		remote func(i *oftp2.StartSessionInput)
+		reason oftp2.EndSessionReason
+		error  string
+	}{
+		{
+			with:   "both sides only sending",
+			local:  func(i *oftp2.StartSessionInput) { i.Capabilities = oftp2.CapabilitySend },
+			remote: func(i *oftp2.StartSessionInput) { i.Capabilities = oftp2.CapabilitySend },
+			reason: oftp2.EndSessionModeOrCapabilitiesIncompatible,
+			error:  "capabilities S and S are incompatible",
+		},
+		{
+			with:   "both sides only receiving",
+			local:  func(i *oftp2.StartSessionInput) { i.Capabilities = oftp2.CapabilityReceive },
+			remote: func(i *oftp2.StartSessionInput) { i.Capabilities = oftp2.CapabilityReceive },
+			reason: oftp2.EndSessionModeOrCapabilitiesIncompatible,
+			error:  "capabilities R and R are incompatible",
+		},
+		{
+			with:   "secure authentication on one side",
+			local:  func(i *oftp2.StartSessionInput) {},
+			remote: func(i *oftp2.StartSessionInput) { i.SecureAuthentication = false },
+			reason: oftp2.EndSessionSecureAuthenticationIncompatible,
+			error:  "secure authentication wasn't agreed",
+		},
+		{
+			with:   "no credit on one side",
+			local:  func(i *oftp2.StartSessionInput) {},
+			remote: func(i *oftp2.StartSessionInput) { i.Credit = 0 },
+			reason: oftp2.EndSessionCommandContainedInvalidData,
+			error:  "invalid credit: 0",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			_, err := oftp2.Negotiate(sessionStartWith(t, scenario.local), sessionStartWith(t, scenario.remote))
+			require.EqualError(t, err, scenario.error)
+			var endSessionErr oftp2.EndSessionError
+			require.True(t, errors.As(err, &endSessionErr))
+			require.Equal(t, scenario.reason, endSessionErr.Reason)
+		})
+	}
+}
+
+func TestNegotiatedSession_Capabilities(t *testing.T) {
+	require.Equal(t, oftp2.CapabilityBoth, oftp2.NegotiatedSession{Send: true, Receive: true}.Capabilities())
+	require.Equal(t, oftp2.CapabilitySend, oftp2.NegotiatedSession{Send: true}.Capabilities())
+	require.Equal(t, oftp2.CapabilityReceive, oftp2.NegotiatedSession{Receive: true}.Capabilities())
+}
+
+func sessionStartWith(t *testing.T, change func(i *oftp2.StartSessionInput)) oftp2.StartSessionCmd {
+	t.Helper()
+	input := oftp2.StartSessionInput{
+		IdentificationCode:     validSsidCode(t),
+		Password:               "password",
+		DataExchangeBufferSize: 99999,
+		Capabilities:           oftp2.CapabilityBoth,
+		BufferCompression:      true,
+		Restart:                true,
+		SpecialLogic:           true,
+		Credit:                 999,
+		SecureAuthentication:   true,
+		UserData:               "        ",
+	}
+	change(&input)
+	ssid, err := oftp2.NewStartSession(input)
+	require.NoError(t, err)
+	return oftp2.StartSessionCmd(ssid)
+}
@@ -0,0 +1,289 @@
+package oftp2
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// o-------------------------------------------------------------------o
+// |       NERP        Negative End Response                           |
+// |                                                                   |
+// |       Start File Phase           Speaker ----> Listener           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | NERPCMD   | NERP Command, 'N'                     | F X(1)  |
+// |   1 | NERPDSN   | Virtual File Dataset Name             | V X(26) |
+// |  27 | NERPRSV1  | Reserved                              | F X(6)  |
+// |  33 | NERPDATE  | Virtual File Date stamp, (CCYYMMDD)   | V 9(8)  |
+// |  41 | NERPTIME  | Virtual File Time stamp, (HHMMSScccc) | V 9(10) |
+// |  51 | NERPDEST  | Destination                           | V X(25) |
+// |  76 | NERPORIG  | Originator                            | V X(25) |
+// | 101 | NERPCREA  | Creator of NERP                       | V X(25) |
+// | 126 | NERPREAS  | Reason code                           | F 9(2)  |
+// | 128 | NERPREASL | Reason text length                    | V 9(3)  |
+// | 131 | NERPREAST | Reason text                           | V T(n)  |
+// |     | NERPHSHL  | Virtual File hash length              | V 9(2)  |
+// |     | NERPHSH   | Virtual File hash                     | V U(n)  |
+// |     | NERPSIGL  | NERP signature length                 | V 9(3)  |
+// |     | NERPSIG   | NERP signature                        | V U(n)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.14
+
+type NegativeEndResponseCmd []byte
+
+func (c NegativeEndResponseCmd) Valid() error {
+	fixLength := 137 // prefix + CR
+	if length := len(c); length < fixLength {
+		return NewInvalidLengthError(fixLength, length)
+	} else if NegativeEndResponseMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(NegativeEndResponseMessage.String(), string(c[0]))
+	} else if _, err := NewTimeStamp(c[33:51]); err != nil {
+		return err
+	} else if err := c.Destination().Valid(); err != nil {
+		return err
+	} else if err := c.Origin().Valid(); err != nil {
+		return err
+	} else if err := c.Creator().Valid(); err != nil {
+		return err
+	} else if _, exists := KnownNegativeEndResponseReasons[c.ReasonCode()]; !exists {
+		return fmt.Errorf("invalid reason code")
+	}
+	textLength, err := strconv.Atoi(string(c[128:131]))
+	if err != nil {
+		return err
+	} else if textLength < 0 {
+		return fmt.Errorf("invalid reason text length: %d", textLength)
+	} else if length := len(c); length < fixLength+textLength {
+		return NewInvalidLengthError(fixLength+textLength, length)
+	}
+	offset := 131 + textLength
+	hashLength, err := strconv.Atoi(string(c[offset : offset+2]))
+	if err != nil {
+		return err
+	} else if hashLength < 0 {
+		return fmt.Errorf("invalid hash length: %d", hashLength)
+	} else if length := len(c); length < fixLength+textLength+hashLength {
+		return NewInvalidLengthError(fixLength+textLength+hashLength, length)
+	}
+	offset += 2 + hashLength
+	signatureLength, err := strconv.Atoi(string(c[offset : offset+3]))
+	if err != nil {
+		return err
+	} else if signatureLength < 0 {
+		return fmt.Errorf("invalid signature length: %d", signatureLength)
+	}
+	totalLength := fixLength + textLength + hashLength + signatureLength
+	if length := len(c); length != totalLength {
+		return NewInvalidLengthError(totalLength, length)
+	} else if cmd := string(c[totalLength-1]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c NegativeEndResponseCmd) Id() Id {
+	return NegativeEndResponseMessage
+}
+
+func (c NegativeEndResponseCmd) Encode() Command {
+	return Command(c)
+}
+
+func (c NegativeEndResponseCmd) Name() string {
+	return strings.TrimSpace(string(c[1:27]))
+}
+
+func (c NegativeEndResponseCmd) Date() Timestamp {
+	t, err := NewTimeStamp(c[33:51])
+	if err != nil {
+		log.Println(err)
+	}
+	return t
+}
+
+// Destination is the originator of the rejected virtual file.
+func (c NegativeEndResponseCmd) Destination() Sid {
+	return Sid(c[51:76])
+}
+
+// Origin is the destination of the rejected virtual file.
+func (c NegativeEndResponseCmd) Origin() Sid {
+	return Sid(c[76:101])
+}
+
+// Creator is the node that could not deliver the virtual file.
+func (c NegativeEndResponseCmd) Creator() Sid {
+	return Sid(c[101:126])
+}
+
+func (c NegativeEndResponseCmd) ReasonCode() NegativeEndResponseReason {
+	i, _ := strconv.Atoi(string(c[126:128]))
+	return NegativeEndResponseReason(i)
+}
+
+func (c NegativeEndResponseCmd) ReasonText() string {
+	l, _ := strconv.Atoi(string(c[128:131]))
+	return string(c[131 : 131+l])
+}
+
+func (c NegativeEndResponseCmd) Hash() []byte {
+	offset := 131 + len(c.ReasonText())
+	l, _ := strconv.Atoi(string(c[offset : offset+2]))
+	return c[offset+2 : offset+2+l]
+}
+
+func (c NegativeEndResponseCmd) Signature() []byte {
+	offset := 133 + len(c.ReasonText()) + len(c.Hash())
+	l, _ := strconv.Atoi(string(c[offset : offset+3]))
+	return c[offset+3 : offset+3+l]
+}
+
+// Matches reports whether the response rejects the virtual file started by sfid.
+func (c NegativeEndResponseCmd) Matches(sfid StartFileCmd) bool {
+	return c.Name() == sfid.Name() &&
+		bytes.Equal(c[33:51], sfid[30:48]) &&
+		bytes.Equal(c.Destination(), sfid.Origin()) &&
+		bytes.Equal(c.Origin(), sfid.Destination())
+}
+
+// NegativeEndResponseInputFor prepares the response for the virtual file started by sfid.
+// Destination and Origin are swapped, as the response travels back to the originator.
+func NegativeEndResponseInputFor(sfid StartFileCmd) NegativeEndResponseInput {
+	return NegativeEndResponseInput{
+		Name:        sfid.Name(),
+		Date:        sfid.Date(),
+		Destination: sfid.Origin(),
+		Origin:      sfid.Destination(),
+	}
+}
+
+func NewNegativeEndResponse(input NegativeEndResponseInput) (Command, error) {
+	if len(input.Name) > 26 {
+		return nil, fmt.Errorf("name is too long: %v", input.Name)
+	} else if err := input.Destination.Valid(); err != nil {
+		return nil, err
+	} else if err := input.Origin.Valid(); err != nil {
+		return nil, err
+	} else if err := input.Creator.Valid(); err != nil {
+		return nil, err
+	} else if _, exists := KnownNegativeEndResponseReasons[input.Reason]; !exists {
+		return nil, fmt.Errorf("unknown negative end response reason: %d", input.Reason)
+	} else if length := len(input.ReasonText); length > 999 {
+		return nil, fmt.Errorf("reason text is too long: %d", length)
+	} else if length := len(input.Hash); length > 99 {
+		return nil, fmt.Errorf("hash is too long: %d", length)
+	} else if length := len(input.Signature); length > 999 {
+		return nil, fmt.Errorf("signature is too long: %d", length)
+	}
+
+	name, err := fillUpString(input.Name, 26)
+	if err != nil {
+		return nil, err
+	}
+	reason, _ := fillUpInt(int(input.Reason), 2)
+	textLength, _ := fillUpInt(len(input.ReasonText), 3)
+	hashLength, _ := fillUpInt(len(input.Hash), 2)
+	signatureLength, _ := fillUpInt(len(input.Signature), 3)
+
+	return Command(
+		string(NegativeEndResponseMessage) +
+			name +
+			reserved(6) +
+			input.Date.ToString() +
+			string(input.Destination) +
+			string(input.Origin) +
+			string(input.Creator) +
+			reason +
+			textLength +
+			input.ReasonText +
+			hashLength +
+			string(input.Hash) +
+			signatureLength +
+			string(input.Signature) +
+			CarriageReturn), nil
+}
+
+type NegativeEndResponseInput struct {
+	Name        string
+	Date        Timestamp
+	Destination Sid
+	Origin      Sid
+	Creator     Sid
+	Reason      NegativeEndResponseReason
+	ReasonText  string
+	Hash        []byte
+	Signature   []byte
+}
+
+type NegativeEndResponseReason int
+
+var KnownNegativeEndResponseReasons = map[NegativeEndResponseReason]struct{}{
+	NegativeEndResponseUserCodeNotKnown:                {},
+	NegativeEndResponseInvalidPassword:                 {},
+	NegativeEndResponseSessionUnspecified:              {},
+	NegativeEndResponseInvalidFilename:                 {},
+	NegativeEndResponseInvalidDestination:              {},
+	NegativeEndResponseInvalidOrigin:                   {},
+	NegativeEndResponseStorageRecordFormatNotSupported: {},
+	NegativeEndResponseMaximumRecordLengthNotSupported: {},
+	NegativeEndResponseFilesizeTooBig:                  {},
+	NegativeEndResponseInvalidRecordCount:              {},
+	NegativeEndResponseInvalidByteCount:                {},
+	NegativeEndResponseAccessMethodFailure:             {},
+	NegativeEndResponseDuplicateFile:                   {},
+	NegativeEndResponseFileDirectionRefused:            {},
+	NegativeEndResponseCipherSuiteNotSupported:         {},
+	NegativeEndResponseEncryptedFileNotAllowed:         {},
+	NegativeEndResponseUnencryptedFileNotAllowed:       {},
+	NegativeEndResponseCompressionNotAllowed:           {},
+	NegativeEndResponseSignedFileNotAllowed:            {},
+	NegativeEndResponseUnsignedFileNotAllowed:          {},
+	NegativeEndResponseInvalidFileSignature:            {},
+	NegativeEndResponseFileDecompressionFailed:         {},
+	NegativeEndResponseFileDecryptionFailed:            {},
+	NegativeEndResponseFileProcessingFailed:            {},
+	NegativeEndResponseNotDelivered:                    {},
+	NegativeEndResponseNotAcknowledged:                 {},
+	NegativeEndResponseStoppedByOperator:               {},
+	NegativeEndResponseIncompatibleFileSize:            {},
+	NegativeEndResponseUnspecified:                     {},
+}
+
+// The reasons 11 to 30 report a SFNA (Retry=N) with the reason code minus 10
+// that was received from a node further down the route.
+const (
+	NegativeEndResponseUserCodeNotKnown                NegativeEndResponseReason = 03
+	NegativeEndResponseInvalidPassword                 NegativeEndResponseReason = 04
+	NegativeEndResponseSessionUnspecified              NegativeEndResponseReason = 9
+	NegativeEndResponseInvalidFilename                 NegativeEndResponseReason = 11
+	NegativeEndResponseInvalidDestination              NegativeEndResponseReason = 12
+	NegativeEndResponseInvalidOrigin                   NegativeEndResponseReason = 13
+	NegativeEndResponseStorageRecordFormatNotSupported NegativeEndResponseReason = 14
+	NegativeEndResponseMaximumRecordLengthNotSupported NegativeEndResponseReason = 15
+	NegativeEndResponseFilesizeTooBig                  NegativeEndResponseReason = 16
+	NegativeEndResponseInvalidRecordCount              NegativeEndResponseReason = 20
+	NegativeEndResponseInvalidByteCount                NegativeEndResponseReason = 21
+	NegativeEndResponseAccessMethodFailure             NegativeEndResponseReason = 22
+	NegativeEndResponseDuplicateFile                   NegativeEndResponseReason = 23
+	NegativeEndResponseFileDirectionRefused            NegativeEndResponseReason = 24
+	NegativeEndResponseCipherSuiteNotSupported         NegativeEndResponseReason = 25
+	NegativeEndResponseEncryptedFileNotAllowed         NegativeEndResponseReason = 26
+	NegativeEndResponseUnencryptedFileNotAllowed       NegativeEndResponseReason = 27
+	NegativeEndResponseCompressionNotAllowed           NegativeEndResponseReason = 28
+	NegativeEndResponseSignedFileNotAllowed            NegativeEndResponseReason = 29
+	NegativeEndResponseUnsignedFileNotAllowed          NegativeEndResponseReason = 30
+	NegativeEndResponseInvalidFileSignature            NegativeEndResponseReason = 31
+	NegativeEndResponseFileDecompressionFailed         NegativeEndResponseReason = 32
+	NegativeEndResponseFileDecryptionFailed            NegativeEndResponseReason = 33
+	NegativeEndResponseFileProcessingFailed            NegativeEndResponseReason = 34
+	NegativeEndResponseNotDelivered                    NegativeEndResponseReason = 35
+	NegativeEndResponseNotAcknowledged                 NegativeEndResponseReason = 36
+	NegativeEndResponseStoppedByOperator               NegativeEndResponseReason = 50
+	NegativeEndResponseIncompatibleFileSize            NegativeEndResponseReason = 90
+	NegativeEndResponseUnspecified                     NegativeEndResponseReason = 99
+)
@@ -0,0 +1,247 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNegativeEndResponse(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) oftp2.NegativeEndResponseInput
+		expect func(t *testing.T, cmd oftp2.Command, err error)
+	}{
+		{
+			with:  "a standard input",
+			input: validNegativeEndResponseInput,
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Len(t, cmd, 137+7+3+9)
+			},
+		},
+		{
+			with: "an exceeding filename",
+			input: func(t *testing.T) oftp2.NegativeEndResponseInput {
+				i := validNegativeEndResponseInput(t)
+				i.Name = "123456789101112131415161718"
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "name is too long: 123456789101112131415161718")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an invalid creator",
+			input: func(t *testing.T) oftp2.NegativeEndResponseInput {
+				i := validNegativeEndResponseInput(t)
+				i.Creator = []byte("!")
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "expected the length of 25, but got 1")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an unknown reason",
+			input: func(t *testing.T) oftp2.NegativeEndResponseInput {
+				i := validNegativeEndResponseInput(t)
+				i.Reason = 98
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "unknown negative end response reason: 98")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an exceeding reason text",
+			input: func(t *testing.T) oftp2.NegativeEndResponseInput {
+				i := validNegativeEndResponseInput(t)
+				i.ReasonText = generateLongString(1000)
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "reason text is too long: 1000")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an exceeding hash",
+			input: func(t *testing.T) oftp2.NegativeEndResponseInput {
+				i := validNegativeEndResponseInput(t)
+				i.Hash = []byte(generateLongString(100))
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "hash is too long: 100")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "an exceeding signature",
+			input: func(t *testing.T) oftp2.NegativeEndResponseInput {
+				i := validNegativeEndResponseInput(t)
+				i.Signature = []byte(generateLongString(1000))
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "signature is too long: 1000")
+				require.Nil(t, cmd)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			s, err := oftp2.NewNegativeEndResponse(scenario.input(t))
+			scenario.expect(t, s, err)
+		})
+	}
+}
+
+func TestNegativeEndResponse_Valid(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		expect func(t *testing.T, nerp oftp2.NegativeEndResponseCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func(t *testing.T) []byte {
+				return validNegativeEndResponse(t)
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.NoError(t, nerp.Valid())
+				sfid := oftp2.StartFileCmd(validStartFile(t))
+				require.Equal(t, "MY_FILE", nerp.Name())
+				require.Equal(t, sfid.Date(), nerp.Date())
+				require.Equal(t, sfid.Origin(), nerp.Destination())
+				require.Equal(t, sfid.Destination(), nerp.Origin())
+				require.Equal(t, sfid.Destination(), nerp.Creator())
+				require.Equal(t, oftp2.NegativeEndResponseFileDecryptionFailed, nerp.ReasonCode())
+				require.Equal(t, "BECAUSE", nerp.ReasonText())
+				require.Equal(t, []byte("HSH"), nerp.Hash())
+				require.Equal(t, []byte("SIGNATURE"), nerp.Signature())
+				require.True(t, nerp.Matches(sfid))
+			},
+		},
+		{
+			with: "a wrong cmd type",
+			input: func(t *testing.T) []byte {
+				p := validNegativeEndResponse(t)
+				p[0] = '^'
+				return p
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.EqualError(t, nerp.Valid(), "does not start with N, but with ^")
+			},
+		},
+		{
+			with: "a truncated message",
+			input: func(t *testing.T) []byte {
+				return validNegativeEndResponse(t)[:130]
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.EqualError(t, nerp.Valid(), "expected the length of 137, but got 130")
+			},
+		},
+		{
+			with: "a wrong length",
+			input: func(t *testing.T) []byte {
+				return append(validNegativeEndResponse(t), ' ')
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.EqualError(t, nerp.Valid(), "expected the length of 156, but got 157")
+			},
+		},
+		{
+			with: "an invalid creator",
+			input: func(t *testing.T) []byte {
+				p := validNegativeEndResponse(t)
+				p[101] = '^'
+				return p
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.EqualError(t, nerp.Valid(), "does not start with O, but with ^")
+			},
+		},
+		{
+			with: "an unknown reason code",
+			input: func(t *testing.T) []byte {
+				p := validNegativeEndResponse(t)
+				p[126] = '0'
+				p[127] = '1'
+				return p
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.EqualError(t, nerp.Valid(), "invalid reason code")
+			},
+		},
+		{
+			with: "a corrupted reason text length",
+			input: func(t *testing.T) []byte {
+				p := validNegativeEndResponse(t)
+				p[129] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.EqualError(t, nerp.Valid(), `strconv.Atoi: parsing "0d7": invalid syntax`)
+			},
+		},
+		{
+			with: "a corrupted hash length",
+			input: func(t *testing.T) []byte {
+				p := validNegativeEndResponse(t)
+				p[138] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.EqualError(t, nerp.Valid(), `strconv.Atoi: parsing "d3": invalid syntax`)
+			},
+		},
+		{
+			with: "a corrupted signature length",
+			input: func(t *testing.T) []byte {
+				p := validNegativeEndResponse(t)
+				p[143] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.EqualError(t, nerp.Valid(), `strconv.Atoi: parsing "d09": invalid syntax`)
+			},
+		},
+		{
+			with: "missing carriage return",
+			input: func(t *testing.T) []byte {
+				p := validNegativeEndResponse(t)
+				p[len(p)-1] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, nerp oftp2.NegativeEndResponseCmd) {
+				require.EqualError(t, nerp.Valid(), "does not end on carriage return, but on d")
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input(t))
+		})
+	}
+}
+
+func validNegativeEndResponse(t *testing.T) oftp2.Command {
+	nerp, err := oftp2.NewNegativeEndResponse(validNegativeEndResponseInput(t))
+	require.NoError(t, err)
+	return nerp
+}
+
+func validNegativeEndResponseInput(t *testing.T) oftp2.NegativeEndResponseInput {
+	sfid := oftp2.StartFileCmd(validStartFile(t))
+	input := oftp2.NegativeEndResponseInputFor(sfid)
+	input.Creator = sfid.Destination()
+	input.Reason = oftp2.NegativeEndResponseFileDecryptionFailed
+	input.ReasonText = "BECAUSE"
+	input.Hash = []byte("HSH")
+	input.Signature = []byte("SIGNATURE")
+	return input
+}
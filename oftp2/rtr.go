@@ -0,0 +1,39 @@
+package oftp2
+
+// o-------------------------------------------------------------------o
+// |       RTR         Ready To Receive                                |
+// |                                                                   |
+// |       Start File Phase           Speaker <---- Listener           |
+// |       End File Phase             Speaker <---- Listener           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | RTRCMD    | RTR Command, 'P'                      | F X(1)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.15
+
+type ReadyToReceiveCmd []byte
+
+func (c ReadyToReceiveCmd) Valid() error {
+	if l := len(c); l != 2 {
+		return NewInvalidLengthError(2, l)
+	} else if ReadyToReceiveMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(ReadyToReceiveMessage.String(), string(c[0]))
+	} else if cmd := string(c[1]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c ReadyToReceiveCmd) Id() Id {
+	return ReadyToReceiveMessage
+}
+
+func (c ReadyToReceiveCmd) Encode() Command {
+	return Command(c)
+}
+
+func NewReadyToReceive() Command {
+	return Command(string(ReadyToReceiveMessage) + CarriageReturn)
+}
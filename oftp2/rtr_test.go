@@ -0,0 +1,61 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestReadyToReceive(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func() []byte
+		expect func(t *testing.T, cmd oftp2.ReadyToReceiveCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func() []byte {
+				return oftp2.NewReadyToReceive()
+			},
+			expect: func(t *testing.T, cmd oftp2.ReadyToReceiveCmd) {
+				require.NoError(t, cmd.Valid())
+				require.Equal(t, "P\r", string(cmd))
+			},
+		},
+		{
+			with: "a wrong cmd id",
+			input: func() []byte {
+				m := oftp2.NewReadyToReceive()
+				m[0] = 'X'
+				return m
+			},
+			expect: func(t *testing.T, cmd oftp2.ReadyToReceiveCmd) {
+				require.EqualError(t, cmd.Valid(), "does not start with P, but with X")
+			},
+		},
+		{
+			with: "missing CR",
+			input: func() []byte {
+				m := oftp2.NewReadyToReceive()
+				m[len(m)-1] = '6'
+				return m
+			},
+			expect: func(t *testing.T, cmd oftp2.ReadyToReceiveCmd) {
+				require.EqualError(t, cmd.Valid(), "does not end on carriage return, but on 6")
+			},
+		},
+		{
+			with: "an exceeding message",
+			input: func() []byte {
+				return append(oftp2.NewReadyToReceive(), ' ')
+			},
+			expect: func(t *testing.T, cmd oftp2.ReadyToReceiveCmd) {
+				require.EqualError(t, cmd.Valid(), "expected the length of 2, but got 3")
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input())
+		})
+	}
+}
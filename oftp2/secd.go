@@ -0,0 +1,38 @@
+package oftp2
+
+// o-------------------------------------------------------------------o
+// |       SECD        Security Change Direction                       |
+// |                                                                   |
+// |       Start Session Phase     Initiator <---> Responder           |
+// |-------------------------------------------------------------------|
+// | Pos | Field     | Description                           | Format  |
+// |-----+-----------+---------------------------------------+---------|
+// |   0 | SECDCMD   | SECD Command, 'J'                     | F X(1)  |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.16
+
+type SecurityChangeDirectionCmd []byte
+
+func (c SecurityChangeDirectionCmd) Valid() error {
+	if l := len(c); l != 2 {
+		return NewInvalidLengthError(2, l)
+	} else if SecurityChangeDirectionMessage.Byte() != c[0] {
+		return NewInvalidPrefixError(SecurityChangeDirectionMessage.String(), string(c[0]))
+	} else if cmd := string(c[1]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	}
+	return nil
+}
+
+func (c SecurityChangeDirectionCmd) Id() Id {
+	return SecurityChangeDirectionMessage
+}
+
+func (c SecurityChangeDirectionCmd) Encode() Command {
+	return Command(c)
+}
+
+func NewSecurityChangeDirection() Command {
+	return Command(string(SecurityChangeDirectionMessage) + CarriageReturn)
+}
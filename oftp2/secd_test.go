@@ -0,0 +1,61 @@
+package oftp2_test
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSecurityChangeDirection(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func() []byte
+		expect func(t *testing.T, secd oftp2.SecurityChangeDirectionCmd)
+	}{
+		{
+			with: "a standard message",
+			input: func() []byte {
+				return oftp2.NewSecurityChangeDirection()
+			},
+			expect: func(t *testing.T, secd oftp2.SecurityChangeDirectionCmd) {
+				require.NoError(t, secd.Valid())
+				require.Equal(t, "J\r", string(secd))
+			},
+		},
+		{
+			with: "a wrong cmd id",
+			input: func() []byte {
+				m := oftp2.NewSecurityChangeDirection()
+				m[0] = 'X'
+				return m
+			},
+			expect: func(t *testing.T, secd oftp2.SecurityChangeDirectionCmd) {
+				require.EqualError(t, secd.Valid(), "does not start with J, but with X")
+			},
+		},
+		{
+			with: "missing CR",
+			input: func() []byte {
+				m := oftp2.NewSecurityChangeDirection()
+				m[1] = '6'
+				return m
+			},
+			expect: func(t *testing.T, secd oftp2.SecurityChangeDirectionCmd) {
+				require.EqualError(t, secd.Valid(), "does not end on carriage return, but on 6")
+			},
+		},
+		{
+			with: "an exceeding message",
+			input: func() []byte {
+				return append(oftp2.NewSecurityChangeDirection(), ' ')
+			},
+			expect: func(t *testing.T, secd oftp2.SecurityChangeDirectionCmd) {
+				require.EqualError(t, secd.Valid(), "expected the length of 2, but got 3")
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			scenario.expect(t, scenario.input())
+		})
+	}
+}
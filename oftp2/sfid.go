@@ -3,6 +3,7 @@ package oftp2
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 )
 
@@ -40,12 +41,77 @@ import (
 type StartFileCmd []byte
 
 func (c StartFileCmd) Valid() error {
-	if Id(c[0]) != StartFile {
+	fixLength := 166 // prefix + CR
+	if length := len(c); length < fixLength {
+		return NewInvalidLengthError(fixLength, length)
+	} else if Id(c[0]) != StartFile {
 		return fmt.Errorf("wrong command id: %v", string(c[0]))
 	}
+	descriptionLength, err := strconv.Atoi(string(c[162:165]))
+	if err != nil {
+		return err
+	} else if descriptionLength < 0 {
+		return fmt.Errorf("invalid description length: %d", descriptionLength)
+	}
+	totalLength := fixLength + descriptionLength
+	if length := len(c); length != totalLength {
+		return NewInvalidLengthError(totalLength, length)
+	} else if cmd := string(c[totalLength-1]); CarriageReturn != cmd {
+		return NewNoCrSuffixError(cmd)
+	} else if _, err := NewTimeStamp(c[30:48]); err != nil {
+		return err
+	} else if err := c.Destination().Valid(); err != nil {
+		return err
+	} else if err := c.Origin().Valid(); err != nil {
+		return err
+	} else if _, exists := KnownFileFormats[c.Format()]; !exists {
+		return fmt.Errorf("unknown file format: %v", string(c.Format()))
+	} else if size, err := strconv.Atoi(string(c[107:112])); err != nil {
+		return fmt.Errorf("invalid max record size: %w", err)
+	} else if size < 0 {
+		return fmt.Errorf("invalid max record size: %d", size)
+	} else if size, err := strconv.ParseInt(string(c[112:125]), 10, 64); err != nil {
+		return fmt.Errorf("invalid transmitted size: %w", err)
+	} else if size < 0 {
+		return fmt.Errorf("invalid transmitted size: %d", size)
+	} else if size, err := strconv.ParseInt(string(c[125:138]), 10, 64); err != nil {
+		return fmt.Errorf("invalid original size: %w", err)
+	} else if size < 0 {
+		return fmt.Errorf("invalid original size: %d", size)
+	} else if position, err := strconv.ParseInt(string(c[138:155]), 10, 64); err != nil {
+		return fmt.Errorf("invalid restart position: %w", err)
+	} else if position < 0 {
+		return fmt.Errorf("invalid restart position: %d", position)
+	} else if security, err := strconv.Atoi(string(c[155:157])); err != nil {
+		return fmt.Errorf("invalid security level: %w", err)
+	} else if _, exists := KnownSecurityLevels[SecurityLevel(security)]; !exists {
+		return fmt.Errorf("unknown security level: %d", security)
+	} else if cipher, err := strconv.Atoi(string(c[157:159])); err != nil {
+		return fmt.Errorf("invalid cipher: %w", err)
+	} else if _, exists := KnownCiphers[Cipher(cipher)]; !exists {
+		return fmt.Errorf("unknown cipher: %d", cipher)
+	} else if compression, err := strconv.Atoi(string(c[159:160])); err != nil {
+		return fmt.Errorf("invalid compression: %w", err)
+	} else if _, exists := KnownCompressions[Compression(compression)]; !exists {
+		return fmt.Errorf("unknown compression: %d", compression)
+	} else if envelope, err := strconv.Atoi(string(c[160:161])); err != nil {
+		return fmt.Errorf("invalid envelope: %w", err)
+	} else if _, exists := KnownEnvelopes[Envelope(envelope)]; !exists {
+		return fmt.Errorf("unknown envelope: %d", envelope)
+	} else if sign := string(c[161]); !isBool(sign) {
+		return fmt.Errorf("unknown signed EERP request: %s", sign)
+	}
 	return nil
 }
 
+func (c StartFileCmd) Id() Id {
+	return StartFile
+}
+
+func (c StartFileCmd) Encode() Command {
+	return Command(c)
+}
+
 func (c StartFileCmd) Name() string {
 	return strings.TrimSpace(string(c[1:27]))
 }
@@ -70,6 +136,62 @@ func (c StartFileCmd) Origin() Sid {
 	return Sid(c[81:106])
 }
 
+func (c StartFileCmd) Format() FileFormat {
+	return FileFormat(c[106])
+}
+
+func (c StartFileCmd) MaxRecordSize() int {
+	i, _ := strconv.Atoi(string(c[107:112]))
+	return i
+}
+
+// FileSize is the transmitted size in 1K blocks.
+func (c StartFileCmd) FileSize() int64 {
+	i, _ := strconv.ParseInt(string(c[112:125]), 10, 64)
+	return i
+}
+
+// OriginalFileSize is the size in 1K blocks before security services or compression were applied.
+func (c StartFileCmd) OriginalFileSize() int64 {
+	i, _ := strconv.ParseInt(string(c[125:138]), 10, 64)
+	return i
+}
+
+// RestartPosition is a record count for fixed and variable files
+// and a count of 1K blocks for unstructured and text files.
+func (c StartFileCmd) RestartPosition() int64 {
+	i, _ := strconv.ParseInt(string(c[138:155]), 10, 64)
+	return i
+}
+
+func (c StartFileCmd) SecurityLevel() SecurityLevel {
+	i, _ := strconv.Atoi(string(c[155:157]))
+	return SecurityLevel(i)
+}
+
+func (c StartFileCmd) Cipher() Cipher {
+	i, _ := strconv.Atoi(string(c[157:159]))
+	return Cipher(i)
+}
+
+func (c StartFileCmd) Compression() Compression {
+	i, _ := strconv.Atoi(string(c[159:160]))
+	return Compression(i)
+}
+
+func (c StartFileCmd) Envelope() Envelope {
+	i, _ := strconv.Atoi(string(c[160:161]))
+	return Envelope(i)
+}
+
+func (c StartFileCmd) SignedEERPRequested() bool {
+	return c[161] == 'Y'
+}
+
+func (c StartFileCmd) Description() string {
+	return string(c[165 : len(c)-1])
+}
+
 func NewStartFile(input StartFileInput) (Command, error) {
 	if len(input.Name) > 26 {
 		return nil, fmt.Errorf("name is too long: %v", input.Name)
@@ -87,6 +209,8 @@ func NewStartFile(input StartFileInput) (Command, error) {
 		return nil, fmt.Errorf("invalid transmitted size: %d", input.TransmittedSize)
 	} else if input.OriginalSize < 0 || input.OriginalSize > 9999999999999 {
 		return nil, fmt.Errorf("invalid original size: %d", input.OriginalSize)
+	} else if input.RestartPosition < 0 {
+		return nil, fmt.Errorf("invalid restart position: %d", input.RestartPosition)
 	} else if _, exists := KnownSecurityLevels[input.Security]; !exists {
 		return nil, fmt.Errorf("unknown security level: %d", input.Security)
 	} else if input.Security == SecurityNoServices && input.Compression == NoCompression && input.TransmittedSize != input.OriginalSize {
@@ -95,6 +219,8 @@ func NewStartFile(input StartFileInput) (Command, error) {
 		return nil, fmt.Errorf("unknown cipher: %d", input.Cipher)
 	} else if _, exists := KnownCompressions[input.Compression]; !exists {
 		return nil, fmt.Errorf("unknown compression: %d", input.Compression)
+	} else if _, exists := KnownEnvelopes[input.Envelope]; !exists {
+		return nil, fmt.Errorf("unknown envelope: %d", input.Envelope)
 	} else if length := len(input.Description); length > 999 {
 		return nil, fmt.Errorf("description is too long: %d", length)
 	}
@@ -107,6 +233,18 @@ func NewStartFile(input StartFileInput) (Command, error) {
 	if err != nil {
 		return nil, err
 	}
+	maxRecordSize, _ := fillUpInt(input.MaxRecordSize, 5)
+	transmittedSize, _ := fillUpInt(int(input.TransmittedSize), 13)
+	originalSize, _ := fillUpInt(int(input.OriginalSize), 13)
+	restartPosition, err := fillUpInt(int(input.RestartPosition), 17)
+	if err != nil {
+		return nil, err
+	}
+	security, _ := fillUpInt(int(input.Security), 2)
+	cipher, _ := fillUpInt(int(input.Cipher), 2)
+	compression, _ := fillUpInt(int(input.Compression), 1)
+	envelope, _ := fillUpInt(int(input.Envelope), 1)
+	descriptionLength, _ := fillUpInt(len(input.Description), 3)
 
 	return Command(
 		string(StartFile) +
@@ -116,6 +254,18 @@ func NewStartFile(input StartFileInput) (Command, error) {
 			userData +
 			string(input.Destination) +
 			string(input.Origin) +
+			string(input.Format) +
+			maxRecordSize +
+			transmittedSize +
+			originalSize +
+			restartPosition +
+			security +
+			cipher +
+			compression +
+			envelope +
+			boolToString(input.SignedReceipt) +
+			descriptionLength +
+			input.Description +
 			CarriageReturn), nil
 }
 
@@ -133,6 +283,7 @@ type StartFileInput struct {
 	Security        SecurityLevel
 	Cipher          Cipher
 	Compression     Compression
+	Envelope        Envelope
 	SignedReceipt   bool
 	Description     string
 }
@@ -171,15 +322,17 @@ const (
 
 type Cipher int
 
+// KnownCiphers are the cipher suites by their SFIDCIPH.
+// Further suites, like the SHA-256 and SHA-512 based ones, can be added with the names of their algorithms.
 var KnownCiphers = map[Cipher]CipherMapping{
 	NoCipher: {},
 	Cipher3DesEdeCbc3Key: {
-		Symmetric:  "AES_256_CBC",
+		Symmetric:  "3DES_EDE_CBC_3KEY",
 		Asymmetric: "RSA_PKCS1_15",
 		Hashing:    "SHA-1",
 	},
 	CipherAes256Cbc: {
-		Symmetric:  "3DES_EDE_CBC_3KEY",
+		Symmetric:  "AES_256_CBC",
 		Asymmetric: "RSA_PKCS1_15",
 		Hashing:    "SHA-1",
 	},
@@ -208,3 +361,15 @@ const (
 	NoCompression   Compression = 0
 	CompressionZlib Compression = 1
 )
+
+type Envelope int
+
+var KnownEnvelopes = map[Envelope]struct{}{
+	NoEnvelope:  {},
+	EnvelopeCms: {},
+}
+
+const (
+	NoEnvelope  Envelope = 0
+	EnvelopeCms Envelope = 1
+)
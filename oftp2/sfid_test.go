@@ -203,6 +203,42 @@ func TestStartFile(t *testing.T) {
 				require.Nil(t, cmd)
 			},
 		},
+		{
+			with: "negative restart position",
+			input: func(t *testing.T) oftp2.StartFileInput {
+				i := validStartFileInput(t)
+				i.RestartPosition = -1
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "invalid restart position: -1")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "exceeding restart position",
+			input: func(t *testing.T) oftp2.StartFileInput {
+				i := validStartFileInput(t)
+				i.RestartPosition = 100000000000000000
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "exceeded capacity: 100000000000000000 (17)")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "unknown envelope",
+			input: func(t *testing.T) oftp2.StartFileInput {
+				i := validStartFileInput(t)
+				i.Envelope = -1
+				return i
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "unknown envelope: -1")
+				require.Nil(t, cmd)
+			},
+		},
 		{
 			with: "exceeding description",
 			input: func(t *testing.T) oftp2.StartFileInput {
@@ -236,9 +272,36 @@ func TestStartFile_Valid(t *testing.T) {
 			},
 			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
 				require.NoError(t, sfid.Valid())
-				//require.Equal(t, oftp2.AnswerInvalidFilename, sfna.ReasonCode())
-				//require.Equal(t, false, sfna.Retry())
-				//require.Equal(t, "MY_TEXT", sfna.ReasonText())
+				require.Equal(t, "MY_FILE", sfid.Name())
+				require.Equal(t, oftp2.FileFormatFixed, sfid.Format())
+				require.Equal(t, 10, sfid.MaxRecordSize())
+				require.Equal(t, int64(10), sfid.FileSize())
+				require.Equal(t, int64(20), sfid.OriginalFileSize())
+				require.Equal(t, int64(0), sfid.RestartPosition())
+				require.Equal(t, oftp2.SecurityEncrypted, sfid.SecurityLevel())
+				require.Equal(t, oftp2.CipherAes256Cbc, sfid.Cipher())
+				require.Equal(t, oftp2.NoCompression, sfid.Compression())
+				require.Equal(t, oftp2.EnvelopeCms, sfid.Envelope())
+				require.False(t, sfid.SignedEERPRequested())
+				require.Equal(t, "Description", sfid.Description())
+			},
+		},
+		{
+			with: "a restarted and signed file",
+			input: func(t *testing.T) []byte {
+				i := validStartFileInput(t)
+				i.RestartPosition = 42
+				i.SignedReceipt = true
+				i.Description = ""
+				file, err := oftp2.NewStartFile(i)
+				require.NoError(t, err)
+				return file
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.NoError(t, sfid.Valid())
+				require.Equal(t, int64(42), sfid.RestartPosition())
+				require.True(t, sfid.SignedEERPRequested())
+				require.Equal(t, "", sfid.Description())
 			},
 		},
 		{
@@ -271,79 +334,200 @@ func TestStartFile_Valid(t *testing.T) {
 				require.Equal(t, originSid, sfid.Origin())
 			},
 		},
-		//{
-		//	with: "a wrong length",
-		//	input: func(t *testing.T) []byte {
-		//		p := validStartFileNegative(t)
-		//		return append(p, ' ')
-		//	},
-		//	expect: func(t *testing.T, sfna oftp2.StartFileNegativeAnswerCmd) {
-		//		require.EqualError(t, sfna.Valid(), "expected the length of 8, but got 9")
-		//		require.Equal(t, oftp2.AnswerInvalidFilename, sfna.ReasonCode())
-		//		require.Equal(t, true, sfna.Retry())
-		//		require.Equal(t, "\r", sfna.ReasonText())
-		//	},
-		//},
-		//{
-		//	with: "missing carriage return",
-		//	input: func(t *testing.T) []byte {
-		//		p := validStartFileNegative(t)
-		//		p[len(p)-1] = 'd'
-		//		return p
-		//	},
-		//	expect: func(t *testing.T, sfna oftp2.StartFileNegativeAnswerCmd) {
-		//		require.EqualError(t, sfna.Valid(), "does not end on carriage return, but on d")
-		//		require.Equal(t, oftp2.AnswerInvalidFilename, sfna.ReasonCode())
-		//		require.Equal(t, true, sfna.Retry())
-		//		require.Equal(t, "", sfna.ReasonText())
-		//	},
-		//},
-		//{
-		//	with: "corrupted reason code",
-		//	input: func(t *testing.T) []byte {
-		//		p := validStartFileNegative(t)
-		//		p[2] = 'd'
-		//		return p
-		//	},
-		//	expect: func(t *testing.T, sfna oftp2.StartFileNegativeAnswerCmd) {
-		//		require.EqualError(t, sfna.Valid(), `invalid reason code`)
-		//		require.Equal(t, oftp2.AnswerReason(0), sfna.ReasonCode())
-		//		require.Equal(t, true, sfna.Retry())
-		//		require.Equal(t, "", sfna.ReasonText())
-		//	},
-		//},
-		//{
-		//	with: "corrupted retry",
-		//	input: func(t *testing.T) []byte {
-		//		p := validStartFileNegative(t)
-		//		p[3] = 'd'
-		//		return p
-		//	},
-		//	expect: func(t *testing.T, sfna oftp2.StartFileNegativeAnswerCmd) {
-		//		require.EqualError(t, sfna.Valid(), `invalid retry`)
-		//		require.Equal(t, oftp2.AnswerInvalidFilename, sfna.ReasonCode())
-		//		require.Equal(t, false, sfna.Retry())
-		//		require.Equal(t, "", sfna.ReasonText())
-		//	},
-		//},
-		//{
-		//	with: "corrupted reason length",
-		//	input: func(t *testing.T) []byte {
-		//		file, err := oftp2.NewStartFileNegativeAnswer(oftp2.NegativeFileInput{
-		//			Reason:     oftp2.AnswerInvalidFilename,
-		//			ReasonText: "MY_TEXT",
-		//		})
-		//		require.NoError(t, err)
-		//		file[5] = 'd'
-		//		return file
-		//	},
-		//	expect: func(t *testing.T, sfna oftp2.StartFileNegativeAnswerCmd) {
-		//		require.EqualError(t, sfna.Valid(), `strconv.Atoi: parsing "0d7": invalid syntax`)
-		//		require.Equal(t, oftp2.AnswerInvalidFilename, sfna.ReasonCode())
-		//		require.Equal(t, false, sfna.Retry())
-		//		require.Equal(t, "MY_TEXT", sfna.ReasonText())
-		//	},
-		//},
+		{
+			with: "a truncated message",
+			input: func(t *testing.T) []byte {
+				return validStartFile(t)[:165]
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "expected the length of 166, but got 165")
+			},
+		},
+		{
+			with: "a wrong length",
+			input: func(t *testing.T) []byte {
+				return append(validStartFile(t), ' ')
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "expected the length of 177, but got 178")
+			},
+		},
+		{
+			with: "missing carriage return",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[len(p)-1] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "does not end on carriage return, but on d")
+			},
+		},
+		{
+			with: "corrupted date",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[31] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), `strconv.Atoi: parsing "2d20": invalid syntax`)
+			},
+		},
+		{
+			with: "an invalid destination",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[56] = '^'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "does not start with O, but with ^")
+			},
+		},
+		{
+			with: "an invalid origin",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[81] = '^'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "does not start with O, but with ^")
+			},
+		},
+		{
+			with: "an unknown file format",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[106] = '?'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "unknown file format: ?")
+			},
+		},
+		{
+			with: "a corrupted max record size",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[107] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), `invalid max record size: strconv.Atoi: parsing "d0010": invalid syntax`)
+			},
+		},
+		{
+			with: "a negative max record size",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[107] = '-'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "invalid max record size: -10")
+			},
+		},
+		{
+			with: "a corrupted transmitted size",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[112] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), `invalid transmitted size: strconv.ParseInt: parsing "d000000000010": invalid syntax`)
+			},
+		},
+		{
+			with: "a negative original size",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[125] = '-'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "invalid original size: -20")
+			},
+		},
+		{
+			with: "a corrupted restart position",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[138] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), `invalid restart position: strconv.ParseInt: parsing "d0000000000000000": invalid syntax`)
+			},
+		},
+		{
+			with: "an unknown security level",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[156] = '9'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "unknown security level: 9")
+			},
+		},
+		{
+			with: "an unknown cipher",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[158] = '9'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "unknown cipher: 9")
+			},
+		},
+		{
+			with: "an unknown compression",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[159] = '9'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "unknown compression: 9")
+			},
+		},
+		{
+			with: "an unknown envelope",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[160] = '9'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "unknown envelope: 9")
+			},
+		},
+		{
+			with: "an unknown signed EERP request",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[161] = 'U'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), "unknown signed EERP request: U")
+			},
+		},
+		{
+			with: "a corrupted description length",
+			input: func(t *testing.T) []byte {
+				p := validStartFile(t)
+				p[163] = 'd'
+				return p
+			},
+			expect: func(t *testing.T, sfid oftp2.StartFileCmd) {
+				require.EqualError(t, sfid.Valid(), `strconv.Atoi: parsing "0d1": invalid syntax`)
+			},
+		},
 	} {
 		t.Run(scenario.with, func(t *testing.T) {
 			scenario.expect(t, scenario.input(t))
@@ -386,7 +570,17 @@ func validStartFileInput(t *testing.T) oftp2.StartFileInput {
 		Security:        oftp2.SecurityEncrypted,
 		Cipher:          oftp2.CipherAes256Cbc,
 		Compression:     oftp2.NoCompression,
+		Envelope:        oftp2.EnvelopeCms,
 		SignedReceipt:   false,
 		Description:     "Description",
 	}
 }
+
+func TestKnownCiphers(t *testing.T) {
+	require.Equal(t, "3DES_EDE_CBC_3KEY", oftp2.KnownCiphers[oftp2.Cipher3DesEdeCbc3Key].Symmetric)
+	require.Equal(t, "AES_256_CBC", oftp2.KnownCiphers[oftp2.CipherAes256Cbc].Symmetric)
+	for _, c := range []oftp2.Cipher{oftp2.Cipher3DesEdeCbc3Key, oftp2.CipherAes256Cbc} {
+		require.Equal(t, "RSA_PKCS1_15", oftp2.KnownCiphers[c].Asymmetric)
+		require.Equal(t, "SHA-1", oftp2.KnownCiphers[c].Hashing)
+	}
+}
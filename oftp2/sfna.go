@@ -25,6 +25,9 @@ type StartFileNegativeAnswerCmd []byte
 
 func (c StartFileNegativeAnswerCmd) Valid() error {
 	fixLength := 8 // prefix + CR
+	if length := len(c); length < fixLength {
+		return NewInvalidLengthError(fixLength, length)
+	}
 	variableLength, err := strconv.Atoi(string(c[4:7]))
 	if err != nil {
 		return err
@@ -44,6 +47,14 @@ func (c StartFileNegativeAnswerCmd) Valid() error {
 	return nil
 }
 
+func (c StartFileNegativeAnswerCmd) Id() Id {
+	return StartFileNegativeMessage
+}
+
+func (c StartFileNegativeAnswerCmd) Encode() Command {
+	return Command(c)
+}
+
 func (c StartFileNegativeAnswerCmd) ReasonCode() AnswerReason {
 	i, _ := strconv.Atoi(string(c[1:3]))
 	return AnswerReason(i)
@@ -35,6 +35,14 @@ func (c StartFilePositiveAnswerCmd) Valid() error {
 	return nil
 }
 
+func (c StartFilePositiveAnswerCmd) Id() Id {
+	return StartFilePositiveMessage
+}
+
+func (c StartFilePositiveAnswerCmd) Encode() Command {
+	return Command(c)
+}
+
 func (c StartFilePositiveAnswerCmd) AnswerCount() int {
 	i, _ := strconv.Atoi(string(c[1:18]))
 	return i
@@ -43,11 +43,11 @@ func (c StartSessionCmd) Valid() error {
 	} else if err := c.IdentificationCode().Valid(); err != nil {
 		return err
 	} else if level := c.ProtocolLevel(); level != '5' {
-		return fmt.Errorf("invalid protocol level: %d", level)
+		return NewEndSessionError(EndSessionModeOrCapabilitiesIncompatible, fmt.Errorf("invalid protocol level: %d", level))
 	} else if de, err := strconv.Atoi(string(c[35:40])); err != nil {
-		return fmt.Errorf("invalid DataExchangeBufferSize: %w", err)
-	} else if de < 128 || de > 99999 {
-		return fmt.Errorf("invalid DataExchangeBufferSize: %d", de)
+		return NewEndSessionError(EndSessionExchangeBufferSizeError, fmt.Errorf("invalid DataExchangeBufferSize: %w", err))
+	} else if de < MinDataExchangeBufferSize || de > MaxDataExchangeBufferSize {
+		return NewEndSessionError(EndSessionExchangeBufferSizeError, fmt.Errorf("invalid DataExchangeBufferSize: %d", de))
 	} else if ca := c.Capabilities(); !isCapability(ca) {
 		return fmt.Errorf("unknown capability: %s", ca)
 	} else if bc := string(c[41]); !isBool(bc) {
@@ -67,6 +67,14 @@ func (c StartSessionCmd) Valid() error {
 	return nil
 }
 
+func (c StartSessionCmd) Id() Id {
+	return StartSessionMessage
+}
+
+func (c StartSessionCmd) Encode() Command {
+	return Command(c)
+}
+
 func (c StartSessionCmd) ProtocolLevel() byte {
 	return c[1]
 }
@@ -121,6 +129,11 @@ const (
 	CapabilityBoth    SsidCapability = "B"
 )
 
+const (
+	MinDataExchangeBufferSize = 128
+	MaxDataExchangeBufferSize = 99999
+)
+
 type StartSessionInput struct {
 	IdentificationCode     IdentificationCode
 	Password               string
@@ -27,6 +27,14 @@ func (c StartSessionReadyMessageCmd) Valid() error {
 	return nil
 }
 
+func (c StartSessionReadyMessageCmd) Id() Id {
+	return StartSessionReadyMessage
+}
+
+func (c StartSessionReadyMessageCmd) Encode() Command {
+	return Command(c)
+}
+
 func (c StartSessionReadyMessageCmd) Message() []byte {
 	return c[1:18]
 }
@@ -0,0 +1,90 @@
+package oftp2
+
+import (
+	"fmt"
+	"io"
+)
+
+// o-------------------------------------------------------------------o
+// |       STH         Stream Transmission Header                      |
+// |-------------------------------------------------------------------|
+// | Bits  | Field   | Description                                     |
+// |-------+---------+-------------------------------------------------|
+// | 0-3   | Version | Always 0001                                     |
+// | 4-7   | Flags   | Always 0000                                     |
+// | 8-31  | Length  | Length of the STB including the header,         |
+// |       |         | minimum 5, maximum 100003                       |
+// o-------------------------------------------------------------------o
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-8.1
+
+const (
+	StreamTransmissionVersion         = 0x1
+	StreamTransmissionFlags           = 0x0
+	MinStreamTransmissionBufferLength = 5
+	MaxStreamTransmissionBufferLength = 100003
+)
+
+// StreamTransmissionReader reads whole commands framed by a Stream Transmission Header.
+type StreamTransmissionReader struct {
+	reader io.Reader
+	header []byte
+}
+
+func NewStreamTransmissionReader(reader io.Reader) *StreamTransmissionReader {
+	return &StreamTransmissionReader{
+		reader: reader,
+		header: make([]byte, StreamTransmissionHeaderLength),
+	}
+}
+
+// ReadCommand blocks until the next complete command has been received.
+// Malformed headers are EndSessionErrors, as the stream can't be resynchronised.
+func (s *StreamTransmissionReader) ReadCommand() (Command, error) {
+	if _, err := io.ReadFull(s.reader, s.header); err != nil {
+		return nil, err
+	}
+	if version := s.header[0] >> 4; version != StreamTransmissionVersion {
+		return nil, NewEndSessionError(EndSessionProtocolViolation, fmt.Errorf("unsupported stream transmission version: %d", version))
+	}
+	if flags := s.header[0] & 0x0f; flags != StreamTransmissionFlags {
+		return nil, NewEndSessionError(EndSessionProtocolViolation, fmt.Errorf("unsupported stream transmission flags: %d", flags))
+	}
+	length := int(s.header[1])<<16 | int(s.header[2])<<8 | int(s.header[3])
+	if err := validStreamTransmissionLength(length); err != nil {
+		return nil, err
+	}
+	cmd := make(Command, length-StreamTransmissionHeaderLength)
+	if _, err := io.ReadFull(s.reader, cmd); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// StreamTransmissionWriter writes commands framed by a Stream Transmission Header.
+type StreamTransmissionWriter struct {
+	writer io.Writer
+}
+
+func NewStreamTransmissionWriter(writer io.Writer) *StreamTransmissionWriter {
+	return &StreamTransmissionWriter{writer: writer}
+}
+
+// WriteCommand writes the header and the command in a single write.
+func (s *StreamTransmissionWriter) WriteCommand(c Command) error {
+	if err := validStreamTransmissionLength(len(c) + StreamTransmissionHeaderLength); err != nil {
+		return err
+	}
+	_, err := s.writer.Write(c.StreamTransmissionBuffer())
+	return err
+}
+
+func validStreamTransmissionLength(length int) error {
+	if length < MinStreamTransmissionBufferLength || length > MaxStreamTransmissionBufferLength {
+		return NewEndSessionError(EndSessionProtocolViolation, fmt.Errorf("invalid stream transmission length: %d", length))
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package oftp2_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestStreamTransmissionReader(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  func(t *testing.T) []byte
+		expect func(t *testing.T, cmd oftp2.Command, err error)
+	}{
+		{
+			with: "a framed command",
+			input: func(t *testing.T) []byte {
+				return oftp2.NewStartSessionReadyMessage().StreamTransmissionBuffer()
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Equal(t, oftp2.NewStartSessionReadyMessage(), cmd)
+			},
+		},
+		{
+			with: "a binary payload containing carriage returns",
+			input: func(t *testing.T) []byte {
+				return oftp2.NewDataExchangeBuffer([]byte("\r\x00\r")).StreamTransmissionBuffer()
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.NoError(t, err)
+				require.Equal(t, oftp2.Command("D\r\x00\r"), cmd)
+			},
+		},
+		{
+			with: "a wrong version",
+			input: func(t *testing.T) []byte {
+				stb := oftp2.NewStartSessionReadyMessage().StreamTransmissionBuffer()
+				stb[0] = 0x20
+				return stb
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "unsupported stream transmission version: 2")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "set flags",
+			input: func(t *testing.T) []byte {
+				stb := oftp2.NewStartSessionReadyMessage().StreamTransmissionBuffer()
+				stb[0] = 0x11
+				return stb
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "unsupported stream transmission flags: 1")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "a too short length",
+			input: func(t *testing.T) []byte {
+				return []byte{0x10, 0x00, 0x00, 0x04}
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "invalid stream transmission length: 4")
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "a too long length",
+			input: func(t *testing.T) []byte {
+				return []byte{0x10, 0x01, 0x86, 0xa4}
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.EqualError(t, err, "invalid stream transmission length: 100004")
+				var endSessionErr oftp2.EndSessionError
+				require.True(t, errors.As(err, &endSessionErr))
+				require.Equal(t, oftp2.EndSessionProtocolViolation, endSessionErr.Reason)
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "a truncated command",
+			input: func(t *testing.T) []byte {
+				stb := oftp2.NewStartSessionReadyMessage().StreamTransmissionBuffer()
+				return stb[:len(stb)-1]
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "a truncated command body",
+			input: func(t *testing.T) []byte {
+				return []byte{0x10, 0x00, 0x00, 0x05}
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+				require.Nil(t, cmd)
+			},
+		},
+		{
+			with: "no input",
+			input: func(t *testing.T) []byte {
+				return []byte{}
+			},
+			expect: func(t *testing.T, cmd oftp2.Command, err error) {
+				require.True(t, errors.Is(err, io.EOF))
+				require.Nil(t, cmd)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			reader := oftp2.NewStreamTransmissionReader(bytes.NewReader(scenario.input(t)))
+			cmd, err := reader.ReadCommand()
+			scenario.expect(t, cmd, err)
+		})
+	}
+}
+
+func TestStreamTransmissionWriter(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		input  oftp2.Command
+		expect func(t *testing.T, written []byte, err error)
+	}{
+		{
+			with:  "a command",
+			input: oftp2.NewChangeDirection(),
+			expect: func(t *testing.T, written []byte, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []byte{0x10, 0x00, 0x00, 0x06, 'R', '\r'}, written)
+			},
+		},
+		{
+			with:  "an empty command",
+			input: oftp2.Command{},
+			expect: func(t *testing.T, written []byte, err error) {
+				require.EqualError(t, err, "invalid stream transmission length: 4")
+				require.Empty(t, written)
+			},
+		},
+		{
+			with:  "an exceeding command",
+			input: make(oftp2.Command, 100000),
+			expect: func(t *testing.T, written []byte, err error) {
+				require.EqualError(t, err, "invalid stream transmission length: 100004")
+				require.Empty(t, written)
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := oftp2.NewStreamTransmissionWriter(buf).WriteCommand(scenario.input)
+			scenario.expect(t, buf.Bytes(), err)
+		})
+	}
+}
+
+func TestStreamTransmission_RoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer := oftp2.NewStreamTransmissionWriter(buf)
+	commands := []oftp2.Command{
+		oftp2.NewStartSessionReadyMessage(),
+		oftp2.NewDataExchangeBuffer(bytes.Repeat([]byte{'\r'}, 99998)),
+		oftp2.NewChangeDirection(),
+	}
+	for _, cmd := range commands {
+		require.NoError(t, writer.WriteCommand(cmd))
+	}
+	reader := oftp2.NewStreamTransmissionReader(buf)
+	for _, expected := range commands {
+		cmd, err := reader.ReadCommand()
+		require.NoError(t, err)
+		require.Equal(t, expected, cmd)
+	}
+	_, err := reader.ReadCommand()
+	require.True(t, errors.Is(err, io.EOF))
+}
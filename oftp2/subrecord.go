@@ -0,0 +1,194 @@
+package oftp2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// o-------------------------------------------------------------------o
+// |       Subrecord Header                                            |
+// |-------------------------------------------------------------------|
+// | Bit   | Field   | Description                                     |
+// |-------+---------+-------------------------------------------------|
+// | 0     | E       | End of Record Flag                              |
+// | 1     | C       | Compression Flag                                |
+// | 2-7   | Count   | Subrecord Count, 0 - 63                         |
+// o-------------------------------------------------------------------o
+//
+// An uncompressed subrecord is followed by Count octets of data.
+// A compressed subrecord is followed by one octet, repeated Count times.
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-7.3
+
+const (
+	SubrecordEndOfRecordFlag = 0x80
+	SubrecordCompressionFlag = 0x40
+	MaxSubrecordCount        = 0x3f
+	// minCompressedRun is the shortest run worth compressing, as a compressed subrecord takes two octets
+	minCompressedRun = 3
+)
+
+// SubrecordEncoder packs records into Data Exchange Buffers of at most the negotiated buffer size.
+// With compression, runs of repeated octets are sent as compressed subrecords.
+type SubrecordEncoder struct {
+	bufferSize  int
+	compression bool
+	buffer      Command
+}
+
+// NewSubrecordEncoder creates an encoder for the negotiated buffer size.
+// Compression must only be used when both sides set SSIDCMPR.
+func NewSubrecordEncoder(bufferSize int, compression bool) (*SubrecordEncoder, error) {
+	if bufferSize < MinDataExchangeBufferSize || bufferSize > MaxDataExchangeBufferSize {
+		return nil, fmt.Errorf("invalid DataExchangeBufferSize: %d", bufferSize)
+	}
+	return &SubrecordEncoder{
+		bufferSize:  bufferSize,
+		compression: compression,
+	}, nil
+}
+
+// WriteRecord adds a whole record, setting the end of record flag on its last subrecord.
+// It returns the buffers that were filled up by it.
+func (e *SubrecordEncoder) WriteRecord(record []byte) []Command {
+	return e.write(record, true)
+}
+
+// Write adds data without record boundaries, as used by unstructured and text files.
+// It returns the buffers that were filled up by it.
+func (e *SubrecordEncoder) Write(data []byte) []Command {
+	return e.write(data, false)
+}
+
+// Flush returns the partly filled buffer, or nil when there is none.
+func (e *SubrecordEncoder) Flush() Command {
+	buffer := e.buffer
+	e.buffer = nil
+	return buffer
+}
+
+func (e *SubrecordEncoder) write(data []byte, endOfRecord bool) []Command {
+	if len(data) == 0 && !endOfRecord {
+		return nil
+	}
+	var full []Command
+	for {
+		if e.buffer == nil {
+			e.buffer = NewDataExchangeBuffer(nil)
+		}
+		// a header with at least one octet must fit, except for the end of an empty record
+		space := e.bufferSize - len(e.buffer) - 1
+		if space < 1 && (space < 0 || len(data) > 0) {
+			full = append(full, e.Flush())
+			continue
+		}
+		header, octets, count := e.nextSubrecord(data, space)
+		last := count == len(data)
+		if last && endOfRecord {
+			header |= SubrecordEndOfRecordFlag
+		}
+		e.buffer = append(append(e.buffer, header), octets...)
+		data = data[count:]
+		if last {
+			return full
+		}
+	}
+}
+
+// nextSubrecord returns the header without E flag, the octets following it and the number of data octets it covers.
+func (e *SubrecordEncoder) nextSubrecord(data []byte, space int) (byte, []byte, int) {
+	if e.compression {
+		if run := repetitions(data); run >= minCompressedRun {
+			count := limit(run, MaxSubrecordCount)
+			return SubrecordCompressionFlag | byte(count), data[:1], count
+		}
+	}
+	count := limit(len(data), limit(space, MaxSubrecordCount))
+	if e.compression {
+		count = literals(data[:count])
+	}
+	return byte(count), data[:count], count
+}
+
+// repetitions counts how often the first octet is repeated at the start of data.
+func repetitions(data []byte) int {
+	run := 0
+	for run < len(data) && data[run] == data[0] {
+		run++
+	}
+	return run
+}
+
+// literals counts the octets before the first run worth compressing.
+func literals(data []byte) int {
+	for i := 0; i+minCompressedRun <= len(data); i++ {
+		if data[i] == data[i+1] && data[i] == data[i+2] {
+			return i
+		}
+	}
+	return len(data)
+}
+
+func limit(i, max int) int {
+	if i > max {
+		return max
+	}
+	return i
+}
+
+// SubrecordDecoder rebuilds records from received Data Exchange Buffers.
+// Records may span several buffers.
+type SubrecordDecoder struct {
+	pending []byte
+}
+
+func NewSubrecordDecoder() *SubrecordDecoder {
+	return &SubrecordDecoder{}
+}
+
+// Decode returns the records ended within the buffer.
+// Data of a record that isn't ended yet is kept until its end arrives.
+func (d *SubrecordDecoder) Decode(buffer DataExchangeBuffer) ([][]byte, error) {
+	if err := buffer.Valid(); err != nil {
+		return nil, err
+	}
+	var records [][]byte
+	payload := buffer.Payload()
+	if len(payload) == 0 {
+		return nil, errors.New("missing subrecord")
+	}
+	for len(payload) > 0 {
+		header := payload[0]
+		count := int(header & MaxSubrecordCount)
+		payload = payload[1:]
+		if header&SubrecordCompressionFlag != 0 {
+			if len(payload) < 1 {
+				return nil, errors.New("missing compressed octet")
+			}
+			for i := 0; i < count; i++ {
+				d.pending = append(d.pending, payload[0])
+			}
+			payload = payload[1:]
+		} else {
+			if len(payload) < count {
+				return nil, fmt.Errorf("subrecord of %d octets exceeds the buffer by %d", count, count-len(payload))
+			}
+			d.pending = append(d.pending, payload[:count]...)
+			payload = payload[count:]
+		}
+		if header&SubrecordEndOfRecordFlag != 0 {
+			records = append(records, d.Flush())
+		}
+	}
+	return records, nil
+}
+
+// Flush returns the data received since the last end of record, as used by unstructured and text files.
+func (d *SubrecordDecoder) Flush() []byte {
+	data := d.pending
+	d.pending = nil
+	if data == nil {
+		return []byte{}
+	}
+	return data
+}
@@ -0,0 +1,266 @@
+package oftp2_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewSubrecordEncoder(t *testing.T) {
+	for _, size := range []int{127, 100000} {
+		encoder, err := oftp2.NewSubrecordEncoder(size, false)
+		require.Error(t, err)
+		require.Nil(t, encoder)
+	}
+}
+
+func TestSubrecordEncoder(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		write  func(e *oftp2.SubrecordEncoder) []oftp2.Command
+		expect []oftp2.Command
+	}{
+		{
+			with: "a short record",
+			write: func(e *oftp2.SubrecordEncoder) []oftp2.Command {
+				return e.WriteRecord([]byte("ABC"))
+			},
+			expect: []oftp2.Command{
+				append([]byte{'D', 0x83}, "ABC"...),
+			},
+		},
+		{
+			with: "several records",
+			write: func(e *oftp2.SubrecordEncoder) []oftp2.Command {
+				return append(e.WriteRecord([]byte("AB")), e.WriteRecord([]byte("C"))...)
+			},
+			expect: []oftp2.Command{
+				append(append([]byte{'D', 0x82}, "AB"...), 0x81, 'C'),
+			},
+		},
+		{
+			with: "an empty record",
+			write: func(e *oftp2.SubrecordEncoder) []oftp2.Command {
+				return e.WriteRecord([]byte{})
+			},
+			expect: []oftp2.Command{
+				{'D', 0x80},
+			},
+		},
+		{
+			with: "a record longer than a subrecord",
+			write: func(e *oftp2.SubrecordEncoder) []oftp2.Command {
+				return e.WriteRecord(bytes.Repeat([]byte{'A'}, 64))
+			},
+			expect: []oftp2.Command{
+				append(append(append([]byte{'D', 0x3f}, bytes.Repeat([]byte{'A'}, 63)...), 0x81), 'A'),
+			},
+		},
+		{
+			with: "unstructured data",
+			write: func(e *oftp2.SubrecordEncoder) []oftp2.Command {
+				return append(e.Write([]byte("AB")), e.Write(nil)...)
+			},
+			expect: []oftp2.Command{
+				append([]byte{'D', 0x02}, "AB"...),
+			},
+		},
+		{
+			with: "a record spanning buffers",
+			write: func(e *oftp2.SubrecordEncoder) []oftp2.Command {
+				return e.WriteRecord(bytes.Repeat([]byte{'A'}, 130))
+			},
+			expect: []oftp2.Command{
+				append(append(append([]byte{'D', 0x3f}, bytes.Repeat([]byte{'A'}, 63)...), 0x3e), bytes.Repeat([]byte{'A'}, 62)...),
+				append([]byte{'D', 0x85}, bytes.Repeat([]byte{'A'}, 5)...),
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			encoder, err := oftp2.NewSubrecordEncoder(oftp2.MinDataExchangeBufferSize, false)
+			require.NoError(t, err)
+			buffers := scenario.write(encoder)
+			if last := encoder.Flush(); last != nil {
+				buffers = append(buffers, last)
+			}
+			require.Equal(t, scenario.expect, buffers)
+			for _, buffer := range buffers {
+				require.LessOrEqual(t, len(buffer), oftp2.MinDataExchangeBufferSize)
+			}
+			require.Nil(t, encoder.Flush())
+		})
+	}
+}
+
+func TestSubrecordEncoder_Compression(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		record []byte
+		expect []oftp2.Command
+	}{
+		{
+			with:   "no repetitions",
+			record: []byte("ABC"),
+			expect: []oftp2.Command{
+				append([]byte{'D', 0x83}, "ABC"...),
+			},
+		},
+		{
+			with:   "a too short repetition",
+			record: []byte("AAB"),
+			expect: []oftp2.Command{
+				append([]byte{'D', 0x83}, "AAB"...),
+			},
+		},
+		{
+			with:   "a repetition",
+			record: []byte("AAAA"),
+			expect: []oftp2.Command{
+				{'D', 0xc4, 'A'},
+			},
+		},
+		{
+			with:   "a repetition between literals",
+			record: []byte("AB     C"),
+			expect: []oftp2.Command{
+				{'D', 0x02, 'A', 'B', 0x45, ' ', 0x81, 'C'},
+			},
+		},
+		{
+			with:   "a repetition longer than a subrecord",
+			record: bytes.Repeat([]byte{' '}, 100),
+			expect: []oftp2.Command{
+				{'D', 0x7f, ' ', 0xe5, ' '},
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			encoder, err := oftp2.NewSubrecordEncoder(oftp2.MinDataExchangeBufferSize, true)
+			require.NoError(t, err)
+			buffers := append(encoder.WriteRecord(scenario.record), encoder.Flush())
+			require.Equal(t, scenario.expect, buffers)
+		})
+	}
+}
+
+func TestSubrecordDecoder(t *testing.T) {
+	for _, scenario := range []struct {
+		with    string
+		input   []oftp2.Command
+		records [][]byte
+		pending []byte
+	}{
+		{
+			with:    "a record",
+			input:   []oftp2.Command{append([]byte{'D', 0x83}, "ABC"...)},
+			records: [][]byte{[]byte("ABC")},
+			pending: []byte{},
+		},
+		{
+			with:    "an empty record",
+			input:   []oftp2.Command{{'D', 0x80}},
+			records: [][]byte{{}},
+			pending: []byte{},
+		},
+		{
+			with: "a record spanning buffers",
+			input: []oftp2.Command{
+				append([]byte{'D', 0x02}, "AB"...),
+				append([]byte{'D', 0x81}, "C"...),
+			},
+			records: [][]byte{[]byte("ABC")},
+			pending: []byte{},
+		},
+		{
+			with:    "a compressed subrecord",
+			input:   []oftp2.Command{{'D', 0xc4, 'A', 0x01, 'B'}},
+			records: [][]byte{[]byte("AAAA")},
+			pending: []byte("B"),
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			decoder := oftp2.NewSubrecordDecoder()
+			var records [][]byte
+			for _, buffer := range scenario.input {
+				r, err := decoder.Decode(oftp2.DataExchangeBuffer(buffer))
+				require.NoError(t, err)
+				records = append(records, r...)
+			}
+			require.Equal(t, scenario.records, records)
+			require.Equal(t, scenario.pending, decoder.Flush())
+		})
+	}
+}
+
+func TestSubrecordDecoder_Invalid(t *testing.T) {
+	for _, scenario := range []struct {
+		with  string
+		input oftp2.Command
+		error string
+	}{
+		{
+			with:  "a wrong command",
+			input: oftp2.Command{'F', 0x80},
+			error: "does not start with D, but with F",
+		},
+		{
+			with:  "no subrecord",
+			input: oftp2.Command{'D'},
+			error: "missing subrecord",
+		},
+		{
+			with:  "a truncated subrecord",
+			input: oftp2.Command{'D', 0x83, 'A'},
+			error: "subrecord of 3 octets exceeds the buffer by 2",
+		},
+		{
+			with:  "a truncated compressed subrecord",
+			input: oftp2.Command{'D', 0xc3},
+			error: "missing compressed octet",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			records, err := oftp2.NewSubrecordDecoder().Decode(oftp2.DataExchangeBuffer(scenario.input))
+			require.EqualError(t, err, scenario.error)
+			require.Nil(t, records)
+		})
+	}
+}
+
+func TestSubrecord_RoundTrip(t *testing.T) {
+	for _, compression := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compression %v", compression), func(t *testing.T) {
+			roundTrip(t, compression)
+		})
+	}
+}
+
+func roundTrip(t *testing.T, compression bool) {
+	records := [][]byte{
+		[]byte("first"),
+		{},
+		bytes.Repeat([]byte{'\r'}, 1000),
+		[]byte("AAB  CCCC  D"),
+		bytes.Repeat([]byte("ABCD"), 100),
+		[]byte("last"),
+	}
+	encoder, err := oftp2.NewSubrecordEncoder(oftp2.MinDataExchangeBufferSize, compression)
+	require.NoError(t, err)
+	var buffers []oftp2.Command
+	for _, record := range records {
+		buffers = append(buffers, encoder.WriteRecord(record)...)
+	}
+	buffers = append(buffers, encoder.Flush())
+
+	decoder := oftp2.NewSubrecordDecoder()
+	var decoded [][]byte
+	for _, buffer := range buffers {
+		require.LessOrEqual(t, len(buffer), oftp2.MinDataExchangeBufferSize)
+		r, err := decoder.Decode(oftp2.DataExchangeBuffer(buffer))
+		require.NoError(t, err)
+		decoded = append(decoded, r...)
+	}
+	require.Equal(t, records, decoded)
+}
@@ -0,0 +1,140 @@
+// Package partner keeps the session profiles of known partners in a registry, which is loaded from a JSON file.
+package partner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"os"
+)
+
+// Partner is the session profile of a partner.
+type Partner struct {
+	// Name identifies the partner in the registry, which defaults to its identification code.
+	Name string `json:"name"`
+	// IdentificationCode is the Odette ID of the partner, e.g. O0177PARTNER.
+	IdentificationCode string `json:"identificationCode"`
+	// Password is what the partner sends in its SSID.
+	Password string `json:"password"`
+	// LocalIdentificationCode and LocalPassword are what this station sends to the partner,
+	// if it isn't known to the partner by the defaults of the registry.
+	LocalIdentificationCode string `json:"localIdentificationCode,omitempty"`
+	LocalPassword           string `json:"localPassword,omitempty"`
+	// Address is where the partner accepts sessions, e.g. oftp.partner.example:6619.
+	Address string `json:"address,omitempty"`
+	// TLS connects to the partner over TLS, if it is set.
+	TLS                  *TLS `json:"tls,omitempty"`
+	BufferSize           int  `json:"bufferSize,omitempty"`
+	Credit               int  `json:"credit,omitempty"`
+	BufferCompression    bool `json:"bufferCompression"`
+	Restart              bool `json:"restart"`
+	SecureAuthentication bool `json:"secureAuthentication"`
+	// CertificateFile is the PEM file of the OFTP2 certificate of the partner,
+	// which is used for secure authentication, file services and signed receipts.
+	CertificateFile string `json:"certificate,omitempty"`
+	// Direction are the directions files may be exchanged in, which defaults to DirectionBoth.
+	Direction Direction `json:"direction,omitempty"`
+
+	certificate *x509.Certificate
+}
+
+// TLS are the settings to connect to a partner over TLS.
+type TLS struct {
+	// CAFile is the PEM file of the CAs, that are trusted for the partner. The system CAs are trusted when it is empty.
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the PEM files of the client certificate for mutual TLS.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// ServerName is checked against the certificate of the partner, which defaults to the host of Address.
+	ServerName string `json:"serverName,omitempty"`
+	// MinVersion is the lowest accepted TLS version, 1.2 or 1.3.
+	MinVersion string `json:"minVersion,omitempty"`
+}
+
+// Direction restricts the files exchanged with a partner.
+type Direction string
+
+const (
+	DirectionBoth    Direction = "both"
+	DirectionSend    Direction = "send"
+	DirectionReceive Direction = "receive"
+)
+
+// Capabilities are the SSID capabilities of this station for the direction.
+func (d Direction) Capabilities() oftp2.SsidCapability {
+	switch d {
+	case DirectionSend:
+		return oftp2.CapabilitySend
+	case DirectionReceive:
+		return oftp2.CapabilityReceive
+	}
+	return oftp2.CapabilityBoth
+}
+
+func (d Direction) valid() error {
+	switch d {
+	case "", DirectionBoth, DirectionSend, DirectionReceive:
+		return nil
+	}
+	return fmt.Errorf("unknown direction: %v", d)
+}
+
+// Certificate is the OFTP2 certificate of the partner, if it is known.
+func (p Partner) Certificate() *x509.Certificate {
+	return p.certificate
+}
+
+// TLSConfig is the config to connect to the partner over TLS.
+func (p Partner) TLSConfig() (*tls.Config, error) {
+	if p.TLS == nil {
+		return nil, errors.New("partner isn't connected over TLS")
+	}
+	config := &tls.Config{ServerName: p.TLS.ServerName}
+	switch p.TLS.MinVersion {
+	case "", "1.2":
+		config.MinVersion = tls.VersionTLS12
+	case "1.3":
+		config.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS version: %v", p.TLS.MinVersion)
+	}
+	if p.TLS.CAFile != "" {
+		content, err := os.ReadFile(p.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no certificate in %v", p.TLS.CAFile)
+		}
+	}
+	if p.TLS.CertFile != "" || p.TLS.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(p.TLS.CertFile, p.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+func (p Partner) valid() error {
+	if p.IdentificationCode == "" {
+		return errors.New("missing identification code")
+	} else if len(p.IdentificationCode) > 25 {
+		return fmt.Errorf("identification code is too long: %v", p.IdentificationCode)
+	} else if len(p.LocalIdentificationCode) > 25 {
+		return fmt.Errorf("local identification code is too long: %v", p.LocalIdentificationCode)
+	} else if len(p.Password) > 8 {
+		return errors.New("password is too long")
+	} else if len(p.LocalPassword) > 8 {
+		return errors.New("local password is too long")
+	} else if p.BufferSize != 0 && (p.BufferSize < oftp2.MinDataExchangeBufferSize || p.BufferSize > oftp2.MaxDataExchangeBufferSize) {
+		return fmt.Errorf("invalid buffer size: %d", p.BufferSize)
+	} else if p.Credit < 0 || p.Credit > 999 {
+		return fmt.Errorf("invalid credit: %d", p.Credit)
+	}
+	return p.Direction.valid()
+}
@@ -0,0 +1,216 @@
+package partner
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/session"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Registry holds the partners of this station with the defaults it uses towards them.
+//
+//	{
+//	  "identificationCode": "O0177ORGANISATION",
+//	  "password": "SECRET",
+//	  "certificate": "oftp.pem",
+//	  "key": "oftp.key",
+//	  "partners": [{
+//	    "name": "partner",
+//	    "identificationCode": "O0177PARTNER",
+//	    "password": "PARTNER",
+//	    "address": "oftp.partner.example:6619",
+//	    "tls": {"caFile": "partner-ca.pem"},
+//	    "bufferSize": 4096,
+//	    "credit": 64,
+//	    "restart": true,
+//	    "certificate": "partner.pem",
+//	    "direction": "both"
+//	  }]
+//	}
+//
+// Relative file names are resolved against the directory of the registry file.
+type Registry struct {
+	// IdentificationCode and Password are what this station sends to its partners by default.
+	IdentificationCode string `json:"identificationCode"`
+	Password           string `json:"password"`
+	// CertificateFile and KeyFile are the PEM files of the own OFTP2 certificate and RSA key.
+	CertificateFile string    `json:"certificate,omitempty"`
+	KeyFile         string    `json:"key,omitempty"`
+	Partners        []Partner `json:"partners"`
+
+	key         *rsa.PrivateKey
+	certificate *x509.Certificate
+	byCode      map[string]Partner
+	byName      map[string]Partner
+}
+
+// Load reads a registry from a JSON file.
+func Load(file string) (*Registry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f, filepath.Dir(file))
+}
+
+// Parse reads a registry in JSON. Relative file names are resolved against directory.
+// Partners are looked up by identification code and name, which must be unique.
+func Parse(r io.Reader, directory string) (*Registry, error) {
+	var registry Registry
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&registry); err != nil {
+		return nil, fmt.Errorf("invalid registry: %w", err)
+	}
+	if len(registry.IdentificationCode) > 25 {
+		return nil, fmt.Errorf("identification code is too long: %v", registry.IdentificationCode)
+	} else if len(registry.Password) > 8 {
+		return nil, errors.New("password is too long")
+	}
+	if registry.CertificateFile != "" || registry.KeyFile != "" {
+		var err error
+		registry.key, registry.certificate, err = cms.LoadKeyPair(resolve(directory, registry.CertificateFile), resolve(directory, registry.KeyFile))
+		if err != nil {
+			return nil, err
+		}
+	}
+	registry.byCode = map[string]Partner{}
+	registry.byName = map[string]Partner{}
+	for i, p := range registry.Partners {
+		if err := p.valid(); err != nil {
+			return nil, fmt.Errorf("partner %d: %w", i+1, err)
+		}
+		if p.Name == "" {
+			p.Name = Normalize(p.IdentificationCode)
+		}
+		if _, duplicate := registry.byCode[Normalize(p.IdentificationCode)]; duplicate {
+			return nil, fmt.Errorf("duplicate partner: %v", p.IdentificationCode)
+		} else if _, duplicate := registry.byName[p.Name]; duplicate {
+			return nil, fmt.Errorf("duplicate partner name: %v", p.Name)
+		}
+		if p.CertificateFile != "" {
+			certificate, err := cms.LoadCertificate(resolve(directory, p.CertificateFile))
+			if err != nil {
+				return nil, err
+			}
+			p.certificate = certificate
+		}
+		if p.TLS != nil {
+			tls := *p.TLS
+			tls.CAFile = resolve(directory, tls.CAFile)
+			tls.CertFile = resolve(directory, tls.CertFile)
+			tls.KeyFile = resolve(directory, tls.KeyFile)
+			p.TLS = &tls
+		}
+		registry.Partners[i] = p
+		registry.byCode[Normalize(p.IdentificationCode)] = p
+		registry.byName[p.Name] = p
+	}
+	return &registry, nil
+}
+
+// Lookup finds a partner by its identification code, as in SSIDCODE. Padding is ignored.
+func (r *Registry) Lookup(code oftp2.IdentificationCode) (Partner, error) {
+	p, known := r.byCode[Normalize(string(code))]
+	if !known {
+		return Partner{}, oftp2.NewEndSessionError(oftp2.EndSessionUserCodeNotKnown, fmt.Errorf("unknown partner: %v", Normalize(string(code))))
+	}
+	return p, nil
+}
+
+// Named finds a partner by its name.
+func (r *Registry) Named(name string) (Partner, error) {
+	p, known := r.byName[name]
+	if !known {
+		return Partner{}, fmt.Errorf("unknown partner: %v", name)
+	}
+	return p, nil
+}
+
+// Authenticate accepts the SSID of known partners with their password.
+func (r *Registry) Authenticate(ssid oftp2.StartSessionCmd) error {
+	p, err := r.Lookup(ssid.IdentificationCode())
+	if err != nil {
+		return err
+	} else if p.Password != strings.TrimSpace(string(ssid.Password())) {
+		return oftp2.NewEndSessionError(oftp2.EndSessionInvalidPassword, errors.New("invalid password"))
+	}
+	return nil
+}
+
+// Config is the session config for the partner, based on the config of this station.
+// The Store of base is kept, but files from the partner are refused, unless its direction allows receiving them.
+func (r *Registry) Config(p Partner, base session.Config) session.Config {
+	config := base
+	config.IdentificationCode = oftp2.IdentificationCode(fmt.Sprintf("%-25s", r.IdentificationCode))
+	if p.LocalIdentificationCode != "" {
+		config.IdentificationCode = oftp2.IdentificationCode(fmt.Sprintf("%-25s", p.LocalIdentificationCode))
+	}
+	config.Password = r.Password
+	if p.LocalPassword != "" {
+		config.Password = p.LocalPassword
+	}
+	if p.BufferSize != 0 {
+		config.DataExchangeBufferSize = p.BufferSize
+	}
+	if p.Credit != 0 {
+		config.Credit = p.Credit
+	}
+	config.BufferCompression = p.BufferCompression
+	config.Restart = p.Restart
+	config.SecureAuthentication = p.SecureAuthentication
+	config.Capabilities = p.Direction.Capabilities()
+	config.KeyStore = r
+	config.Authenticate = r.Authenticate
+	config.Profile = nil
+	return config
+}
+
+// Profile chooses the config of sessions as responder by the partner, see session.Config.
+func (r *Registry) Profile(base session.Config) func(remote oftp2.StartSessionCmd) (session.Config, error) {
+	return func(remote oftp2.StartSessionCmd) (session.Config, error) {
+		p, err := r.Lookup(remote.IdentificationCode())
+		if err != nil {
+			return session.Config{}, err
+		}
+		return r.Config(p, base), nil
+	}
+}
+
+// Key is the own OFTP2 key of this station, see cms.KeyStore.
+func (r *Registry) Key() (*rsa.PrivateKey, *x509.Certificate, error) {
+	if r.key == nil || r.certificate == nil {
+		return nil, nil, errors.New("missing own key")
+	}
+	return r.key, r.certificate, nil
+}
+
+// Certificate is the OFTP2 certificate of a partner, see cms.KeyStore.
+func (r *Registry) Certificate(code oftp2.IdentificationCode) (*x509.Certificate, error) {
+	p, known := r.byCode[Normalize(string(code))]
+	if !known || p.certificate == nil {
+		return nil, fmt.Errorf("missing certificate of partner: %v", Normalize(string(code)))
+	}
+	return p.certificate, nil
+}
+
+func resolve(directory, file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(directory, file)
+}
+
+// Normalize drops the padding of identification codes, so that they match however they were padded.
+func Normalize(code string) string {
+	return strings.Join(strings.Fields(code), "")
+}
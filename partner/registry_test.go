@@ -0,0 +1,236 @@
+package partner_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/partner"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const registryJSON = `{
+  "identificationCode": "O0177LOCAL",
+  "password": "LOCAL",
+  "certificate": "LOCAL.pem",
+  "key": "LOCAL.key",
+  "partners": [
+    {
+      "name": "partner",
+      "identificationCode": "O0177PARTNER",
+      "password": "PARTNER",
+      "localPassword": "FORPART",
+      "address": "oftp.partner.example:6619",
+      "tls": {"caFile": "PARTNER.pem", "minVersion": "1.3"},
+      "bufferSize": 2048,
+      "credit": 20,
+      "bufferCompression": true,
+      "restart": true,
+      "secureAuthentication": true,
+      "certificate": "PARTNER.pem",
+      "direction": "send"
+    },
+    {
+      "identificationCode": "O0177OTHER",
+      "password": "OTHER"
+    }
+  ]
+}`
+
+func TestLoad(t *testing.T) {
+	directory := t.TempDir()
+	testkeys.WriteRSA(t, directory, "LOCAL")
+	testkeys.WriteRSA(t, directory, "PARTNER")
+	file := filepath.Join(directory, "partners.json")
+	require.NoError(t, os.WriteFile(file, []byte(registryJSON), 0o600))
+
+	registry, err := partner.Load(file)
+	require.NoError(t, err)
+	require.Len(t, registry.Partners, 2)
+
+	named, err := registry.Named("partner")
+	require.NoError(t, err)
+	p, err := registry.Lookup(oftp2.IdentificationCode("O0177PARTNER             "))
+	require.NoError(t, err)
+	require.Equal(t, named.Name, p.Name)
+	require.Equal(t, "PARTNER", p.Certificate().Subject.CommonName)
+	require.Equal(t, filepath.Join(directory, "PARTNER.pem"), p.TLS.CAFile)
+
+	other, err := registry.Named("O0177OTHER")
+	require.NoError(t, err)
+	require.Nil(t, other.Certificate())
+	_, err = registry.Named("unknown")
+	require.EqualError(t, err, "unknown partner: unknown")
+	_, err = registry.Lookup(oftp2.IdentificationCode("O0177UNKNOWN"))
+	var endSessionErr oftp2.EndSessionError
+	require.True(t, errors.As(err, &endSessionErr))
+	require.Equal(t, oftp2.EndSessionUserCodeNotKnown, endSessionErr.Reason)
+
+	_, certificate, err := registry.Key()
+	require.NoError(t, err)
+	require.Equal(t, "LOCAL", certificate.Subject.CommonName)
+	certificate, err = registry.Certificate(oftp2.IdentificationCode("O0177PARTNER"))
+	require.NoError(t, err)
+	require.Equal(t, "PARTNER", certificate.Subject.CommonName)
+	_, err = registry.Certificate(oftp2.IdentificationCode("O0177OTHER"))
+	require.EqualError(t, err, "missing certificate of partner: O0177OTHER")
+
+	tlsConfig, err := p.TLSConfig()
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	require.NotNil(t, tlsConfig.RootCAs)
+	_, err = other.TLSConfig()
+	require.EqualError(t, err, "partner isn't connected over TLS")
+}
+
+func TestRegistry_Config(t *testing.T) {
+	registry, err := partner.Parse(strings.NewReader(`{
+  "identificationCode": "O0177LOCAL",
+  "password": "LOCAL",
+  "partners": [
+    {"name": "partner", "identificationCode": "O0177PARTNER", "password": "PARTNER", "localPassword": "FORPART", "bufferSize": 2048, "credit": 20, "restart": true, "direction": "send"},
+    {"name": "other", "identificationCode": "O0177OTHER", "password": "OTHER", "localIdentificationCode": "O0177ALIAS"}
+  ]
+}`), "")
+	require.NoError(t, err)
+	base := session.Config{DataExchangeBufferSize: 4096, Credit: 64, Store: nil}
+
+	p, err := registry.Named("partner")
+	require.NoError(t, err)
+	config := registry.Config(p, base)
+	require.Equal(t, oftp2.IdentificationCode("O0177LOCAL               "), config.IdentificationCode)
+	require.Equal(t, "FORPART", config.Password)
+	require.Equal(t, 2048, config.DataExchangeBufferSize)
+	require.Equal(t, 20, config.Credit)
+	require.True(t, config.Restart)
+	require.False(t, config.BufferCompression)
+	require.Equal(t, oftp2.CapabilitySend, config.Capabilities)
+
+	other, err := registry.Named("other")
+	require.NoError(t, err)
+	config = registry.Config(other, base)
+	require.Equal(t, oftp2.IdentificationCode("O0177ALIAS               "), config.IdentificationCode)
+	require.Equal(t, "LOCAL", config.Password)
+	require.Equal(t, 4096, config.DataExchangeBufferSize)
+	require.Equal(t, 64, config.Credit)
+	require.Equal(t, oftp2.CapabilityBoth, config.Capabilities)
+
+	config, err = registry.Profile(base)(sessionStart(t, "OTHER", "OTHER"))
+	require.NoError(t, err)
+	require.Equal(t, oftp2.IdentificationCode("O0177ALIAS               "), config.IdentificationCode)
+	_, err = registry.Profile(base)(sessionStart(t, "UNKNOWN", "OTHER"))
+	require.EqualError(t, err, "unknown partner: O0177UNKNOWN")
+}
+
+func TestRegistry_Authenticate(t *testing.T) {
+	registry, err := partner.Parse(strings.NewReader(`{"partners": [{"identificationCode": "O0177PARTNER", "password": "PASSWORD"}]}`), "")
+	require.NoError(t, err)
+	for _, scenario := range []struct {
+		with   string
+		ssid   oftp2.StartSessionCmd
+		reason oftp2.EndSessionReason
+		error  string
+	}{
+		{
+			with: "a known partner",
+			ssid: sessionStart(t, "PARTNER", "PASSWORD"),
+		},
+		{
+			with:   "an unknown partner",
+			ssid:   sessionStart(t, "OTHER", "PASSWORD"),
+			reason: oftp2.EndSessionUserCodeNotKnown,
+			error:  "unknown partner: O0177OTHER",
+		},
+		{
+			with:   "a wrong password",
+			ssid:   sessionStart(t, "PARTNER", "SECRET"),
+			reason: oftp2.EndSessionInvalidPassword,
+			error:  "invalid password",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			err := registry.Authenticate(scenario.ssid)
+			if scenario.error == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, scenario.error)
+			var endSessionErr oftp2.EndSessionError
+			require.True(t, errors.As(err, &endSessionErr))
+			require.Equal(t, scenario.reason, endSessionErr.Reason)
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, scenario := range []struct {
+		with     string
+		registry string
+		error    string
+	}{
+		{
+			with:     "no JSON",
+			registry: `partners`,
+			error:    "invalid registry: invalid character 'p' looking for beginning of value",
+		},
+		{
+			with:     "an unknown field",
+			registry: `{"partner": []}`,
+			error:    `invalid registry: json: unknown field "partner"`,
+		},
+		{
+			with:     "a missing identification code",
+			registry: `{"partners": [{"name": "partner"}]}`,
+			error:    "partner 1: missing identification code",
+		},
+		{
+			with:     "a long password",
+			registry: `{"partners": [{"identificationCode": "O0177PARTNER", "password": "TOO LONG PASSWORD"}]}`,
+			error:    "partner 1: password is too long",
+		},
+		{
+			with:     "an invalid buffer size",
+			registry: `{"partners": [{"identificationCode": "O0177PARTNER", "bufferSize": 100}]}`,
+			error:    "partner 1: invalid buffer size: 100",
+		},
+		{
+			with:     "an unknown direction",
+			registry: `{"partners": [{"identificationCode": "O0177PARTNER", "direction": "sideways"}]}`,
+			error:    "partner 1: unknown direction: sideways",
+		},
+		{
+			with:     "a duplicate partner",
+			registry: `{"partners": [{"identificationCode": "O0177PARTNER"}, {"identificationCode": "O0177 PARTNER", "name": "other"}]}`,
+			error:    "duplicate partner: O0177 PARTNER",
+		},
+		{
+			with:     "a duplicate name",
+			registry: `{"partners": [{"identificationCode": "O0177PARTNER", "name": "partner"}, {"identificationCode": "O0177OTHER", "name": "partner"}]}`,
+			error:    "duplicate partner name: partner",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			registry, err := partner.Parse(strings.NewReader(scenario.registry), "")
+			require.EqualError(t, err, scenario.error)
+			require.Nil(t, registry)
+		})
+	}
+}
+
+func sessionStart(t *testing.T, organisation, password string) oftp2.StartSessionCmd {
+	t.Helper()
+	ssid, err := oftp2.NewStartSession(oftp2.StartSessionInput{
+		IdentificationCode:     testkeys.IdentificationCode(t, organisation),
+		Password:               password,
+		DataExchangeBufferSize: 128,
+		Capabilities:           oftp2.CapabilityBoth,
+		Credit:                 1,
+	})
+	require.NoError(t, err)
+	return oftp2.StartSessionCmd(ssid)
+}
@@ -0,0 +1,418 @@
+// Package queue keeps files for partners until they are acknowledged, so that they reach partners that are offline.
+// A Scheduler delivers them with the client.
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/partner"
+	"github.com/elgohr/go-oftp2/record"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/elgohr/go-oftp2/storage"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is where a file is in its delivery.
+type State string
+
+const (
+	// StateQueued files wait to be sent.
+	StateQueued State = "queued"
+	// StateInFlight files are being sent.
+	StateInFlight State = "in-flight"
+	// StateSent files were confirmed by EFPA and wait for the EERP of the partner.
+	// Their destination is due again at NotBefore, to collect the EERP if the partner didn't send it yet.
+	StateSent State = "sent"
+	// StateAcknowledged files were acknowledged by EERP.
+	StateAcknowledged State = "acknowledged"
+	// StateFailed files were refused for good, by SFNA without retry, by EFNA, by NERP or by an EERP that failed its check.
+	StateFailed State = "failed"
+)
+
+// Entry is a file in the queue.
+type Entry struct {
+	ID string `json:"id"`
+	// StartFile describes the file, see session.VirtualFile.
+	StartFile oftp2.StartFileInput `json:"startFile"`
+	LocalForm record.LocalForm     `json:"localForm"`
+	// Priority orders the files of a destination. Higher priorities are sent first.
+	Priority int `json:"priority"`
+	// NotBefore delays the file until the given time.
+	// For sent files, it is when the partner is dialed to collect the EERP.
+	NotBefore time.Time `json:"notBefore"`
+	State     State     `json:"state"`
+	// Attempts counts the transmissions that didn't succeed.
+	Attempts int `json:"attempts"`
+	// Error is why the last transmission didn't succeed.
+	Error string `json:"error,omitempty"`
+	// Digest is the hash of the file as it was transmitted, if it asked for a signed receipt.
+	// A signed EERP is checked against it, even if it arrives in a later session.
+	Digest   []byte    `json:"digest,omitempty"`
+	Enqueued time.Time `json:"enqueued"`
+}
+
+// Destination is the Odette ID the file is addressed to, without padding, e.g. O0177PARTNER.
+func (e Entry) Destination() string {
+	return partner.Normalize(string(e.StartFile.Destination))
+}
+
+// Options are the delivery options of an enqueued file.
+type Options struct {
+	Priority  int
+	NotBefore time.Time
+}
+
+// Queue keeps files to be sent in a directory. It is safe for concurrent use.
+// The entries are kept in queue.json, the content of the files in the content directory.
+type Queue struct {
+	mutex   sync.Mutex
+	journal string
+	content storage.Storage
+	entries map[string]*Entry
+}
+
+// Open loads the queue kept in directory.
+// Files that were in flight when the queue was left are queued again.
+func Open(directory string) (*Queue, error) {
+	content, err := storage.NewFilesystem(filepath.Join(directory, "content"))
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		journal: filepath.Join(directory, "queue.json"),
+		content: content,
+		entries: map[string]*Entry{},
+	}
+	journal, err := os.ReadFile(q.journal)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	if err := json.Unmarshal(journal, &entries); err != nil {
+		return nil, fmt.Errorf("invalid queue: %w", err)
+	}
+	for _, e := range entries {
+		if e.State == StateInFlight {
+			e.State = StateQueued
+		}
+		q.entries[e.ID] = e
+	}
+	return q, nil
+}
+
+// Enqueue keeps a copy of the file, until it is delivered.
+// Sizes are taken from the content, when the file is sent.
+func (q *Queue) Enqueue(file session.VirtualFile, options Options) (Entry, error) {
+	if err := file.StartFile.Destination.Valid(); err != nil {
+		return Entry{}, fmt.Errorf("invalid destination: %w", err)
+	} else if file.StartFile.Name == "" {
+		return Entry{}, errors.New("missing name")
+	}
+	id, err := newID()
+	if err != nil {
+		return Entry{}, err
+	}
+	e := &Entry{
+		ID:        id,
+		StartFile: file.StartFile,
+		LocalForm: file.LocalForm,
+		Priority:  options.Priority,
+		NotBefore: options.NotBefore,
+		State:     StateQueued,
+		Enqueued:  time.Now(),
+	}
+	e.StartFile.TransmittedSize = 0
+	e.StartFile.OriginalSize = 0
+	e.StartFile.RestartPosition = 0
+	w, err := q.content.Create(contentOf(e))
+	if err != nil {
+		return Entry{}, err
+	}
+	if _, err := io.Copy(w, file.Content); err != nil {
+		_ = w.Abort()
+		return Entry{}, err
+	}
+	if err := w.Commit(); err != nil {
+		return Entry{}, err
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.entries[id] = e
+	if err := q.save(); err != nil {
+		delete(q.entries, id)
+		_ = q.content.Remove(contentOf(e))
+		return Entry{}, err
+	}
+	return *e, nil
+}
+
+// Entry is the entry of the given ID.
+func (q *Queue) Entry(id string) (Entry, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	e, known := q.entries[id]
+	if !known {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// Entries are all entries in the order they are delivered in.
+func (q *Queue) Entries() []Entry {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.sorted(func(*Entry) bool { return true })
+}
+
+// Due are the destinations with queued files, that may be sent at now,
+// and with sent files, whose EERP is overdue at now.
+func (q *Queue) Due(now time.Time) []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	var destinations []string
+	seen := map[string]bool{}
+	for _, e := range q.sorted(func(e *Entry) bool { return e.due(now) || e.overdue(now) }) {
+		if !seen[e.Destination()] {
+			seen[e.Destination()] = true
+			destinations = append(destinations, e.Destination())
+		}
+	}
+	return destinations
+}
+
+// Remove drops a file that isn't in flight from the queue.
+func (q *Queue) Remove(id string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	e, known := q.entries[id]
+	if !known {
+		return fmt.Errorf("unknown entry: %v", id)
+	} else if e.State == StateInFlight {
+		return fmt.Errorf("entry is in flight: %v", id)
+	}
+	delete(q.entries, id)
+	if err := q.save(); err != nil {
+		q.entries[id] = e
+		return err
+	}
+	if err := q.content.Remove(contentOf(e)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Receipt applies an end to end response of a partner to the file it responds to.
+// EERP acknowledges the file, NERP fails it. It tells whether a sent file matched the response.
+func (q *Queue) Receipt(msg oftp2.Message) (bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, e := range q.entries {
+		if e.State != StateSent {
+			continue
+		}
+		sfid, err := oftp2.NewStartFile(e.StartFile)
+		if err != nil {
+			continue
+		}
+		switch m := msg.(type) {
+		case oftp2.EndToEndResponseCmd:
+			if !m.Matches(oftp2.StartFileCmd(sfid)) {
+				continue
+			}
+			e.State = StateAcknowledged
+			e.Error = ""
+			if err := q.save(); err != nil {
+				return true, err
+			}
+			// the partner took over the file, so that its content isn't needed anymore
+			_ = q.content.Remove(contentOf(e))
+			return true, nil
+		case oftp2.NegativeEndResponseCmd:
+			if !m.Matches(oftp2.StartFileCmd(sfid)) {
+				continue
+			}
+			e.State = StateFailed
+			e.Error = fmt.Sprintf("file rejected by partner with reason %d: %s", m.ReasonCode(), m.ReasonText())
+			return true, q.save()
+		}
+	}
+	return false, nil
+}
+
+// InvalidReceipt fails the sent file, whose signed EERP didn't pass the check of its signature or hash.
+// The partner answered already, so that waiting for another EERP is pointless.
+// It tells whether a sent file matched the response.
+func (q *Queue) InvalidReceipt(invalid session.ReceiptError) (bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, e := range q.entries {
+		if e.State != StateSent {
+			continue
+		}
+		sfid, err := oftp2.NewStartFile(e.StartFile)
+		if err != nil || !invalid.Receipt.Matches(oftp2.StartFileCmd(sfid)) {
+			continue
+		}
+		e.State = StateFailed
+		e.Error = invalid.Error()
+		return true, q.save()
+	}
+	return false, nil
+}
+
+// SentDigest is the hash of the sent file, that eerp responds to, see session.Config.
+func (q *Queue) SentDigest(eerp oftp2.EndToEndResponseCmd) []byte {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, e := range q.entries {
+		if e.State != StateSent || e.Digest == nil {
+			continue
+		}
+		sfid, err := oftp2.NewStartFile(e.StartFile)
+		if err == nil && eerp.Matches(oftp2.StartFileCmd(sfid)) {
+			return e.Digest
+		}
+	}
+	return nil
+}
+
+// Config is base with the hooks, that apply the end to end responses of partners to the queue.
+// Sessions accepted as responder need it as well, as partners may send their responses in a session of their own.
+func (q *Queue) Config(base session.Config) session.Config {
+	config := base
+	config.Receipt = func(msg oftp2.Message) error {
+		_, err := q.Receipt(msg)
+		return err
+	}
+	config.InvalidReceipt = func(invalid session.ReceiptError) error {
+		_, err := q.InvalidReceipt(invalid)
+		return err
+	}
+	config.SentDigest = q.SentDigest
+	return config
+}
+
+// next starts the next file due for destination, which is returned with its content.
+func (q *Queue) next(destination string, now time.Time) (Entry, io.ReadCloser, bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	due := q.sorted(func(e *Entry) bool { return e.Destination() == destination && e.due(now) })
+	if len(due) == 0 {
+		return Entry{}, nil, false, nil
+	}
+	e := q.entries[due[0].ID]
+	content, err := q.content.Open(contentOf(e))
+	if err != nil {
+		return Entry{}, nil, false, err
+	}
+	e.State = StateInFlight
+	if err := q.save(); err != nil {
+		_ = content.Close()
+		e.State = StateQueued
+		return Entry{}, nil, false, err
+	}
+	return *e, content, true, nil
+}
+
+// update replaces an entry after an attempt to send it.
+// The previous entry is kept, if the journal can't be saved.
+func (q *Queue) update(e Entry) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	previous, known := q.entries[e.ID]
+	if !known {
+		return fmt.Errorf("unknown entry: %v", e.ID)
+	}
+	q.entries[e.ID] = &e
+	if err := q.save(); err != nil {
+		q.entries[e.ID] = previous
+		return err
+	}
+	return nil
+}
+
+// sorted are the entries matching filter by destination, priority and the time they were enqueued.
+func (q *Queue) sorted(filter func(*Entry) bool) []Entry {
+	var entries []Entry
+	for _, e := range q.entries {
+		if filter(e) {
+			entries = append(entries, *e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Destination() != b.Destination() {
+			return a.Destination() < b.Destination()
+		} else if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		} else if !a.Enqueued.Equal(b.Enqueued) {
+			return a.Enqueued.Before(b.Enqueued)
+		}
+		return a.ID < b.ID
+	})
+	return entries
+}
+
+// save replaces the journal at once, so that a crash keeps the previous one.
+func (q *Queue) save() error {
+	entries := q.sorted(func(*Entry) bool { return true })
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	temporary := q.journal + ".tmp"
+	f, err := os.OpenFile(temporary, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary, q.journal)
+}
+
+func (e *Entry) due(now time.Time) bool {
+	return e.State == StateQueued && !now.Before(e.NotBefore)
+}
+
+// overdue tells whether the EERP of a sent file should be collected at now.
+func (e *Entry) overdue(now time.Time) bool {
+	return e.State == StateSent && !now.Before(e.NotBefore)
+}
+
+// contentOf is the identity the content of an entry is stored under.
+func contentOf(e *Entry) storage.Identity {
+	return storage.Identity{
+		Name:        e.ID,
+		Date:        e.Enqueued,
+		Originator:  partner.Normalize(string(e.StartFile.Origin)),
+		Destination: e.Destination(),
+	}
+}
+
+func newID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
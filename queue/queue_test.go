@@ -0,0 +1,170 @@
+package queue_test
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/queue"
+	"github.com/elgohr/go-oftp2/record"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	directory := t.TempDir()
+	q, err := queue.Open(directory)
+	require.NoError(t, err)
+
+	later := time.Now().Add(time.Hour)
+	low, err := q.Enqueue(virtualFile(t, "LOW", "PARTNER", "CONTENT\n"), queue.Options{Priority: 1})
+	require.NoError(t, err)
+	high, err := q.Enqueue(virtualFile(t, "HIGH", "PARTNER", "CONTENT\n"), queue.Options{Priority: 5})
+	require.NoError(t, err)
+	delayed, err := q.Enqueue(virtualFile(t, "DELAYED", "OTHER", "CONTENT\n"), queue.Options{NotBefore: later})
+	require.NoError(t, err)
+	require.Equal(t, queue.StateQueued, low.State)
+	require.Equal(t, "O0177PARTNER", low.Destination())
+
+	require.Equal(t, []string{delayed.ID, high.ID, low.ID}, ids(q.Entries()))
+	require.Equal(t, []string{"O0177PARTNER"}, q.Due(time.Now()))
+	require.Equal(t, []string{"O0177OTHER", "O0177PARTNER"}, q.Due(later))
+
+	reopened, err := queue.Open(directory)
+	require.NoError(t, err)
+	require.Equal(t, ids(q.Entries()), ids(reopened.Entries()))
+	entry, known := reopened.Entry(high.ID)
+	require.True(t, known)
+	require.Equal(t, "HIGH", entry.StartFile.Name)
+	require.Equal(t, 5, entry.Priority)
+	require.Equal(t, record.LocalFormLF, entry.LocalForm)
+
+	require.NoError(t, reopened.Remove(delayed.ID))
+	_, known = reopened.Entry(delayed.ID)
+	require.False(t, known)
+	require.EqualError(t, reopened.Remove(delayed.ID), "unknown entry: "+delayed.ID)
+	content, err := os.ReadDir(filepath.Join(directory, "content"))
+	require.NoError(t, err)
+	require.Len(t, content, 2)
+}
+
+func TestOpen_InFlight(t *testing.T) {
+	directory := t.TempDir()
+	q, err := queue.Open(directory)
+	require.NoError(t, err)
+	entry, err := q.Enqueue(virtualFile(t, "INTERRUPTED", "PARTNER", "CONTENT\n"), queue.Options{})
+	require.NoError(t, err)
+
+	journal := filepath.Join(directory, "queue.json")
+	content, err := os.ReadFile(journal)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(journal, []byte(strings.Replace(string(content), `"queued"`, `"in-flight"`, 1)), 0o600))
+
+	reopened, err := queue.Open(directory)
+	require.NoError(t, err)
+	entry, known := reopened.Entry(entry.ID)
+	require.True(t, known)
+	require.Equal(t, queue.StateQueued, entry.State)
+}
+
+func TestQueue_Config(t *testing.T) {
+	directory := t.TempDir()
+	q, err := queue.Open(directory)
+	require.NoError(t, err)
+	file := virtualFile(t, "SENT", "PARTNER", "CONTENT\n")
+	sent, err := q.Enqueue(file, queue.Options{})
+	require.NoError(t, err)
+	invalid := virtualFile(t, "INVALID", "PARTNER", "CONTENT\n")
+	rejected, err := q.Enqueue(invalid, queue.Options{})
+	require.NoError(t, err)
+
+	journal := filepath.Join(directory, "queue.json")
+	content, err := os.ReadFile(journal)
+	require.NoError(t, err)
+	content = []byte(strings.Replace(string(content), `"queued"`, `"sent"`, -1))
+	content = []byte(strings.Replace(string(content), `"enqueued"`, `"digest": "SEFTSA==", "enqueued"`, -1))
+	require.NoError(t, os.WriteFile(journal, content, 0o600))
+	q, err = queue.Open(directory)
+	require.NoError(t, err)
+
+	sfid, err := oftp2.NewStartFile(file.StartFile)
+	require.NoError(t, err)
+	eerp, err := oftp2.NewEndToEndResponse(oftp2.EndToEndResponseInputFor(oftp2.StartFileCmd(sfid)))
+	require.NoError(t, err)
+	config := q.Config(session.Config{Password: "PASSWORD"})
+	require.Equal(t, "PASSWORD", config.Password)
+	require.Equal(t, []byte("HASH"), config.SentDigest(oftp2.EndToEndResponseCmd(eerp)))
+
+	require.NoError(t, config.Receipt(oftp2.EndToEndResponseCmd(eerp)))
+	entry, _ := q.Entry(sent.ID)
+	require.Equal(t, queue.StateAcknowledged, entry.State)
+	require.Nil(t, config.SentDigest(oftp2.EndToEndResponseCmd(eerp)))
+
+	sfid, err = oftp2.NewStartFile(invalid.StartFile)
+	require.NoError(t, err)
+	eerp, err = oftp2.NewEndToEndResponse(oftp2.EndToEndResponseInputFor(oftp2.StartFileCmd(sfid)))
+	require.NoError(t, err)
+	require.NoError(t, config.InvalidReceipt(session.ReceiptError{Receipt: oftp2.EndToEndResponseCmd(eerp), Err: errors.New("invalid signature")}))
+	entry, _ = q.Entry(rejected.ID)
+	require.Equal(t, queue.StateFailed, entry.State)
+	require.Equal(t, "invalid receipt for INVALID: invalid signature", entry.Error)
+}
+
+func TestQueue_EnqueueInvalid(t *testing.T) {
+	q, err := queue.Open(t.TempDir())
+	require.NoError(t, err)
+
+	file := virtualFile(t, "INVALID", "PARTNER", "CONTENT\n")
+	file.StartFile.Destination = nil
+	_, err = q.Enqueue(file, queue.Options{})
+	require.EqualError(t, err, "invalid destination: expected the length of 25, but got 0")
+
+	file = virtualFile(t, "", "PARTNER", "CONTENT\n")
+	_, err = q.Enqueue(file, queue.Options{})
+	require.EqualError(t, err, "missing name")
+	require.Empty(t, q.Entries())
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	directory := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(directory, "queue.json"), []byte("queue"), 0o600))
+	_, err := queue.Open(directory)
+	require.EqualError(t, err, "invalid queue: invalid character 'q' looking for beginning of value")
+}
+
+func virtualFile(t *testing.T, name, destination, content string) session.VirtualFile {
+	t.Helper()
+	stamp, err := oftp2.NewTimeStamp([]byte("20200102030405060708"))
+	require.NoError(t, err)
+	to, err := oftp2.NewSid(oftp2.SidInput{CodeDesignator: "0177", OrganisationCode: destination})
+	require.NoError(t, err)
+	from, err := oftp2.NewSid(oftp2.SidInput{CodeDesignator: "0177", OrganisationCode: "CLIENT"})
+	require.NoError(t, err)
+	return session.VirtualFile{
+		StartFile: oftp2.StartFileInput{
+			Name:          name,
+			Date:          stamp,
+			Destination:   to,
+			Origin:        from,
+			Format:        oftp2.FileFormatVariable,
+			MaxRecordSize: 80,
+			Security:      oftp2.SecurityNoServices,
+			Cipher:        oftp2.NoCipher,
+			Compression:   oftp2.NoCompression,
+			Envelope:      oftp2.NoEnvelope,
+		},
+		LocalForm: record.LocalFormLF,
+		Content:   strings.NewReader(content),
+	}
+}
+
+func ids(entries []queue.Entry) []string {
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
@@ -0,0 +1,236 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/client"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/partner"
+	"github.com/elgohr/go-oftp2/session"
+	"time"
+)
+
+// Scheduler delivers the files of a queue to the partners they are addressed to.
+//
+// A file refused by SFNA is queued again, if the partner asks to retry it, otherwise it fails.
+// EFNA carries no retry indicator, so that a file refused by it fails.
+// Files whose transmission broke off are queued again and restarted at the interrupted position, if restart was agreed.
+// Partners that don't send the EERP of a file in the session it was sent in, are dialed again after ReceiptTimeout
+// to collect it. Partners may as well send it in a session of their own, which a responder applies by Queue.Config.
+type Scheduler struct {
+	Queue *Queue
+	// Dial starts a session with the partner a destination is delivered to.
+	// Its config should set SentDigest to Queue.SentDigest, so that signed EERPs of files sent in earlier sessions are checked.
+	// It doesn't need Queue.Config, which is meant for responders, as Deliver applies the end to end responses
+	// of the session to the queue itself, so that failures of the queue are returned.
+	Dial func(ctx context.Context, destination string) (*client.Session, error)
+	// Backoff delays a file after a failed attempt, doubling with every further attempt up to MaxBackoff.
+	// They default to a minute and an hour.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts fails a file after the given number of failed attempts. Zero retries forever.
+	MaxAttempts int
+	// Interval is how often Run looks for due files, which defaults to a minute.
+	Interval time.Duration
+	// ReceiptTimeout is how long a sent file waits for its EERP, before the partner is dialed to collect it.
+	// It defaults to an hour.
+	ReceiptTimeout time.Duration
+	// OnError is called by Run with failures of the queue itself, e.g. when its journal can't be written.
+	// Without it, Run returns them.
+	OnError func(err error)
+}
+
+// NewScheduler delivers the files of queue to the partners of registry, whose identification code matches the destination.
+// The session config of the partners is based on config and checks signed EERPs against the files sent to them.
+func NewScheduler(queue *Queue, registry *partner.Registry, config client.Config) *Scheduler {
+	config.SentDigest = queue.SentDigest
+	return &Scheduler{
+		Queue: queue,
+		Dial: func(ctx context.Context, destination string) (*client.Session, error) {
+			p, err := registry.Lookup(oftp2.IdentificationCode(destination))
+			if err != nil {
+				return nil, err
+			}
+			return client.DialPartner(ctx, registry, p.Name, config)
+		},
+	}
+}
+
+// Run delivers due files every Interval, until ctx is done or the queue fails without OnError.
+func (s *Scheduler) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval == 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Deliver(ctx); err != nil {
+			if s.OnError == nil {
+				return err
+			}
+			s.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver sends the due files of every destination in one session per destination.
+// The session collects the end to end responses of the partner as well, which acknowledge the sent files.
+// Destinations with sent files, whose EERP is overdue, are dialed only to collect them.
+// Failed attempts are kept in the entries, only errors of the queue itself are returned.
+func (s *Scheduler) Deliver(ctx context.Context) error {
+	for _, destination := range s.Queue.Due(time.Now()) {
+		if err := s.deliver(ctx, destination); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) deliver(ctx context.Context, destination string) error {
+	partnerSession, err := s.Dial(ctx, destination)
+	if err != nil {
+		return s.postpone(destination, fmt.Errorf("dialing partner: %w", err))
+	}
+	for !partnerSession.Ended() {
+		if partnerSession.MustChangeDirection() {
+			// the partner sends its files or end to end responses first
+			if _, err := partnerSession.Receive(ctx); err != nil {
+				break
+			}
+			continue
+		}
+		e, content, ok, err := s.Queue.next(destination, time.Now())
+		if err != nil {
+			_ = partnerSession.Close()
+			return err
+		} else if !ok {
+			break
+		}
+		err = partnerSession.Send(ctx, client.VirtualFile{StartFile: e.StartFile, LocalForm: e.LocalForm, Content: content})
+		_ = content.Close()
+		if sfid, sfidErr := oftp2.NewStartFile(e.StartFile); err == nil && sfidErr == nil {
+			e.Digest = partnerSession.Digest(oftp2.StartFileCmd(sfid))
+		}
+		if err := s.Queue.update(s.outcome(e, err)); err != nil {
+			_ = partnerSession.Close()
+			return err
+		}
+	}
+	if !partnerSession.Ended() {
+		// the partner sends its end to end responses as speaker
+		_, _ = partnerSession.Receive(ctx)
+	}
+	// the scheduler applies the responses of its sessions itself, the hooks of Queue.Config serve responders
+	for _, receipt := range partnerSession.Receipts() {
+		if _, err := s.Queue.Receipt(receipt); err != nil {
+			_ = partnerSession.Close()
+			return err
+		}
+	}
+	for _, invalid := range partnerSession.InvalidReceipts() {
+		if _, err := s.Queue.InvalidReceipt(invalid); err != nil {
+			_ = partnerSession.Close()
+			return err
+		}
+	}
+	_ = partnerSession.Close()
+	return s.await(destination)
+}
+
+// outcome is the entry after an attempt to send it.
+func (s *Scheduler) outcome(e Entry, err error) Entry {
+	var refusal oftp2.StartFileError
+	var endFileErr oftp2.EndFileError
+	var interrupted session.InterruptedError
+	switch {
+	case err == nil:
+		e.State = StateSent
+		e.Error = ""
+		e.StartFile.RestartPosition = 0
+		e.NotBefore = time.Now().Add(s.receiptTimeout())
+		return e
+	case errors.As(err, &refusal) && !refusal.Retry:
+		return failed(e, err)
+	case errors.As(err, &endFileErr):
+		return failed(e, err)
+	case errors.As(err, &interrupted):
+		e.StartFile.RestartPosition = interrupted.Position
+	}
+	return s.retry(e, err)
+}
+
+// retry queues an entry again after its backoff, unless it ran out of attempts.
+func (s *Scheduler) retry(e Entry, err error) Entry {
+	e.Attempts++
+	if s.MaxAttempts > 0 && e.Attempts >= s.MaxAttempts {
+		return failed(e, err)
+	}
+	e.State = StateQueued
+	e.Error = err.Error()
+	e.NotBefore = time.Now().Add(s.backoff(e.Attempts))
+	return e
+}
+
+// postpone retries the due files of a destination, which couldn't be reached.
+func (s *Scheduler) postpone(destination string, err error) error {
+	now := time.Now()
+	for _, e := range s.Queue.Entries() {
+		if e.Destination() == destination && e.due(now) {
+			if err := s.Queue.update(s.retry(e, err)); err != nil {
+				return err
+			}
+		}
+	}
+	return s.await(destination)
+}
+
+// await waits another ReceiptTimeout for the overdue EERPs of a destination, which weren't collected.
+func (s *Scheduler) await(destination string) error {
+	now := time.Now()
+	for _, e := range s.Queue.Entries() {
+		if e.Destination() == destination && e.overdue(now) {
+			e.NotBefore = now.Add(s.receiptTimeout())
+			if err := s.Queue.update(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) receiptTimeout() time.Duration {
+	if s.ReceiptTimeout == 0 {
+		return time.Hour
+	}
+	return s.ReceiptTimeout
+}
+
+func (s *Scheduler) backoff(attempts int) time.Duration {
+	backoff, limit := s.Backoff, s.MaxBackoff
+	if backoff == 0 {
+		backoff = time.Minute
+	}
+	if limit == 0 {
+		limit = time.Hour
+	}
+	for i := 1; i < attempts && backoff < limit; i++ {
+		backoff *= 2
+	}
+	if backoff > limit {
+		return limit
+	}
+	return backoff
+}
+
+func failed(e Entry, err error) Entry {
+	e.State = StateFailed
+	e.Error = err.Error()
+	return e
+}
@@ -0,0 +1,412 @@
+package queue_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/client"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/fileservices"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/queue"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/elgohr/go-oftp2/storage"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Deliver(t *testing.T) {
+	q, err := queue.Open(t.TempDir())
+	require.NoError(t, err)
+	first, err := q.Enqueue(virtualFile(t, "FIRST", "PARTNER", "FIRST\n"), queue.Options{})
+	require.NoError(t, err)
+	second, err := q.Enqueue(virtualFile(t, "SECOND", "PARTNER", "SECOND\n"), queue.Options{Priority: 1})
+	require.NoError(t, err)
+	delayed, err := q.Enqueue(virtualFile(t, "DELAYED", "PARTNER", "DELAYED\n"), queue.Options{NotBefore: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	store := storage.NewMemory()
+	address, done := responder(t, store)
+	scheduler := &queue.Scheduler{Queue: q, Dial: dial(t, address)}
+	require.NoError(t, scheduler.Deliver(context.Background()))
+	require.NoError(t, <-done)
+
+	require.Equal(t, "FIRST\n", stored(t, store, "FIRST"))
+	require.Equal(t, "SECOND\n", stored(t, store, "SECOND"))
+	for _, id := range []string{first.ID, second.ID} {
+		entry, _ := q.Entry(id)
+		require.Equal(t, queue.StateAcknowledged, entry.State)
+	}
+	entry, _ := q.Entry(delayed.ID)
+	require.Equal(t, queue.StateQueued, entry.State)
+	require.Empty(t, q.Due(time.Now()))
+}
+
+func TestScheduler_ChangeDirection(t *testing.T) {
+	q, err := queue.Open(t.TempDir())
+	require.NoError(t, err)
+	first, err := q.Enqueue(virtualFile(t, "FIRST", "PARTNER", "FIRST\n"), queue.Options{Priority: 1})
+	require.NoError(t, err)
+	second, err := q.Enqueue(virtualFile(t, "SECOND", "PARTNER", "SECOND\n"), queue.Options{})
+	require.NoError(t, err)
+
+	// the partner asks to change direction after the first file, to send its own file
+	store := storage.NewMemory()
+	back := virtualFile(t, "BACK", "CLIENT", "BACK\n")
+	address, done := responder(t, store, back)
+	received := storage.NewMemory()
+	scheduler := &queue.Scheduler{
+		Queue: q,
+		Dial: func(ctx context.Context, destination string) (*client.Session, error) {
+			return client.Dial(ctx, address, client.Config{
+				IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+				Password:               "CLIENT",
+				DataExchangeBufferSize: 128,
+				Credit:                 1,
+				Store:                  received,
+			})
+		},
+	}
+	require.NoError(t, scheduler.Deliver(context.Background()))
+	require.NoError(t, <-done)
+
+	require.Equal(t, "FIRST\n", stored(t, store, "FIRST"))
+	require.Equal(t, "SECOND\n", stored(t, store, "SECOND"))
+	for _, id := range []string{first.ID, second.ID} {
+		entry, _ := q.Entry(id)
+		require.Equal(t, queue.StateAcknowledged, entry.State)
+		require.Zero(t, entry.Attempts)
+	}
+	sfid, err := oftp2.NewStartFile(back.StartFile)
+	require.NoError(t, err)
+	r, err := received.Open(storage.IdentityOf(oftp2.StartFileCmd(sfid)))
+	require.NoError(t, err)
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "BACK\n", string(content))
+}
+
+func TestScheduler_Refused(t *testing.T) {
+	for _, scenario := range []struct {
+		with     string
+		retry    bool
+		state    queue.State
+		attempts int
+	}{
+		{
+			with:     "retry",
+			retry:    true,
+			state:    queue.StateQueued,
+			attempts: 1,
+		},
+		{
+			with:  "no retry",
+			state: queue.StateFailed,
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			q, err := queue.Open(t.TempDir())
+			require.NoError(t, err)
+			refused, err := q.Enqueue(virtualFile(t, "REFUSED", "PARTNER", "CONTENT\n"), queue.Options{})
+			require.NoError(t, err)
+
+			address, done := responder(t, refusingStorage{Memory: storage.NewMemory(), retry: scenario.retry})
+			scheduler := &queue.Scheduler{Queue: q, Dial: dial(t, address), Backoff: time.Hour}
+			require.NoError(t, scheduler.Deliver(context.Background()))
+			require.NoError(t, <-done)
+
+			entry, _ := q.Entry(refused.ID)
+			require.Equal(t, scenario.state, entry.State)
+			require.Equal(t, scenario.attempts, entry.Attempts)
+			require.Equal(t, "file refused by partner with reason 99: storage is busy", entry.Error)
+			require.Empty(t, q.Due(time.Now()))
+		})
+	}
+}
+
+func TestScheduler_Unreachable(t *testing.T) {
+	q, err := queue.Open(t.TempDir())
+	require.NoError(t, err)
+	unreachable, err := q.Enqueue(virtualFile(t, "UNREACHABLE", "PARTNER", "CONTENT\n"), queue.Options{})
+	require.NoError(t, err)
+	scheduler := &queue.Scheduler{
+		Queue: q,
+		Dial: func(ctx context.Context, destination string) (*client.Session, error) {
+			require.Equal(t, "O0177PARTNER", destination)
+			return nil, errors.New("connection refused")
+		},
+		Backoff:     time.Minute,
+		MaxAttempts: 2,
+	}
+
+	require.NoError(t, scheduler.Deliver(context.Background()))
+	entry, _ := q.Entry(unreachable.ID)
+	require.Equal(t, queue.StateQueued, entry.State)
+	require.Equal(t, 1, entry.Attempts)
+	require.Equal(t, "dialing partner: connection refused", entry.Error)
+	require.WithinDuration(t, time.Now().Add(time.Minute), entry.NotBefore, 5*time.Second)
+	require.Equal(t, []string{"O0177PARTNER"}, q.Due(entry.NotBefore))
+
+	// a further attempt runs out of attempts
+	require.NoError(t, q.Remove(unreachable.ID))
+	unreachable, err = q.Enqueue(virtualFile(t, "UNREACHABLE", "PARTNER", "CONTENT\n"), queue.Options{})
+	require.NoError(t, err)
+	scheduler.MaxAttempts = 1
+	require.NoError(t, scheduler.Deliver(context.Background()))
+	entry, _ = q.Entry(unreachable.ID)
+	require.Equal(t, queue.StateFailed, entry.State)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	directory := t.TempDir()
+	q, err := queue.Open(directory)
+	require.NoError(t, err)
+	_, err = q.Enqueue(virtualFile(t, "UNWRITTEN", "PARTNER", "CONTENT\n"), queue.Options{})
+	require.NoError(t, err)
+	// the journal can't be replaced anymore
+	require.NoError(t, os.Mkdir(filepath.Join(directory, "queue.json.tmp"), 0o700))
+	unreachable := func(ctx context.Context, destination string) (*client.Session, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	scheduler := &queue.Scheduler{Queue: q, Dial: unreachable, Interval: time.Millisecond}
+	err = scheduler.Run(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "queue.json.tmp")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var failures []error
+	scheduler.OnError = func(err error) {
+		failures = append(failures, err)
+		if len(failures) == 2 {
+			cancel()
+		}
+	}
+	require.Equal(t, context.Canceled, scheduler.Run(ctx))
+	require.Len(t, failures, 2)
+}
+
+func TestScheduler_LateReceipt(t *testing.T) {
+	clientKey, clientCertificate := testkeys.RSA(t, "CLIENT")
+	partnerKey, partnerCertificate := testkeys.RSA(t, "PARTNER")
+	digest := sha1.Sum([]byte("TRANSMITTED"))
+	other := sha1.Sum([]byte("OTHER"))
+	file := virtualFile(t, "LATE", "PARTNER", "CONTENT\n")
+	file.StartFile.Cipher = oftp2.CipherAes256Cbc
+	file.StartFile.SignedReceipt = true
+	sfid, err := oftp2.NewStartFile(file.StartFile)
+	require.NoError(t, err)
+	signed := func(hash []byte) oftp2.EndToEndResponseCmd {
+		eerp, err := fileservices.SignReceipt(oftp2.EndToEndResponseInputFor(oftp2.StartFileCmd(sfid)), hash, oftp2.CipherAes256Cbc, fileservices.Keys{Key: partnerKey, Certificate: partnerCertificate})
+		require.NoError(t, err)
+		return eerp
+	}
+	tampered := func() oftp2.EndToEndResponseCmd {
+		input := oftp2.EndToEndResponseInputFor(oftp2.StartFileCmd(sfid))
+		input.Hash = digest[:]
+		input.Signature = append([]byte{}, signed(digest[:]).Signature()...)
+		input.Signature[len(input.Signature)-1] ^= 0xff
+		eerp, err := oftp2.NewEndToEndResponse(input)
+		require.NoError(t, err)
+		return oftp2.EndToEndResponseCmd(eerp)
+	}
+	for _, scenario := range []struct {
+		with  string
+		eerp  oftp2.EndToEndResponseCmd
+		state queue.State
+		error string
+	}{
+		{with: "the hash of the sent file", eerp: signed(digest[:]), state: queue.StateAcknowledged},
+		{with: "another hash", eerp: signed(other[:]), state: queue.StateFailed, error: "invalid receipt for LATE: "},
+		{with: "a tampered signature", eerp: tampered(), state: queue.StateFailed, error: "invalid receipt for LATE: "},
+		{with: "no receipt", state: queue.StateSent},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			directory := t.TempDir()
+			q, err := queue.Open(directory)
+			require.NoError(t, err)
+			sent, err := q.Enqueue(file, queue.Options{})
+			require.NoError(t, err)
+
+			// the file was sent in an earlier session, which ended before the EERP arrived
+			journal := filepath.Join(directory, "queue.json")
+			content, err := os.ReadFile(journal)
+			require.NoError(t, err)
+			content = []byte(strings.Replace(string(content), `"queued"`, `"sent"`, 1))
+			content = []byte(strings.Replace(string(content), `"enqueued"`, `"digest": "`+base64.StdEncoding.EncodeToString(digest[:])+`", "enqueued"`, 1))
+			require.NoError(t, os.WriteFile(journal, content, 0o600))
+			q, err = queue.Open(directory)
+			require.NoError(t, err)
+			require.Equal(t, []string{"O0177PARTNER"}, q.Due(time.Now()))
+
+			address, done := lateResponder(t, scenario.eerp)
+			scheduler := &queue.Scheduler{
+				Queue: q,
+				Dial: func(ctx context.Context, destination string) (*client.Session, error) {
+					return client.Dial(ctx, address, client.Config{
+						IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+						Password:               "CLIENT",
+						DataExchangeBufferSize: 128,
+						Credit:                 1,
+						KeyStore: cms.StaticKeyStore{
+							PrivateKey:     clientKey,
+							OwnCertificate: clientCertificate,
+							Partners:       map[string]*x509.Certificate{"O0177PARTNER": partnerCertificate},
+						},
+						SentDigest: q.SentDigest,
+					})
+				},
+				ReceiptTimeout: time.Hour,
+			}
+			require.NoError(t, scheduler.Deliver(context.Background()))
+			require.NoError(t, <-done)
+
+			entry, _ := q.Entry(sent.ID)
+			require.Equal(t, scenario.state, entry.State)
+			require.True(t, strings.HasPrefix(entry.Error, scenario.error), entry.Error)
+			require.Empty(t, q.Due(time.Now()))
+			if scenario.state == queue.StateSent {
+				// the partner is dialed again to collect the EERP
+				require.WithinDuration(t, time.Now().Add(time.Hour), entry.NotBefore, 5*time.Second)
+			}
+		})
+	}
+}
+
+// lateResponder accepts a single session of the scheduler, in which it only sends eerp, unless it is nil.
+func lateResponder(t *testing.T, eerp oftp2.EndToEndResponseCmd) (string, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	ssid, err := oftp2.NewStartSession(oftp2.StartSessionInput{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Capabilities:           oftp2.CapabilityBoth,
+		Credit:                 1,
+	})
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		defer listener.Close()
+		connection, err := listener.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer connection.Close()
+		conn := session.NewConn(connection, session.Responder)
+		steps := []func() error{
+			func() error { return conn.Send(oftp2.StartSessionReadyMessageCmd(oftp2.NewStartSessionReadyMessage())) },
+			expect(conn, oftp2.StartSessionCmd{}),
+			func() error { return conn.Send(oftp2.StartSessionCmd(ssid)) },
+			expect(conn, oftp2.ChangeDirectionCmd{}),
+		}
+		if eerp != nil {
+			steps = append(steps, func() error { return conn.Send(eerp) }, expect(conn, oftp2.ReadyToReceiveCmd{}))
+		}
+		steps = append(steps,
+			func() error { return conn.Send(oftp2.ChangeDirectionCmd(oftp2.NewChangeDirection())) },
+			expect(conn, oftp2.EndSessionCmd{}),
+		)
+		for _, step := range steps {
+			if err := step(); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	return listener.Addr().String(), done
+}
+
+func expect(conn *session.Conn, expected oftp2.Message) func() error {
+	return func() error {
+		msg, err := conn.Receive()
+		if err != nil {
+			return err
+		} else if msg.Id() != expected.Id() {
+			return fmt.Errorf("expected %v, but got %v", expected.Id(), msg.Id())
+		}
+		return nil
+	}
+}
+
+// responder accepts a single session of the scheduler, storing the received files in store and sending files.
+func responder(t *testing.T, store storage.Storage, files ...session.VirtualFile) (string, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	config := session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "PARTNER"),
+		Password:               "PARTNER",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+		Store:                  store,
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer listener.Close()
+		connection, err := listener.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer connection.Close()
+		s, err := session.Accept(connection, config)
+		if err != nil {
+			done <- err
+			return
+		}
+		s.Queue(files...)
+		done <- s.Run()
+	}()
+	return listener.Addr().String(), done
+}
+
+func dial(t *testing.T, address string) func(ctx context.Context, destination string) (*client.Session, error) {
+	return func(ctx context.Context, destination string) (*client.Session, error) {
+		require.Equal(t, "O0177PARTNER", destination)
+		return client.Dial(ctx, address, client.Config{
+			IdentificationCode:     testkeys.IdentificationCode(t, "CLIENT"),
+			Password:               "CLIENT",
+			DataExchangeBufferSize: 128,
+			Credit:                 1,
+		})
+	}
+}
+
+// refusingStorage refuses every file as if it was busy.
+type refusingStorage struct {
+	*storage.Memory
+	retry bool
+}
+
+func (r refusingStorage) Create(storage.Identity) (storage.Writer, error) {
+	refusal := oftp2.NewStartFileError(oftp2.AnswerUnspecified, errors.New("storage is busy"))
+	refusal.Retry = r.retry
+	return nil, refusal
+}
+
+func stored(t *testing.T, store storage.Storage, name string) string {
+	t.Helper()
+	sfid, err := oftp2.NewStartFile(virtualFile(t, name, "PARTNER", "").StartFile)
+	require.NoError(t, err)
+	r, err := store.Open(storage.IdentityOf(oftp2.StartFileCmd(sfid)))
+	require.NoError(t, err)
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(content)
+}
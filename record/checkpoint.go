@@ -0,0 +1,18 @@
+package record
+
+// BlockSize is the unit of restart positions for unstructured and text files.
+const BlockSize = 1024
+
+// Checkpoint is a position a transfer can be restarted at.
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-3.4.4
+type Checkpoint struct {
+	// Position is a record count for fixed and variable files and a count of 1K blocks for unstructured and text files.
+	Position int64 `json:"position"`
+	// Units is the number of octets in virtual form up to Position.
+	Units int64 `json:"units"`
+	// Offset is the size of the local file up to Position.
+	Offset int64 `json:"offset"`
+	// PendingCR is set when a text file has a CR right before Position, which isn't stored yet.
+	PendingCR bool `json:"pendingCR,omitempty"`
+}
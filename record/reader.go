@@ -0,0 +1,200 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"io"
+)
+
+// Reader reads a local file in its virtual form.
+type Reader struct {
+	config      Config
+	reader      *bufio.Reader
+	recordCount int64
+	unitCount   int64
+	// rest is what is left of a chunk after skipping to a restart position
+	rest []byte
+	// digest receives the virtual form of everything that is read
+	digest io.Writer
+}
+
+func NewReader(reader io.Reader, config Config) (*Reader, error) {
+	if err := config.valid(); err != nil {
+		return nil, err
+	}
+	return &Reader{
+		config: config,
+		reader: bufio.NewReader(reader),
+	}, nil
+}
+
+// Next returns the next record of fixed and variable files, or the next chunk of unstructured and text files.
+// It returns io.EOF after the last one.
+func (r *Reader) Next() ([]byte, error) {
+	var next []byte
+	var err error
+	// the rest of a chunk was already passed to the digest, when it was skipped
+	replayed := false
+	switch r.config.Format {
+	case oftp2.FileFormatUnstructured, oftp2.FileFormatText:
+		if len(r.rest) > 0 {
+			next, r.rest = r.rest, nil
+			replayed = true
+			break
+		}
+		if r.config.Format == oftp2.FileFormatText {
+			next, err = r.nextText()
+		} else {
+			next, err = r.nextChunk()
+		}
+	case oftp2.FileFormatFixed:
+		next, err = r.nextFixed()
+	case oftp2.FileFormatVariable:
+		next, err = r.nextVariable()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if HasRecords(r.config.Format) {
+		r.recordCount++
+	}
+	r.unitCount += int64(len(next))
+	if r.digest != nil && !replayed {
+		_, _ = r.digest.Write(next)
+	}
+	return next, nil
+}
+
+// Digest passes the virtual form of everything read from now on to w, including what is skipped for a restart.
+// It allows hashing the virtual file while it is sent.
+func (r *Reader) Digest(w io.Writer) {
+	r.digest = w
+}
+
+// Skip moves to a restart position, which is a record count for fixed and variable files
+// and a count of 1K blocks for unstructured and text files.
+func (r *Reader) Skip(position int64) error {
+	if HasRecords(r.config.Format) {
+		for r.recordCount < position {
+			if _, err := r.Next(); err != nil {
+				return skipError(position, err)
+			}
+		}
+		return nil
+	}
+	target := position * BlockSize
+	for r.unitCount < target {
+		next, err := r.Next()
+		if err != nil {
+			return skipError(position, err)
+		}
+		if over := r.unitCount - target; over > 0 {
+			r.rest = next[int64(len(next))-over:]
+			r.unitCount = target
+		}
+	}
+	return nil
+}
+
+func skipError(position int64, err error) error {
+	if err == io.EOF {
+		return fmt.Errorf("restart position %d is beyond the end of the file", position)
+	}
+	return err
+}
+
+// Position is the restart position of what was read so far,
+// which is a record count for fixed and variable files and a count of complete 1K blocks for unstructured and text files.
+func (r *Reader) Position() int64 {
+	if HasRecords(r.config.Format) {
+		return r.recordCount
+	}
+	return r.unitCount / BlockSize
+}
+
+// RecordCount is the number of records read so far, as reported in EFID.
+func (r *Reader) RecordCount() int64 {
+	return r.recordCount
+}
+
+// UnitCount is the number of octets read so far in virtual form, as reported in EFID.
+func (r *Reader) UnitCount() int64 {
+	return r.unitCount
+}
+
+func (r *Reader) nextFixed() ([]byte, error) {
+	if r.config.LocalForm == LocalFormRaw {
+		record := make([]byte, r.config.MaxRecordSize)
+		n, err := io.ReadFull(r.reader, record)
+		if err == io.EOF {
+			return nil, err
+		} else if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		return pad(record[:n], r.config.MaxRecordSize), nil
+	}
+	line, err := r.nextRecordLine()
+	if err != nil {
+		return nil, err
+	}
+	return pad(line, r.config.MaxRecordSize), nil
+}
+
+func (r *Reader) nextVariable() ([]byte, error) {
+	return r.nextRecordLine()
+}
+
+func (r *Reader) nextRecordLine() ([]byte, error) {
+	line, err := r.nextLine()
+	if err != nil {
+		return nil, err
+	}
+	if l := len(line); l > r.config.MaxRecordSize {
+		return nil, fmt.Errorf("record %d exceeds the max record size of %d: %d", r.recordCount+1, r.config.MaxRecordSize, l)
+	}
+	return line, nil
+}
+
+func (r *Reader) nextChunk() ([]byte, error) {
+	chunk := make([]byte, chunkSize)
+	n, err := r.reader.Read(chunk)
+	if n == 0 && err == nil {
+		return r.nextChunk()
+	} else if n == 0 {
+		return nil, err
+	}
+	return chunk[:n], nil
+}
+
+func (r *Reader) nextText() ([]byte, error) {
+	if r.config.LocalForm == LocalFormRaw {
+		return r.nextChunk()
+	}
+	line, err := r.nextLine()
+	if err != nil {
+		return nil, err
+	}
+	return append(line, textSeparator...), nil
+}
+
+// nextLine returns the next line without its separator. Both LF and CR LF are accepted.
+func (r *Reader) nextLine() ([]byte, error) {
+	line, err := r.reader.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		return line, nil
+	} else if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	return bytes.TrimSuffix(line, []byte{'\r'}), nil
+}
+
+func pad(record []byte, size int) []byte {
+	if l := len(record); l < size {
+		return append(record, bytes.Repeat([]byte{' '}, size-l)...)
+	}
+	return record
+}
@@ -0,0 +1,215 @@
+package record_test
+
+import (
+	"bytes"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/record"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	for _, scenario := range []struct {
+		with        string
+		config      record.Config
+		input       string
+		expect      []string
+		recordCount int64
+		unitCount   int64
+	}{
+		{
+			with:        "fixed records from lines",
+			config:      record.Config{Format: oftp2.FileFormatFixed, MaxRecordSize: 4, LocalForm: record.LocalFormLF},
+			input:       "AB\nABCD\r\nA",
+			expect:      []string{"AB  ", "ABCD", "A   "},
+			recordCount: 3,
+			unitCount:   12,
+		},
+		{
+			with:        "fixed records from raw data",
+			config:      record.Config{Format: oftp2.FileFormatFixed, MaxRecordSize: 4, LocalForm: record.LocalFormRaw},
+			input:       "ABCDEFGHI",
+			expect:      []string{"ABCD", "EFGH", "I   "},
+			recordCount: 3,
+			unitCount:   12,
+		},
+		{
+			with:        "variable records",
+			config:      record.Config{Format: oftp2.FileFormatVariable, MaxRecordSize: 4, LocalForm: record.LocalFormCRLF},
+			input:       "AB\r\n\r\nABCD\r\n",
+			expect:      []string{"AB", "", "ABCD"},
+			recordCount: 3,
+			unitCount:   6,
+		},
+		{
+			with:        "unstructured data",
+			config:      record.Config{Format: oftp2.FileFormatUnstructured},
+			input:       "AB\r\nCD\n",
+			expect:      []string{"AB\r\nCD\n"},
+			recordCount: 0,
+			unitCount:   7,
+		},
+		{
+			with:        "text",
+			config:      record.Config{Format: oftp2.FileFormatText, LocalForm: record.LocalFormLF},
+			input:       "AB\nCD",
+			expect:      []string{"AB\r\n", "CD\r\n"},
+			recordCount: 0,
+			unitCount:   8,
+		},
+		{
+			with:        "text in virtual form",
+			config:      record.Config{Format: oftp2.FileFormatText, LocalForm: record.LocalFormRaw},
+			input:       "AB\r\nCD",
+			expect:      []string{"AB\r\nCD"},
+			recordCount: 0,
+			unitCount:   6,
+		},
+		{
+			with:   "an empty file",
+			config: record.Config{Format: oftp2.FileFormatVariable, MaxRecordSize: 4, LocalForm: record.LocalFormLF},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			reader, err := record.NewReader(strings.NewReader(scenario.input), scenario.config)
+			require.NoError(t, err)
+			var records []string
+			for {
+				next, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				records = append(records, string(next))
+			}
+			require.Equal(t, scenario.expect, records)
+			require.Equal(t, scenario.recordCount, reader.RecordCount())
+			require.Equal(t, scenario.unitCount, reader.UnitCount())
+		})
+	}
+}
+
+func TestReader_LargeUnstructuredFile(t *testing.T) {
+	input := bytes.Repeat([]byte{'A'}, 10000)
+	reader, err := record.NewReader(bytes.NewReader(input), record.Config{Format: oftp2.FileFormatUnstructured})
+	require.NoError(t, err)
+	var read []byte
+	for {
+		next, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		read = append(read, next...)
+	}
+	require.Equal(t, input, read)
+	require.Equal(t, int64(10000), reader.UnitCount())
+}
+
+func TestReader_ExceedingRecord(t *testing.T) {
+	for _, format := range []oftp2.FileFormat{oftp2.FileFormatFixed, oftp2.FileFormatVariable} {
+		t.Run(string(format), func(t *testing.T) {
+			reader, err := record.NewReader(strings.NewReader("AB\nABCDE\n"), record.Config{Format: format, MaxRecordSize: 4, LocalForm: record.LocalFormLF})
+			require.NoError(t, err)
+			_, err = reader.Next()
+			require.NoError(t, err)
+			next, err := reader.Next()
+			require.EqualError(t, err, "record 2 exceeds the max record size of 4: 5")
+			require.Nil(t, next)
+		})
+	}
+}
+
+func TestReader_InvalidConfig(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		config record.Config
+		error  string
+	}{
+		{
+			with:   "an unknown format",
+			config: record.Config{Format: 'X'},
+			error:  "unknown file format: X",
+		},
+		{
+			with:   "an unknown local form",
+			config: record.Config{Format: oftp2.FileFormatUnstructured, LocalForm: "\r"},
+			error:  `unknown local form: "\r"`,
+		},
+		{
+			with:   "a missing max record size",
+			config: record.Config{Format: oftp2.FileFormatFixed},
+			error:  "invalid max record size: 0",
+		},
+		{
+			with:   "an exceeding max record size",
+			config: record.Config{Format: oftp2.FileFormatVariable, MaxRecordSize: 100000, LocalForm: record.LocalFormLF},
+			error:  "invalid max record size: 100000",
+		},
+		{
+			with:   "variable records without separator",
+			config: record.Config{Format: oftp2.FileFormatVariable, MaxRecordSize: 4},
+			error:  "variable records need a local separator",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			reader, err := record.NewReader(strings.NewReader(""), scenario.config)
+			require.EqualError(t, err, scenario.error)
+			require.Nil(t, reader)
+			writer, err := record.NewWriter(&bytes.Buffer{}, scenario.config)
+			require.EqualError(t, err, scenario.error)
+			require.Nil(t, writer)
+		})
+	}
+}
+
+func TestReader_Skip(t *testing.T) {
+	variable, err := record.NewReader(strings.NewReader("A\nB\nC\n"), record.Config{Format: oftp2.FileFormatVariable, MaxRecordSize: 4, LocalForm: record.LocalFormLF})
+	require.NoError(t, err)
+	require.NoError(t, variable.Skip(2))
+	next, err := variable.Next()
+	require.NoError(t, err)
+	require.Equal(t, "C", string(next))
+	require.Equal(t, int64(3), variable.Position())
+	require.Equal(t, int64(3), variable.UnitCount())
+
+	input := bytes.Repeat([]byte("ABCDEFGH"), 1000)
+	unstructured, err := record.NewReader(bytes.NewReader(input), record.Config{Format: oftp2.FileFormatUnstructured})
+	require.NoError(t, err)
+	require.NoError(t, unstructured.Skip(5))
+	require.Equal(t, int64(5), unstructured.Position())
+	var read []byte
+	for {
+		next, err := unstructured.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		read = append(read, next...)
+	}
+	require.Equal(t, input[5*record.BlockSize:], read)
+	require.Equal(t, int64(len(input)), unstructured.UnitCount())
+
+	beyond, err := record.NewReader(bytes.NewReader(input), record.Config{Format: oftp2.FileFormatUnstructured})
+	require.NoError(t, err)
+	require.EqualError(t, beyond.Skip(8), "restart position 8 is beyond the end of the file")
+}
+
+func TestReader_Digest(t *testing.T) {
+	input := bytes.Repeat([]byte("ABCDEFGH"), 1000)
+	reader, err := record.NewReader(bytes.NewReader(input), record.Config{Format: oftp2.FileFormatUnstructured})
+	require.NoError(t, err)
+	var digest bytes.Buffer
+	reader.Digest(&digest)
+	require.NoError(t, reader.Skip(5))
+	for {
+		_, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.Equal(t, input, digest.Bytes())
+}
@@ -0,0 +1,74 @@
+// Package record converts local files to and from the virtual file formats of OFTP2.
+//
+// Fixed (F) and Variable (V) files are sent as records, Unstructured (U) and Text (T) files as a stream of octets.
+// Text files are transmitted with CR LF record separators.
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-1.5.2
+package record
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+)
+
+// LocalForm is the separator terminating records in the local file.
+type LocalForm string
+
+const (
+	LocalFormLF   LocalForm = "\n"
+	LocalFormCRLF LocalForm = "\r\n"
+	// LocalFormRaw stores records without separators, as fixed records or text already in virtual form.
+	LocalFormRaw LocalForm = ""
+)
+
+const (
+	// MaxRecordSize is the largest record a virtual file can hold.
+	MaxRecordSize = 99999
+	// chunkSize is the size of the chunks unstructured files are read in.
+	chunkSize = 4096
+	// textSeparator separates records of text files in their virtual form.
+	textSeparator = "\r\n"
+)
+
+// Config describes the virtual file and its local form.
+type Config struct {
+	Format oftp2.FileFormat
+	// MaxRecordSize is the size of fixed records and the limit for variable records.
+	MaxRecordSize int
+	LocalForm     LocalForm
+}
+
+func (c Config) valid() error {
+	if _, exists := oftp2.KnownFileFormats[c.Format]; !exists {
+		return fmt.Errorf("unknown file format: %v", string(c.Format))
+	}
+	if c.LocalForm != LocalFormLF && c.LocalForm != LocalFormCRLF && c.LocalForm != LocalFormRaw {
+		return fmt.Errorf("unknown local form: %q", string(c.LocalForm))
+	}
+	if !HasRecords(c.Format) {
+		return nil
+	}
+	if c.MaxRecordSize < 1 || c.MaxRecordSize > MaxRecordSize {
+		return fmt.Errorf("invalid max record size: %d", c.MaxRecordSize)
+	}
+	if c.Format == oftp2.FileFormatVariable && c.LocalForm == LocalFormRaw {
+		return errors.New("variable records need a local separator")
+	}
+	return nil
+}
+
+// HasRecords tells whether the format is transmitted as records, which end on the end of record flag.
+// Other formats are transmitted as a stream of octets and report a record count of 0.
+func HasRecords(format oftp2.FileFormat) bool {
+	return format == oftp2.FileFormatFixed || format == oftp2.FileFormatVariable
+}
+
+// DefaultLocalForm is the local form received files are stored in, unless configured otherwise.
+// Fixed and unstructured files are kept as they are, variable records and text become lines.
+func DefaultLocalForm(format oftp2.FileFormat) LocalForm {
+	if format == oftp2.FileFormatVariable || format == oftp2.FileFormatText {
+		return LocalFormLF
+	}
+	return LocalFormRaw
+}
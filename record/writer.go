@@ -0,0 +1,156 @@
+package record
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"io"
+)
+
+// Writer stores a received virtual file in its local form.
+type Writer struct {
+	config      Config
+	writer      io.Writer
+	recordCount int64
+	unitCount   int64
+	// pendingCR holds a CR of a text file, that might be the start of a separator split over two writes.
+	pendingCR bool
+	// offset is the number of octets written to the local file
+	offset     int64
+	checkpoint Checkpoint
+}
+
+func NewWriter(writer io.Writer, config Config) (*Writer, error) {
+	return ResumeWriter(writer, config, Checkpoint{})
+}
+
+// ResumeWriter continues a file, whose local form was written up to the checkpoint.
+func ResumeWriter(writer io.Writer, config Config, checkpoint Checkpoint) (*Writer, error) {
+	if err := config.valid(); err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		config:     config,
+		writer:     writer,
+		unitCount:  checkpoint.Units,
+		pendingCR:  checkpoint.PendingCR,
+		offset:     checkpoint.Offset,
+		checkpoint: checkpoint,
+	}
+	if HasRecords(config.Format) {
+		w.recordCount = checkpoint.Position
+	}
+	return w, nil
+}
+
+// WriteRecord stores a record of a fixed or variable file.
+func (w *Writer) WriteRecord(record []byte) error {
+	if !HasRecords(w.config.Format) {
+		return fmt.Errorf("file format %v has no records", string(w.config.Format))
+	}
+	if l := len(record); l > w.config.MaxRecordSize {
+		return fmt.Errorf("record %d exceeds the max record size of %d: %d", w.recordCount+1, w.config.MaxRecordSize, l)
+	} else if w.config.Format == oftp2.FileFormatFixed && l != w.config.MaxRecordSize {
+		return fmt.Errorf("record %d doesn't have the fixed size of %d: %d", w.recordCount+1, w.config.MaxRecordSize, l)
+	}
+	local := make([]byte, 0, len(record)+len(w.config.LocalForm))
+	if err := w.write(append(append(local, record...), w.config.LocalForm...)); err != nil {
+		return err
+	}
+	w.recordCount++
+	w.unitCount += int64(len(record))
+	w.checkpoint = Checkpoint{
+		Position: w.recordCount,
+		Units:    w.unitCount,
+		Offset:   w.offset,
+	}
+	return nil
+}
+
+// Write stores octets of an unstructured or text file.
+// CR LF separators of text files are replaced by the local separator.
+func (w *Writer) Write(p []byte) (int, error) {
+	if HasRecords(w.config.Format) {
+		return 0, fmt.Errorf("file format %v has records", string(w.config.Format))
+	}
+	written := 0
+	for written < len(p) {
+		// parts end on block boundaries, so that a checkpoint can be taken after each block
+		part := p[written:]
+		if untilBoundary := BlockSize - int(w.unitCount%BlockSize); len(part) > untilBoundary {
+			part = part[:untilBoundary]
+		}
+		local := part
+		if w.config.Format == oftp2.FileFormatText && w.config.LocalForm != LocalFormCRLF && w.config.LocalForm != LocalFormRaw {
+			local = w.toLocalText(part)
+		}
+		if err := w.write(local); err != nil {
+			return written, err
+		}
+		written += len(part)
+		w.unitCount += int64(len(part))
+		if w.unitCount%BlockSize == 0 {
+			w.checkpoint = Checkpoint{
+				Position:  w.unitCount / BlockSize,
+				Units:     w.unitCount,
+				Offset:    w.offset,
+				PendingCR: w.pendingCR,
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *Writer) write(local []byte) error {
+	n, err := w.writer.Write(local)
+	w.offset += int64(n)
+	return err
+}
+
+// Checkpoint is the last position the file can be restarted at.
+// It ends on a record for fixed and variable files and on a 1K block for unstructured and text files.
+func (w *Writer) Checkpoint() Checkpoint {
+	return w.checkpoint
+}
+
+// Close writes what is left of the file. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if !w.pendingCR {
+		return nil
+	}
+	w.pendingCR = false
+	return w.write([]byte{'\r'})
+}
+
+// RecordCount is the number of records written so far, to be compared with EFID.
+func (w *Writer) RecordCount() int64 {
+	return w.recordCount
+}
+
+// UnitCount is the number of octets written so far in virtual form, to be compared with EFID.
+func (w *Writer) UnitCount() int64 {
+	return w.unitCount
+}
+
+// Verify compares the written file with the counts announced in EFID.
+func (w *Writer) Verify(efid oftp2.EndFileCmd) error {
+	if w.recordCount != efid.RecordCount() {
+		return fmt.Errorf("expected %d records, but got %d", efid.RecordCount(), w.recordCount)
+	} else if w.unitCount != efid.UnitCount() {
+		return fmt.Errorf("expected %d units, but got %d", efid.UnitCount(), w.unitCount)
+	}
+	return nil
+}
+
+func (w *Writer) toLocalText(p []byte) []byte {
+	text := p
+	if w.pendingCR {
+		text = append([]byte{'\r'}, p...)
+		w.pendingCR = false
+	}
+	if bytes.HasSuffix(text, []byte{'\r'}) {
+		text = text[:len(text)-1]
+		w.pendingCR = true
+	}
+	return bytes.ReplaceAll(text, []byte(textSeparator), []byte(w.config.LocalForm))
+}
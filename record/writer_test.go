@@ -0,0 +1,215 @@
+package record_test
+
+import (
+	"bytes"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/record"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriter_Records(t *testing.T) {
+	for _, scenario := range []struct {
+		with    string
+		config  record.Config
+		records []string
+		expect  string
+	}{
+		{
+			with:    "fixed records as lines",
+			config:  record.Config{Format: oftp2.FileFormatFixed, MaxRecordSize: 2, LocalForm: record.LocalFormLF},
+			records: []string{"AB", "C "},
+			expect:  "AB\nC \n",
+		},
+		{
+			with:    "fixed records as raw data",
+			config:  record.Config{Format: oftp2.FileFormatFixed, MaxRecordSize: 2, LocalForm: record.LocalFormRaw},
+			records: []string{"AB", "C "},
+			expect:  "ABC ",
+		},
+		{
+			with:    "variable records",
+			config:  record.Config{Format: oftp2.FileFormatVariable, MaxRecordSize: 2, LocalForm: record.LocalFormCRLF},
+			records: []string{"AB", "", "C"},
+			expect:  "AB\r\n\r\nC\r\n",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer, err := record.NewWriter(buf, scenario.config)
+			require.NoError(t, err)
+			var units int64
+			for _, r := range scenario.records {
+				require.NoError(t, writer.WriteRecord([]byte(r)))
+				units += int64(len(r))
+			}
+			require.NoError(t, writer.Close())
+			require.Equal(t, scenario.expect, buf.String())
+			require.Equal(t, int64(len(scenario.records)), writer.RecordCount())
+			require.Equal(t, units, writer.UnitCount())
+			_, err = writer.Write([]byte("A"))
+			require.EqualError(t, err, "file format "+string(scenario.config.Format)+" has records")
+		})
+	}
+}
+
+func TestWriter_InvalidRecords(t *testing.T) {
+	fixed, err := record.NewWriter(&bytes.Buffer{}, record.Config{Format: oftp2.FileFormatFixed, MaxRecordSize: 2, LocalForm: record.LocalFormLF})
+	require.NoError(t, err)
+	require.EqualError(t, fixed.WriteRecord([]byte("A")), "record 1 doesn't have the fixed size of 2: 1")
+	require.EqualError(t, fixed.WriteRecord([]byte("ABC")), "record 1 exceeds the max record size of 2: 3")
+
+	unstructured, err := record.NewWriter(&bytes.Buffer{}, record.Config{Format: oftp2.FileFormatUnstructured})
+	require.NoError(t, err)
+	require.EqualError(t, unstructured.WriteRecord([]byte("A")), "file format U has no records")
+}
+
+func TestWriter_Stream(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		config record.Config
+		chunks []string
+		expect string
+	}{
+		{
+			with:   "unstructured data",
+			config: record.Config{Format: oftp2.FileFormatUnstructured},
+			chunks: []string{"AB\r", "\nC"},
+			expect: "AB\r\nC",
+		},
+		{
+			with:   "text",
+			config: record.Config{Format: oftp2.FileFormatText, LocalForm: record.LocalFormLF},
+			chunks: []string{"AB\r\nC\r\n"},
+			expect: "AB\nC\n",
+		},
+		{
+			with:   "text with a separator spanning writes",
+			config: record.Config{Format: oftp2.FileFormatText, LocalForm: record.LocalFormLF},
+			chunks: []string{"AB\r", "\nC\r", "D\r"},
+			expect: "AB\nC\rD\r",
+		},
+		{
+			with:   "text in virtual form",
+			config: record.Config{Format: oftp2.FileFormatText, LocalForm: record.LocalFormRaw},
+			chunks: []string{"AB\r\n"},
+			expect: "AB\r\n",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			writer, err := record.NewWriter(buf, scenario.config)
+			require.NoError(t, err)
+			var units int64
+			for _, c := range scenario.chunks {
+				n, err := writer.Write([]byte(c))
+				require.NoError(t, err)
+				require.Equal(t, len(c), n)
+				units += int64(len(c))
+			}
+			require.NoError(t, writer.Close())
+			require.Equal(t, scenario.expect, buf.String())
+			require.Equal(t, int64(0), writer.RecordCount())
+			require.Equal(t, units, writer.UnitCount())
+		})
+	}
+}
+
+func TestWriter_Verify(t *testing.T) {
+	writer, err := record.NewWriter(&bytes.Buffer{}, record.Config{Format: oftp2.FileFormatVariable, MaxRecordSize: 2, LocalForm: record.LocalFormLF})
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRecord([]byte("AB")))
+
+	for _, scenario := range []struct {
+		input oftp2.EndFileInput
+		error string
+	}{
+		{input: oftp2.EndFileInput{RecordCount: 1, UnitCount: 2}},
+		{input: oftp2.EndFileInput{RecordCount: 2, UnitCount: 2}, error: "expected 2 records, but got 1"},
+		{input: oftp2.EndFileInput{RecordCount: 1, UnitCount: 3}, error: "expected 3 units, but got 2"},
+	} {
+		efid, err := oftp2.NewEndFile(scenario.input)
+		require.NoError(t, err)
+		if scenario.error == "" {
+			require.NoError(t, writer.Verify(oftp2.EndFileCmd(efid)))
+		} else {
+			require.EqualError(t, writer.Verify(oftp2.EndFileCmd(efid)), scenario.error)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, config := range []record.Config{
+		{Format: oftp2.FileFormatFixed, MaxRecordSize: 3, LocalForm: record.LocalFormLF},
+		{Format: oftp2.FileFormatVariable, MaxRecordSize: 3, LocalForm: record.LocalFormLF},
+		{Format: oftp2.FileFormatUnstructured},
+		{Format: oftp2.FileFormatText, LocalForm: record.LocalFormLF},
+	} {
+		t.Run(string(config.Format), func(t *testing.T) {
+			input := "ABC\nDEF\n"
+			reader, err := record.NewReader(bytes.NewBufferString(input), config)
+			require.NoError(t, err)
+			out := &bytes.Buffer{}
+			writer, err := record.NewWriter(out, config)
+			require.NoError(t, err)
+			for {
+				next, err := reader.Next()
+				if err != nil {
+					break
+				}
+				if record.HasRecords(config.Format) {
+					require.NoError(t, writer.WriteRecord(next))
+				} else {
+					_, err = writer.Write(next)
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, writer.Close())
+			require.Equal(t, input, out.String())
+			require.Equal(t, reader.RecordCount(), writer.RecordCount())
+			require.Equal(t, reader.UnitCount(), writer.UnitCount())
+		})
+	}
+}
+
+func TestWriter_Resume(t *testing.T) {
+	config := record.Config{Format: oftp2.FileFormatText, LocalForm: record.LocalFormLF}
+	// the separator of the first line spans the first block boundary
+	input := strings.Repeat("A", 1023) + "\n" + strings.Repeat("B", 2000) + "\n"
+
+	reader, err := record.NewReader(strings.NewReader(input), config)
+	require.NoError(t, err)
+	out := &bytes.Buffer{}
+	writer, err := record.NewWriter(out, config)
+	require.NoError(t, err)
+	next, err := reader.Next()
+	require.NoError(t, err)
+	_, err = writer.Write(next)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("BBB"))
+	require.NoError(t, err)
+
+	checkpoint := writer.Checkpoint()
+	require.Equal(t, record.Checkpoint{Position: 1, Units: 1024, Offset: 1023, PendingCR: true}, checkpoint)
+	out.Truncate(int(checkpoint.Offset))
+
+	reader, err = record.NewReader(strings.NewReader(input), config)
+	require.NoError(t, err)
+	require.NoError(t, reader.Skip(checkpoint.Position))
+	writer, err = record.ResumeWriter(out, config, checkpoint)
+	require.NoError(t, err)
+	for {
+		next, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		_, err = writer.Write(next)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.Equal(t, input, out.String())
+	require.Equal(t, reader.UnitCount(), writer.UnitCount())
+}
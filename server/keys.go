@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/x509"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/partner"
+	"path/filepath"
+	"strings"
+)
+
+// NewKeyStore loads the own key for secure authentication and the partner certificates of a directory.
+// Partner certificates are PEM files named by the identification code of the partner, e.g. O0177PARTNER.pem.
+func NewKeyStore(certFile, keyFile, partnerDirectory string) (cms.StaticKeyStore, error) {
+	key, certificate, err := cms.LoadKeyPair(certFile, keyFile)
+	if err != nil {
+		return cms.StaticKeyStore{}, err
+	}
+	store := cms.StaticKeyStore{
+		PrivateKey:     key,
+		OwnCertificate: certificate,
+		Partners:       map[string]*x509.Certificate{},
+	}
+	if partnerDirectory == "" {
+		return store, nil
+	}
+	files, err := filepath.Glob(filepath.Join(partnerDirectory, "*.pem"))
+	if err != nil {
+		return cms.StaticKeyStore{}, err
+	}
+	for _, file := range files {
+		certificate, err := cms.LoadCertificate(file)
+		if err != nil {
+			return cms.StaticKeyStore{}, err
+		}
+		store.Partners[partner.Normalize(strings.TrimSuffix(filepath.Base(file), ".pem"))] = certificate
+	}
+	return store, nil
+}
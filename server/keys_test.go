@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewKeyStore(t *testing.T) {
+	directory := t.TempDir()
+	partners := filepath.Join(directory, "partners")
+	require.NoError(t, os.Mkdir(partners, 0o700))
+	certFile, keyFile := testkeys.WriteRSA(t, directory, "SERVER")
+	partnerFile, _ := testkeys.WriteRSA(t, partners, "O0177PARTNER")
+
+	store, err := NewKeyStore(certFile, keyFile, partners)
+	require.NoError(t, err)
+	key, certificate, err := store.Key()
+	require.NoError(t, err)
+	require.NotNil(t, key)
+	require.Equal(t, "SERVER", certificate.Subject.CommonName)
+
+	partner, err := store.Certificate(testkeys.IdentificationCode(t, "PARTNER"))
+	require.NoError(t, err)
+	require.Equal(t, "O0177PARTNER", partner.Subject.CommonName)
+	_, err = store.Certificate(testkeys.IdentificationCode(t, "UNKNOWN"))
+	require.EqualError(t, err, "missing certificate of partner: O0177UNKNOWN")
+
+	_, err = NewKeyStore(partnerFile, keyFile, partners)
+	require.Error(t, err)
+}
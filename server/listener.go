@@ -1,22 +1,30 @@
 package main
 
 import (
-	"bufio"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/session"
 	"log"
 	"net"
 	"os"
+	"time"
 )
 
+// defaultTimeout is how long a partner may stay silent, before its session ends by time out.
+const defaultTimeout = 5 * time.Minute
+
 type Listener struct {
-	c           <-chan os.Signal
-	listener    *net.TCPListener
-	connections map[string]struct{}
+	c        <-chan os.Signal
+	listener net.Listener
+	config   session.Config
+	// timeout ends sessions, whose partner doesn't read or write within it, with ESID 09
+	timeout time.Duration
 }
 
-func NewListener(c <-chan os.Signal) (*Listener, error) {
-	localAddress, err := net.ResolveTCPAddr("tcp", "0.0.0.0:3305")
+// NewListener accepts plain TCP connections.
+func NewListener(c <-chan os.Signal, address string, config session.Config) (*Listener, error) {
+	localAddress, err := net.ResolveTCPAddr("tcp", address)
 	if err != nil {
 		return nil, err
 	}
@@ -25,45 +33,97 @@ func NewListener(c <-chan os.Signal) (*Listener, error) {
 		return nil, err
 	}
 	return &Listener{
-		c:           c,
-		listener:    listener,
-		connections: map[string]struct{}{},
+		c:        c,
+		listener: listener,
+		config:   config,
+		timeout:  defaultTimeout,
 	}, nil
 }
 
+// NewTLSListener accepts TLS connections, which is how OFTP2 is usually run.
+// Partners are asked for a certificate, when tlsConfig sets ClientAuth.
+func NewTLSListener(c <-chan os.Signal, address string, tlsConfig *tls.Config, config session.Config) (*Listener, error) {
+	if tlsConfig == nil || (len(tlsConfig.Certificates) == 0 && tlsConfig.GetCertificate == nil) {
+		return nil, errors.New("missing server certificate")
+	}
+	p, err := NewListener(c, address, config)
+	if err != nil {
+		return nil, err
+	}
+	p.listener = tls.NewListener(p.listener, tlsConfig)
+	return p, nil
+}
+
+// Addr is the address the listener accepts connections on.
+func (p *Listener) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// Listen accepts connections, until a signal arrives or the listener is closed.
+// Failing accepts are retried with a backoff, like net/http does.
 func (p *Listener) Listen() {
+	var backoff time.Duration
 	for {
 		select {
 		case <-p.c:
 			log.Println("exiting...")
 			return
 		default:
-			localConnection, err := p.listener.AcceptTCP()
-			if err != nil {
+			localConnection, err := p.listener.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			} else if err != nil {
 				log.Println(err)
+				backoff = nextBackoff(backoff)
+				time.Sleep(backoff)
+				continue
 			}
+			backoff = 0
 			go p.handle(localConnection)
 		}
 	}
 }
 
-func (p *Listener) handle(connection *net.TCPConn) {
-	reader := bufio.NewReader(connection)
-	for {
-		if _, exists := p.connections[connection.RemoteAddr().String()]; !exists {
-			fmt.Printf("Serving %s\n", connection.RemoteAddr().String())
-			if _, err := connection.Write(oftp2.NewStartSessionReadyMessage().StreamTransmissionBuffer()); err != nil {
-				log.Println(err)
-				return
-			}
-			//p.connections[connection.RemoteAddr().String()] = struct{}{}
-		}
+// nextBackoff doubles the delay after a failed accept, starting at 5ms and ending at a second.
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		return 5 * time.Millisecond
+	} else if backoff *= 2; backoff > time.Second {
+		return time.Second
+	}
+	return backoff
+}
 
-		c, _, err := reader.ReadRune()
-		if err != nil {
-			log.Println(err)
-			return
-		}
-		log.Println(string(c))
+func (p *Listener) handle(connection net.Conn) {
+	defer connection.Close()
+	fmt.Printf("Serving %s\n", connection.RemoteAddr().String())
+	s, err := session.Accept(idleConn{Conn: connection, timeout: p.timeout}, p.config)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := s.Run(); err != nil {
+		log.Println(err)
+	}
+}
+
+// idleConn fails reads and writes, that don't progress within timeout,
+// so that a partner, which goes silent, doesn't keep its connection for good.
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c idleConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+func (c idleConn) Write(p []byte) (int, error) {
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
 	}
+	return c.Conn.Write(p)
 }
@@ -2,23 +2,25 @@ package main
 
 import (
 	"bufio"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
 	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/elgohr/go-oftp2/storage"
 	"github.com/stretchr/testify/require"
+	"io"
 	"net"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestListener(t *testing.T) {
-	c := make(chan os.Signal, 1)
-	p, err := NewListener(c)
-	require.NoError(t, err)
-	go p.Listen()
+	p := startListener(t, t.TempDir())
 
-	addr, err := net.ResolveTCPAddr("tcp", "localhost:3305")
-	require.NoError(t, err)
-	conn, err := net.DialTCP("tcp", nil, addr)
+	conn, err := net.Dial("tcp", p.Addr().String())
 	require.NoError(t, err)
+	defer conn.Close()
 
 	reader := bufio.NewReader(conn)
 
@@ -30,15 +32,254 @@ func TestListener(t *testing.T) {
 	})
 
 	t.Run("-SSID->", func(t *testing.T) {
-		_, err = conn.Write([]byte("test\n"))
+		_, err = conn.Write(invalidSessionStart(t).StreamTransmissionBuffer())
 		require.NoError(t, err)
 	})
 
-	t.Run("<-SSID-", func(t *testing.T) {
-		con, err := reader.ReadBytes('\r')
-		sth := "\x10\x00\x00\x17" // https://tools.ietf.org/html/rfc5024#section-8.1
+	t.Run("<-ESID-", func(t *testing.T) {
+		con, err := oftp2.NewStreamTransmissionReader(reader).ReadCommand()
 		require.NoError(t, err)
-		require.Equal(t, sth+"IODETTE FTP READY "+oftp2.CarriageReturn, string(con))
+		esid := oftp2.EndSessionCmd(con)
+		require.NoError(t, esid.Valid())
+		require.Equal(t, oftp2.EndSessionExchangeBufferSizeError, esid.ReasonCode())
+		require.Equal(t, "invalid DataExchangeBufferSize: 127", esid.ReasonText())
+	})
+}
+
+func TestListener_Timeout(t *testing.T) {
+	p, err := NewListener(make(chan os.Signal, 1), "localhost:0", session.Config{})
+	require.NoError(t, err)
+	p.timeout = 50 * time.Millisecond
+	go p.Listen()
+	t.Cleanup(func() {
+		_ = p.listener.Close()
 	})
 
+	conn := dial(t, p)
+	receive(t, conn, oftp2.StartSessionReadyMessageCmd{})
+	// the partner stays silent
+	esid := receive(t, conn, oftp2.EndSessionCmd{}).(oftp2.EndSessionCmd)
+	require.Equal(t, oftp2.EndSessionTimeOut, esid.ReasonCode())
+	require.Equal(t, "no command from the partner in time", esid.ReasonText())
+}
+
+func TestListener_Closed(t *testing.T) {
+	p, err := NewListener(make(chan os.Signal, 1), "localhost:0", session.Config{})
+	require.NoError(t, err)
+	done := make(chan struct{})
+	go func() {
+		p.Listen()
+		close(done)
+	}()
+	require.NoError(t, p.listener.Close())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listener didn't stop after it was closed")
+	}
+}
+
+func TestListener_UnknownPartner(t *testing.T) {
+	p := startListener(t, t.TempDir())
+	conn := dial(t, p)
+	receive(t, conn, oftp2.StartSessionReadyMessageCmd{})
+
+	send(t, conn, sessionStart(t, "UNKNOWN", "PASSWORD"))
+	esid := receive(t, conn, oftp2.EndSessionCmd{}).(oftp2.EndSessionCmd)
+	require.Equal(t, oftp2.EndSessionUserCodeNotKnown, esid.ReasonCode())
+	require.Equal(t, "unknown partner: O0177UNKNOWN", esid.ReasonText())
+}
+
+func TestListener_InvalidPassword(t *testing.T) {
+	p := startListener(t, t.TempDir())
+	conn := dial(t, p)
+	receive(t, conn, oftp2.StartSessionReadyMessageCmd{})
+
+	send(t, conn, sessionStart(t, "PARTNER", "WRONG"))
+	esid := receive(t, conn, oftp2.EndSessionCmd{}).(oftp2.EndSessionCmd)
+	require.Equal(t, oftp2.EndSessionInvalidPassword, esid.ReasonCode())
+}
+
+func TestListener_ReceiveFile(t *testing.T) {
+	directory := t.TempDir()
+	p := startListener(t, directory)
+	conn := dial(t, p)
+	receive(t, conn, oftp2.StartSessionReadyMessageCmd{})
+
+	send(t, conn, sessionStart(t, "PARTNER", "PASSWORD"))
+	ssid := receive(t, conn, oftp2.StartSessionCmd{}).(oftp2.StartSessionCmd)
+	require.Equal(t, 256, ssid.DataExchangeBufferSize())
+	require.Equal(t, 2, ssid.Credit())
+	require.Equal(t, oftp2.CapabilityBoth, ssid.Capabilities())
+
+	sfid := startFile(t, "RECEIVED")
+	send(t, conn, sfid)
+	receive(t, conn, oftp2.StartFilePositiveAnswerCmd{})
+
+	encoder, err := oftp2.NewSubrecordEncoder(ssid.DataExchangeBufferSize(), false)
+	require.NoError(t, err)
+	var buffers []oftp2.Command
+	for i := 0; i < 10; i++ {
+		buffers = append(buffers, encoder.WriteRecord([]byte("0123456789012345678901234567890123456789"))...)
+	}
+	buffers = append(buffers, encoder.Flush())
+	require.Len(t, buffers, 2)
+	for _, buffer := range buffers {
+		send(t, conn, oftp2.DataExchangeBuffer(buffer))
+	}
+	receive(t, conn, oftp2.SetCreditCmd{})
+
+	efid, err := oftp2.NewEndFile(oftp2.EndFileInput{RecordCount: 10, UnitCount: 400})
+	require.NoError(t, err)
+	send(t, conn, oftp2.EndFileCmd(efid))
+	efpa := receive(t, conn, oftp2.EndFilePositiveAnswerCmd{}).(oftp2.EndFilePositiveAnswerCmd)
+	require.False(t, efpa.ChangeDirection())
+
+	send(t, conn, oftp2.ChangeDirectionCmd(oftp2.NewChangeDirection()))
+	eerp := receive(t, conn, oftp2.EndToEndResponseCmd{}).(oftp2.EndToEndResponseCmd)
+	require.True(t, eerp.Matches(sfid))
+	send(t, conn, oftp2.ReadyToReceiveCmd(oftp2.NewReadyToReceive()))
+	receive(t, conn, oftp2.ChangeDirectionCmd{})
+
+	esid, err := oftp2.NewEndSession(oftp2.EndSessionInput{Reason: oftp2.EndSessionNormalTermination})
+	require.NoError(t, err)
+	send(t, conn, oftp2.EndSessionCmd(esid))
+
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+	var received io.ReadCloser
+	require.Eventually(t, func() bool {
+		received, err = store.Open(storage.IdentityOf(sfid))
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	defer received.Close()
+	content, err := io.ReadAll(received)
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("0123456789012345678901234567890123456789\n", 10), string(content))
+}
+
+func TestListener_RefuseDuplicateFile(t *testing.T) {
+	directory := t.TempDir()
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+	duplicate, err := store.Create(storage.IdentityOf(startFile(t, "DUPLICATE")))
+	require.NoError(t, err)
+	require.NoError(t, duplicate.Commit())
+	p := startListener(t, directory)
+	conn := dial(t, p)
+	receive(t, conn, oftp2.StartSessionReadyMessageCmd{})
+	send(t, conn, sessionStart(t, "PARTNER", "PASSWORD"))
+	receive(t, conn, oftp2.StartSessionCmd{})
+
+	send(t, conn, startFile(t, "DUPLICATE"))
+	sfna := receive(t, conn, oftp2.StartFileNegativeAnswerCmd{}).(oftp2.StartFileNegativeAnswerCmd)
+	require.Equal(t, oftp2.AnswerDuplicateFile, sfna.ReasonCode())
+	require.Equal(t, "duplicate file: DUPLICATE", sfna.ReasonText())
+
+	send(t, conn, oftp2.ChangeDirectionCmd(oftp2.NewChangeDirection()))
+	receive(t, conn, oftp2.EndSessionCmd{})
+}
+
+func startListener(t *testing.T, directory string) *Listener {
+	t.Helper()
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+	partners, err := ParsePartners("O0177PARTNER=PASSWORD")
+	require.NoError(t, err)
+	c := make(chan os.Signal, 1)
+	p, err := NewListener(c, "localhost:0", session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "SERVER"),
+		Password:               "SERVER",
+		DataExchangeBufferSize: 256,
+		Credit:                 2,
+		Authenticate:           partners.Authenticate,
+		Store:                  store,
+	})
+	require.NoError(t, err)
+	go p.Listen()
+	return p
+}
+
+func dial(t *testing.T, p *Listener) *session.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", p.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return session.NewConn(conn, session.Initiator)
+}
+
+func send(t *testing.T, conn *session.Conn, msg oftp2.Message) {
+	t.Helper()
+	require.NoError(t, conn.Send(msg))
+}
+
+func receive(t *testing.T, conn *session.Conn, expected oftp2.Message) oftp2.Message {
+	t.Helper()
+	msg, err := conn.Receive()
+	require.NoError(t, err)
+	require.IsType(t, expected, msg)
+	return msg
+}
+
+func sessionStart(t *testing.T, organisation, password string) oftp2.StartSessionCmd {
+	t.Helper()
+	ssid, err := oftp2.NewStartSession(oftp2.StartSessionInput{
+		IdentificationCode:     testkeys.IdentificationCode(t, organisation),
+		Password:               password,
+		DataExchangeBufferSize: 1024,
+		Capabilities:           oftp2.CapabilityBoth,
+		Credit:                 10,
+	})
+	require.NoError(t, err)
+	return oftp2.StartSessionCmd(ssid)
+}
+
+func startFile(t *testing.T, name string) oftp2.StartFileCmd {
+	t.Helper()
+	return restartedFile(t, name, 0)
+}
+
+func restartedFile(t *testing.T, name string, position int64) oftp2.StartFileCmd {
+	t.Helper()
+	stamp, err := oftp2.NewTimeStamp([]byte("20200102030405060708"))
+	require.NoError(t, err)
+	destination, err := oftp2.NewSid(oftp2.SidInput{CodeDesignator: "0177", OrganisationCode: "SERVER"})
+	require.NoError(t, err)
+	origin, err := oftp2.NewSid(oftp2.SidInput{CodeDesignator: "0177", OrganisationCode: "PARTNER"})
+	require.NoError(t, err)
+	sfid, err := oftp2.NewStartFile(oftp2.StartFileInput{
+		Name:            name,
+		Date:            stamp,
+		Destination:     destination,
+		Origin:          origin,
+		Format:          oftp2.FileFormatVariable,
+		MaxRecordSize:   40,
+		Security:        oftp2.SecurityNoServices,
+		Cipher:          oftp2.NoCipher,
+		Compression:     oftp2.NoCompression,
+		Envelope:        oftp2.NoEnvelope,
+		RestartPosition: position,
+	})
+	require.NoError(t, err)
+	return oftp2.StartFileCmd(sfid)
+}
+
+func invalidSessionStart(t *testing.T) oftp2.Command {
+	code, err := oftp2.SsidIdentificationCode(oftp2.SsidIdentificationCodeInput{
+		OdetteIdentifier:            "O",
+		InternationalCodeDesignator: "0177",
+		OrganisationCode:            "ORGANISATION",
+		ComputerSubaddress:          "SUB",
+	})
+	require.NoError(t, err)
+	ssid, err := oftp2.NewStartSession(oftp2.StartSessionInput{
+		IdentificationCode:     code,
+		Password:               "PASSWORD",
+		DataExchangeBufferSize: 127,
+		Capabilities:           oftp2.CapabilityBoth,
+	})
+	require.NoError(t, err)
+	return ssid
 }
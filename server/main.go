@@ -1,19 +1,125 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"github.com/elgohr/go-oftp2/partner"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/elgohr/go-oftp2/storage"
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 )
 
 func main() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Kill, os.Interrupt)
+	address := flag.String("address", "0.0.0.0:3305", "address to listen on for plain TCP, disabled when empty")
+	tlsAddress := flag.String("tls-address", "0.0.0.0:6619", "address to listen on for TLS, enabled by -cert and -key")
+	certFile := flag.String("cert", "", "PEM file of the server certificate for TLS")
+	keyFile := flag.String("key", "", "PEM file of the server key for TLS")
+	clientCAFile := flag.String("client-ca", "", "PEM file of the CAs of partner certificates, which enables mutual TLS")
+	tlsMinVersion := flag.String("tls-min-version", "1.2", "lowest accepted TLS version, 1.2 or 1.3")
+	cipherSuites := flag.String("cipher-suites", "", "accepted TLS 1.2 cipher suites as comma separated names, all secure ones when empty")
+	code := flag.String("code", "", "own identification code, e.g. O0177ORGANISATION")
+	password := flag.String("password", "", "own password")
+	directory := flag.String("directory", "received", "directory for received files")
+	partners := flag.String("partners", "", "known partners as CODE=PASSWORD,CODE=PASSWORD")
+	bufferSize := flag.Int("buffer-size", 4096, "data exchange buffer size")
+	credit := flag.Int("credit", 64, "credit of data exchange buffers")
+	restart := flag.Bool("restart", true, "keep interrupted files for a restart by the partner")
+	timeout := flag.Duration("timeout", defaultTimeout, "time a partner may stay silent, before its session ends with ESID 09")
+	secureAuthentication := flag.Bool("secure-authentication", false, "authenticate partners by their certificates with AUCH and AURP")
+	oftpCertFile := flag.String("oftp-cert", "", "PEM file of the own OFTP2 certificate for secure authentication and file services")
+	oftpKeyFile := flag.String("oftp-key", "", "PEM file of the own OFTP2 RSA key for secure authentication and file services")
+	partnerCertificates := flag.String("partner-certificates", "", "directory of partner certificates, named like O0177PARTNER.pem")
+	registryFile := flag.String("registry", "", "JSON file of the partner registry, which replaces -code, -password, -partners and the OFTP2 keys")
+	flag.Parse()
 
-	p, err := NewListener(c)
+	if len(*code) > 25 {
+		log.Fatalf("identification code is too long: %v\n", *code)
+	}
+	known, err := ParsePartners(*partners)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	p.Listen()
+	store, err := storage.NewFilesystem(*directory)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	config := session.Config{
+		IdentificationCode:     []byte(fmt.Sprintf("%-25s", *code)),
+		Password:               *password,
+		DataExchangeBufferSize: *bufferSize,
+		Credit:                 *credit,
+		BufferCompression:      true,
+		Restart:                *restart,
+		SecureAuthentication:   *secureAuthentication,
+		Authenticate:           known.Authenticate,
+		Store:                  store,
+	}
+	if *oftpCertFile != "" || *oftpKeyFile != "" {
+		keys, err := NewKeyStore(*oftpCertFile, *oftpKeyFile, *partnerCertificates)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		config.KeyStore = keys
+	}
+	if *registryFile != "" {
+		registry, err := partner.Load(*registryFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		config.IdentificationCode = []byte(fmt.Sprintf("%-25s", registry.IdentificationCode))
+		config.Password = registry.Password
+		config.Authenticate = registry.Authenticate
+		config.KeyStore = registry
+		config.Profile = registry.Profile(config)
+	}
+
+	var listeners []*Listener
+	if *address != "" {
+		p, err := NewListener(notify(), *address, config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		listeners = append(listeners, p)
+	}
+	if *certFile != "" || *keyFile != "" {
+		tlsConfig, err := TLSOptions{
+			CertFile:     *certFile,
+			KeyFile:      *keyFile,
+			ClientCAFile: *clientCAFile,
+			MinVersion:   *tlsMinVersion,
+			CipherSuites: *cipherSuites,
+		}.Config()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		p, err := NewTLSListener(notify(), *tlsAddress, tlsConfig, config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		listeners = append(listeners, p)
+	}
+	if len(listeners) == 0 {
+		log.Fatalln("neither -address nor -cert and -key are set")
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range listeners {
+		p.timeout = *timeout
+		wg.Add(1)
+		go func(p *Listener) {
+			defer wg.Done()
+			p.Listen()
+		}(p)
+	}
+	wg.Wait()
 }
 
+// notify is a channel for the signals stopping a listener.
+func notify() <-chan os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Kill, os.Interrupt)
+	return c
+}
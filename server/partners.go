@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/partner"
+	"strings"
+)
+
+// Partners maps the identification codes of known partners to their passwords.
+type Partners map[string]string
+
+// ParsePartners reads partners in the form CODE=PASSWORD,CODE=PASSWORD.
+func ParsePartners(input string) (Partners, error) {
+	partners := Partners{}
+	for _, p := range strings.Split(input, ",") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid partner: %v", p)
+		}
+		partners[partner.Normalize(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return partners, nil
+}
+
+// Authenticate accepts the SSID of known partners with the right password.
+func (p Partners) Authenticate(ssid oftp2.StartSessionCmd) error {
+	code := partner.Normalize(string(ssid.IdentificationCode()))
+	password, known := p[code]
+	if !known {
+		return oftp2.NewEndSessionError(oftp2.EndSessionUserCodeNotKnown, fmt.Errorf("unknown partner: %v", code))
+	} else if password != strings.TrimSpace(string(ssid.Password())) {
+		return oftp2.NewEndSessionError(oftp2.EndSessionInvalidPassword, errors.New("invalid password"))
+	}
+	return nil
+}
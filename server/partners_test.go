@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParsePartners(t *testing.T) {
+	partners, err := ParsePartners("O0177 PARTNER=PASSWORD, O0177OTHER=SECRET,")
+	require.NoError(t, err)
+	require.Equal(t, Partners{
+		"O0177PARTNER": "PASSWORD",
+		"O0177OTHER":   "SECRET",
+	}, partners)
+
+	partners, err = ParsePartners("O0177PARTNER")
+	require.EqualError(t, err, "invalid partner: O0177PARTNER")
+	require.Nil(t, partners)
+}
+
+func TestPartners_Authenticate(t *testing.T) {
+	partners, err := ParsePartners("O0177PARTNER=PASSWORD")
+	require.NoError(t, err)
+	for _, scenario := range []struct {
+		with   string
+		ssid   oftp2.StartSessionCmd
+		reason oftp2.EndSessionReason
+		error  string
+	}{
+		{
+			with: "a known partner",
+			ssid: sessionStart(t, "PARTNER", "PASSWORD"),
+		},
+		{
+			with:   "an unknown partner",
+			ssid:   sessionStart(t, "OTHER", "PASSWORD"),
+			reason: oftp2.EndSessionUserCodeNotKnown,
+			error:  "unknown partner: O0177OTHER",
+		},
+		{
+			with:   "a wrong password",
+			ssid:   sessionStart(t, "PARTNER", "SECRET"),
+			reason: oftp2.EndSessionInvalidPassword,
+			error:  "invalid password",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			err := partners.Authenticate(scenario.ssid)
+			if scenario.error == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, scenario.error)
+			var endSessionErr oftp2.EndSessionError
+			require.True(t, errors.As(err, &endSessionErr))
+			require.Equal(t, scenario.reason, endSessionErr.Reason)
+		})
+	}
+}
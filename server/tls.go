@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions describe the TLS listener.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual authentication. Partners must present a certificate issued by one of its CAs.
+	ClientCAFile string
+	// MinVersion is the lowest accepted TLS version, 1.2 or 1.3. It defaults to 1.2.
+	MinVersion string
+	// CipherSuites are the accepted cipher suites as comma separated names, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+	// They only apply to TLS 1.2, as TLS 1.3 suites aren't configurable. All secure suites are accepted when it is empty.
+	CipherSuites string
+}
+
+// Config loads the certificates of the options.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if o.CertFile == "" || o.KeyFile == "" {
+		return nil, errors.New("missing certificate or key file")
+	}
+	certificate, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := ParseTLSVersion(o.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := ParseCipherSuites(o.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+	}
+	if o.ClientCAFile != "" {
+		pem, err := os.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %v", o.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ParseTLSVersion reads a TLS version like 1.2, which defaults to 1.2 when it is empty.
+func ParseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimSpace(version) {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version: %v", version)
+	}
+}
+
+// ParseCipherSuites reads comma separated names of secure cipher suites.
+func ParseCipherSuites(names string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	var suites []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite: %v", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
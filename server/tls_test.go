@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLSOptions_Config(t *testing.T) {
+	directory := t.TempDir()
+	ca := issue(t, "CA", nil)
+	server := issue(t, "localhost", &ca)
+	certFile, keyFile := server.write(t, directory, "server")
+	caFile, _ := ca.write(t, directory, "ca")
+
+	config, err := TLSOptions{CertFile: certFile, KeyFile: keyFile}.Config()
+	require.NoError(t, err)
+	require.Len(t, config.Certificates, 1)
+	require.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	require.Equal(t, tls.NoClientCert, config.ClientAuth)
+
+	config, err = TLSOptions{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+		MinVersion:   "1.3",
+		CipherSuites: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	}.Config()
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}, config.CipherSuites)
+	require.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+	require.NotNil(t, config.ClientCAs)
+
+	for _, scenario := range []struct {
+		with    string
+		options TLSOptions
+		error   string
+	}{
+		{
+			with:    "a missing key",
+			options: TLSOptions{CertFile: certFile},
+			error:   "missing certificate or key file",
+		},
+		{
+			with:    "an unsupported version",
+			options: TLSOptions{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"},
+			error:   "unsupported TLS version: 1.0",
+		},
+		{
+			with:    "an insecure cipher suite",
+			options: TLSOptions{CertFile: certFile, KeyFile: keyFile, CipherSuites: "TLS_RSA_WITH_RC4_128_SHA"},
+			error:   "unknown or insecure cipher suite: TLS_RSA_WITH_RC4_128_SHA",
+		},
+		{
+			with:    "client CAs without certificates",
+			options: TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile},
+			error:   "no certificates in " + keyFile,
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			config, err := scenario.options.Config()
+			require.EqualError(t, err, scenario.error)
+			require.Nil(t, config)
+		})
+	}
+}
+
+func TestTLSListener(t *testing.T) {
+	ca := issue(t, "CA", nil)
+	server := issue(t, "localhost", &ca)
+	p := startTLSListener(t, &tls.Config{Certificates: []tls.Certificate{server.tls}})
+
+	conn, err := tls.Dial("tcp", p.Addr().String(), &tls.Config{RootCAs: ca.pool(), ServerName: "localhost"})
+	require.NoError(t, err)
+	defer conn.Close()
+	cmd, err := oftp2.NewStreamTransmissionReader(bufio.NewReader(conn)).ReadCommand()
+	require.NoError(t, err)
+	require.Equal(t, oftp2.StartSessionReadyMessage, cmd.Cmd())
+}
+
+func TestTLSListener_MutualAuthentication(t *testing.T) {
+	ca := issue(t, "CA", nil)
+	server := issue(t, "localhost", &ca)
+	partner := issue(t, "PARTNER", &ca)
+	p := startTLSListener(t, &tls.Config{
+		Certificates: []tls.Certificate{server.tls},
+		ClientCAs:    ca.pool(),
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+
+	t.Run("with certificate", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", p.Addr().String(), &tls.Config{
+			RootCAs:      ca.pool(),
+			ServerName:   "localhost",
+			Certificates: []tls.Certificate{partner.tls},
+		})
+		require.NoError(t, err)
+		defer conn.Close()
+		cmd, err := oftp2.NewStreamTransmissionReader(bufio.NewReader(conn)).ReadCommand()
+		require.NoError(t, err)
+		require.Equal(t, oftp2.StartSessionReadyMessage, cmd.Cmd())
+	})
+
+	t.Run("without certificate", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", p.Addr().String(), &tls.Config{RootCAs: ca.pool(), ServerName: "localhost"})
+		if err == nil {
+			// TLS 1.3 reports the missing certificate after the handshake
+			defer conn.Close()
+			_, err = oftp2.NewStreamTransmissionReader(bufio.NewReader(conn)).ReadCommand()
+		}
+		require.Error(t, err)
+	})
+}
+
+func TestNewTLSListener_MissingCertificate(t *testing.T) {
+	p, err := NewTLSListener(make(chan os.Signal, 1), "localhost:0", &tls.Config{}, session.Config{})
+	require.EqualError(t, err, "missing server certificate")
+	require.Nil(t, p)
+}
+
+func startTLSListener(t *testing.T, tlsConfig *tls.Config) *Listener {
+	t.Helper()
+	c := make(chan os.Signal, 1)
+	p, err := NewTLSListener(c, "localhost:0", tlsConfig, session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, "SERVER"),
+		Password:               "SERVER",
+		DataExchangeBufferSize: 256,
+		Credit:                 2,
+	})
+	require.NoError(t, err)
+	go p.Listen()
+	return p
+}
+
+type issued struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	tls         tls.Certificate
+}
+
+// issue creates a certificate, which is self-signed without an issuer.
+func issue(t *testing.T, name string, issuer *issued) issued {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	parent, parentKey := template, key
+	if issuer == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		parent, parentKey = issuer.certificate, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return issued{
+		certificate: certificate,
+		key:         key,
+		tls:         tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+	}
+}
+
+func (i issued) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(i.certificate)
+	return pool
+}
+
+// write stores the certificate and key as PEM files.
+func (i issued) write(t *testing.T, directory string, name string) (string, string) {
+	t.Helper()
+	certFile := filepath.Join(directory, name+".crt")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.certificate.Raw}), 0o600))
+	key, err := x509.MarshalECPrivateKey(i.key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(directory, name+".key")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0o600))
+	return certFile, keyFile
+}
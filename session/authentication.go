@@ -0,0 +1,128 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"io"
+)
+
+// agreeAuthentication checks that secure authentication can be run, if it was agreed by oftp2.Negotiate.
+func agreeAuthentication(config Config) error {
+	if config.SecureAuthentication && config.KeyStore == nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionSecureAuthenticationIncompatible, errors.New("missing key store"))
+	}
+	return nil
+}
+
+// authenticate runs the secure authentication after SSID, if it was agreed.
+// The responder challenges the initiator first, then the initiator challenges the responder.
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.17
+func authenticate(conn *Conn, config Config, remote oftp2.StartSessionCmd) error {
+	if err := agreeAuthentication(config); err != nil || !config.SecureAuthentication {
+		return err
+	}
+	if conn.Machine().Side() == Initiator {
+		if err := conn.Send(oftp2.SecurityChangeDirectionCmd(oftp2.NewSecurityChangeDirection())); err != nil {
+			return err
+		}
+		if err := respond(conn, config.KeyStore); err != nil {
+			return err
+		}
+		if err := receiveSecurityChangeDirection(conn); err != nil {
+			return err
+		}
+		return challenge(conn, config, remote)
+	}
+	if err := receiveSecurityChangeDirection(conn); err != nil {
+		return err
+	}
+	if err := challenge(conn, config, remote); err != nil {
+		return err
+	}
+	if err := conn.Send(oftp2.SecurityChangeDirectionCmd(oftp2.NewSecurityChangeDirection())); err != nil {
+		return err
+	}
+	return respond(conn, config.KeyStore)
+}
+
+// challenge sends a random challenge, encrypted for the partner's certificate, which the partner must return decrypted.
+func challenge(conn *Conn, config Config, remote oftp2.StartSessionCmd) error {
+	certificate, err := config.KeyStore.Certificate(remote.IdentificationCode())
+	if err != nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionSecureAuthenticationIncompatible, err)
+	}
+	random := make([]byte, oftp2.AuthenticationResponseLength)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionResourcesNotAvailable, err)
+	}
+	cipher := config.AuthenticationCipher
+	if cipher == oftp2.NoCipher {
+		cipher = oftp2.CipherAes256Cbc
+	}
+	enveloped, err := cms.Encrypt(random, certificate, cipher)
+	if err != nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionSecureAuthenticationIncompatible, err)
+	}
+	auch, err := oftp2.NewAuthenticationChallenge(enveloped)
+	if err != nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionUnspecified, err)
+	}
+	if err := conn.Send(oftp2.AuthenticationChallengeCmd(auch)); err != nil {
+		return err
+	}
+	msg, err := conn.Receive()
+	if err != nil {
+		return err
+	}
+	aurp, ok := msg.(oftp2.AuthenticationResponseCmd)
+	if !ok {
+		return refusedByPartner(msg)
+	}
+	if subtle.ConstantTimeCompare(aurp.Response(), random) != 1 {
+		return oftp2.NewEndSessionError(oftp2.EndSessionInvalidChallengeResponse, errors.New("invalid challenge response"))
+	}
+	return nil
+}
+
+// respond decrypts the challenge of the partner with the own private key and returns it.
+func respond(conn *Conn, keys cms.KeyStore) error {
+	msg, err := conn.Receive()
+	if err != nil {
+		return err
+	}
+	auch, ok := msg.(oftp2.AuthenticationChallengeCmd)
+	if !ok {
+		return refusedByPartner(msg)
+	}
+	key, certificate, err := keys.Key()
+	if err != nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionSecureAuthenticationIncompatible, err)
+	}
+	response, err := cms.Decrypt(auch.Challenge(), key, certificate)
+	if err != nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionSecureAuthenticationIncompatible, err)
+	} else if l := len(response); l != oftp2.AuthenticationResponseLength {
+		return oftp2.NewEndSessionError(oftp2.EndSessionCommandContainedInvalidData, fmt.Errorf("invalid challenge length: %d", l))
+	}
+	aurp, err := oftp2.NewAuthenticationResponse(response)
+	if err != nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionUnspecified, err)
+	}
+	return conn.Send(oftp2.AuthenticationResponseCmd(aurp))
+}
+
+func receiveSecurityChangeDirection(conn *Conn) error {
+	msg, err := conn.Receive()
+	if err != nil {
+		return err
+	}
+	if _, ok := msg.(oftp2.SecurityChangeDirectionCmd); !ok {
+		return refusedByPartner(msg)
+	}
+	return nil
+}
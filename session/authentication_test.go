@@ -0,0 +1,118 @@
+package session_test
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/internal/testkeys"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestSecureAuthentication(t *testing.T) {
+	initiatorKey, initiatorCertificate := testkeys.RSA(t, "INITIATOR")
+	responderKey, responderCertificate := testkeys.RSA(t, "RESPONDER")
+	initiator := stationConfig(t, "INITIATOR")
+	initiator.SecureAuthentication = true
+	initiator.KeyStore = cms.StaticKeyStore{
+		PrivateKey:     initiatorKey,
+		OwnCertificate: initiatorCertificate,
+		Partners:       map[string]*x509.Certificate{"O0177RESPONDER": responderCertificate},
+	}
+	initiator.AuthenticationCipher = oftp2.Cipher3DesEdeCbc3Key
+	responder := stationConfig(t, "RESPONDER")
+	responder.SecureAuthentication = true
+	responder.KeyStore = cms.StaticKeyStore{
+		PrivateKey:     responderKey,
+		OwnCertificate: responderCertificate,
+		Partners:       map[string]*x509.Certificate{"O0177INITIATOR": initiatorCertificate},
+	}
+
+	initiatorSession, responderSession, initiatorErr, responderErr := start(t, initiator, responder)
+	require.NoError(t, initiatorErr)
+	require.NoError(t, responderErr)
+	require.True(t, initiatorSession.Local().Authentication())
+	require.True(t, responderSession.Local().Authentication())
+}
+
+func TestSecureAuthentication_Failures(t *testing.T) {
+	initiatorKey, initiatorCertificate := testkeys.RSA(t, "INITIATOR")
+	responderKey, responderCertificate := testkeys.RSA(t, "RESPONDER")
+	_, otherCertificate := testkeys.RSA(t, "OTHER")
+	keys := func(key *rsa.PrivateKey, own *x509.Certificate, code string, partner *x509.Certificate) cms.KeyStore {
+		return cms.StaticKeyStore{PrivateKey: key, OwnCertificate: own, Partners: map[string]*x509.Certificate{code: partner}}
+	}
+	for _, scenario := range []struct {
+		with           string
+		initiatorKeys  cms.KeyStore
+		responderKeys  cms.KeyStore
+		initiatorError string
+		responderError string
+	}{
+		{
+			with:           "a responder without secure authentication",
+			initiatorKeys:  keys(initiatorKey, initiatorCertificate, "O0177RESPONDER", responderCertificate),
+			initiatorError: "session ended by partner with reason 12: secure authentication wasn't agreed",
+			responderError: "secure authentication wasn't agreed",
+		},
+		{
+			with:           "an unknown initiator certificate",
+			initiatorKeys:  keys(initiatorKey, initiatorCertificate, "O0177RESPONDER", responderCertificate),
+			responderKeys:  keys(responderKey, responderCertificate, "O0177OTHER", otherCertificate),
+			initiatorError: "session ended by partner with reason 12: missing certificate of partner: O0177INITIATOR",
+			responderError: "missing certificate of partner: O0177INITIATOR",
+		},
+		{
+			with:           "a wrong initiator certificate",
+			initiatorKeys:  keys(initiatorKey, initiatorCertificate, "O0177RESPONDER", responderCertificate),
+			responderKeys:  keys(responderKey, responderCertificate, "O0177INITIATOR", otherCertificate),
+			initiatorError: "can't decrypt the content encryption key: crypto/rsa: decryption error",
+			responderError: "session ended by partner with reason 12: can't decrypt the content encryption key: crypto/rsa: decryption error",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			initiator := stationConfig(t, "INITIATOR")
+			initiator.SecureAuthentication = scenario.initiatorKeys != nil
+			initiator.KeyStore = scenario.initiatorKeys
+			responder := stationConfig(t, "RESPONDER")
+			responder.SecureAuthentication = scenario.responderKeys != nil
+			responder.KeyStore = scenario.responderKeys
+
+			_, _, initiatorErr, responderErr := start(t, initiator, responder)
+			require.EqualError(t, initiatorErr, scenario.initiatorError)
+			require.EqualError(t, responderErr, scenario.responderError)
+		})
+	}
+}
+
+// start runs the start session phase of both sides.
+func start(t *testing.T, initiator, responder session.Config) (*session.Session, *session.Session, error, error) {
+	t.Helper()
+	initiatorConnection, responderConnection := net.Pipe()
+	t.Cleanup(func() {
+		_ = initiatorConnection.Close()
+		_ = responderConnection.Close()
+	})
+	var responderSession *session.Session
+	responderErr := make(chan error, 1)
+	go func() {
+		var err error
+		responderSession, err = session.Accept(responderConnection, responder)
+		responderErr <- err
+	}()
+	initiatorSession, initiatorErr := session.Initiate(initiatorConnection, initiator)
+	return initiatorSession, responderSession, initiatorErr, <-responderErr
+}
+
+func stationConfig(t *testing.T, organisation string) session.Config {
+	t.Helper()
+	return session.Config{
+		IdentificationCode:     testkeys.IdentificationCode(t, organisation),
+		Password:               "SECRET",
+		DataExchangeBufferSize: 128,
+		Credit:                 1,
+	}
+}
@@ -0,0 +1,87 @@
+package session
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"io"
+	"os"
+)
+
+// maxReasonTextLength is the longest reason text ESID, SFNA and EFNA can carry.
+const maxReasonTextLength = 999
+
+// Conn exchanges commands with the partner and keeps them in line with the Machine.
+type Conn struct {
+	machine *Machine
+	reader  *oftp2.StreamTransmissionReader
+	writer  *oftp2.StreamTransmissionWriter
+}
+
+func NewConn(connection io.ReadWriter, side Side) *Conn {
+	return &Conn{
+		machine: NewMachine(side),
+		reader:  oftp2.NewStreamTransmissionReader(connection),
+		writer:  oftp2.NewStreamTransmissionWriter(connection),
+	}
+}
+
+// Send writes a command, if it is legal in the current state.
+func (c *Conn) Send(msg oftp2.Message) error {
+	if err := c.machine.Send(msg); err != nil {
+		return err
+	}
+	return c.writer.WriteCommand(msg.Encode())
+}
+
+// Receive reads the next command from the partner.
+// Invalid and unexpected commands are returned as oftp2.EndSessionError, which can be passed to Abort.
+// A read deadline of the connection, that passed, ends the session by time out as well.
+func (c *Conn) Receive() (oftp2.Message, error) {
+	cmd, err := c.reader.ReadCommand()
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil, oftp2.NewEndSessionError(oftp2.EndSessionTimeOut, errors.New("no command from the partner in time"))
+	} else if err != nil {
+		return nil, err
+	}
+	msg, err := oftp2.Decode(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.machine.Receive(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Abort ends the session with the reason of err and returns err.
+// Errors that aren't an oftp2.EndSessionError, like a broken connection, end the session without ESID.
+func (c *Conn) Abort(err error) error {
+	var endSessionErr oftp2.EndSessionError
+	if c.machine.Ended() || !errors.As(err, &endSessionErr) {
+		return err
+	}
+	esid, esidErr := oftp2.NewEndSession(oftp2.EndSessionInput{
+		Reason:     endSessionErr.Reason,
+		ReasonText: reasonText(err),
+	})
+	if esidErr != nil {
+		return esidErr
+	}
+	if sendErr := c.Send(oftp2.EndSessionCmd(esid)); sendErr != nil {
+		return sendErr
+	}
+	return err
+}
+
+// Machine exposes the state of the session.
+func (c *Conn) Machine() *Machine {
+	return c.machine
+}
+
+func reasonText(err error) string {
+	text := err.Error()
+	if len(text) > maxReasonTextLength {
+		return text[:maxReasonTextLength]
+	}
+	return text
+}
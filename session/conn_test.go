@@ -0,0 +1,96 @@
+package session_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestConn(t *testing.T) {
+	initiatorConnection, responderConnection := net.Pipe()
+	defer initiatorConnection.Close()
+	defer responderConnection.Close()
+	initiator := session.NewConn(initiatorConnection, session.Initiator)
+	responder := session.NewConn(responderConnection, session.Responder)
+
+	go func() {
+		_ = responder.Send(oftp2.StartSessionReadyMessageCmd(oftp2.NewStartSessionReadyMessage()))
+	}()
+	msg, err := initiator.Receive()
+	require.NoError(t, err)
+	require.IsType(t, oftp2.StartSessionReadyMessageCmd{}, msg)
+	require.Equal(t, session.StateWaitSSID, initiator.Machine().State())
+
+	go func() {
+		_, _ = initiatorConnection.Write(oftp2.NewChangeDirection().StreamTransmissionBuffer())
+	}()
+	msg, err = responder.Receive()
+	require.EqualError(t, err, "unexpected command R from listener in state waitSSID")
+	require.Nil(t, msg)
+
+	go func() {
+		_ = responder.Abort(err)
+	}()
+	msg, err = initiator.Receive()
+	require.NoError(t, err)
+	esid := msg.(oftp2.EndSessionCmd)
+	require.Equal(t, oftp2.EndSessionProtocolViolation, esid.ReasonCode())
+	require.Equal(t, "unexpected command R from listener in state waitSSID", esid.ReasonText())
+	require.True(t, initiator.Machine().Ended())
+	require.True(t, responder.Machine().Ended())
+}
+
+func TestConn_SendUnexpectedCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	conn := session.NewConn(buf, session.Initiator)
+	require.EqualError(t, conn.Send(oftp2.ChangeDirectionCmd(oftp2.NewChangeDirection())), "unexpected command R from listener in state start")
+	require.Empty(t, buf.Bytes())
+}
+
+func TestConn_Abort(t *testing.T) {
+	for _, scenario := range []struct {
+		with   string
+		err    error
+		expect func(t *testing.T, written []byte)
+	}{
+		{
+			with: "a connection error",
+			err:  io.ErrUnexpectedEOF,
+			expect: func(t *testing.T, written []byte) {
+				require.Empty(t, written)
+			},
+		},
+		{
+			with: "an end session error",
+			err:  oftp2.NewEndSessionError(oftp2.EndSessionResourcesNotAvailable, errors.New("no space left")),
+			expect: func(t *testing.T, written []byte) {
+				esid, err := oftp2.NewStreamTransmissionReader(bytes.NewReader(written)).ReadCommand()
+				require.NoError(t, err)
+				require.Equal(t, oftp2.EndSessionResourcesNotAvailable, oftp2.EndSessionCmd(esid).ReasonCode())
+				require.Equal(t, "no space left", oftp2.EndSessionCmd(esid).ReasonText())
+			},
+		},
+		{
+			with: "a too long reason",
+			err:  oftp2.NewEndSessionError(oftp2.EndSessionUnspecified, errors.New(strings.Repeat("A", 1000))),
+			expect: func(t *testing.T, written []byte) {
+				esid, err := oftp2.NewStreamTransmissionReader(bytes.NewReader(written)).ReadCommand()
+				require.NoError(t, err)
+				require.Equal(t, strings.Repeat("A", 999), oftp2.EndSessionCmd(esid).ReasonText())
+			},
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			conn := session.NewConn(buf, session.Responder)
+			require.Equal(t, scenario.err, conn.Abort(scenario.err))
+			scenario.expect(t, buf.Bytes())
+		})
+	}
+}
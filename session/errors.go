@@ -0,0 +1,61 @@
+package session
+
+import (
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+)
+
+// UnexpectedCommandError is a command that isn't legal in the current state.
+// The Machine returns it wrapped into an oftp2.EndSessionError with the reason EndSessionProtocolViolation.
+type UnexpectedCommandError struct {
+	State  State
+	Sender Role
+	Id     oftp2.Id
+}
+
+func (e UnexpectedCommandError) Error() string {
+	return fmt.Sprintf("unexpected command %v from %v in state %v", e.Id, e.Sender, e.State)
+}
+
+// InterruptedError is a file transfer that broke off.
+// The file can be sent again with the restart position Position, if restart was agreed for the session.
+type InterruptedError struct {
+	Position int64
+	Err      error
+}
+
+func (e InterruptedError) Error() string {
+	return fmt.Sprintf("transfer interrupted at position %d: %v", e.Position, e.Err)
+}
+
+func (e InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// ReceiptError is a signed end to end response, whose signature or hash didn't match the sent file.
+type ReceiptError struct {
+	Receipt oftp2.EndToEndResponseCmd
+	Err     error
+}
+
+func (e ReceiptError) Error() string {
+	return fmt.Sprintf("invalid receipt for %v: %v", e.Receipt.Name(), e.Err)
+}
+
+func (e ReceiptError) Unwrap() error {
+	return e.Err
+}
+
+// RefusalError is a file sent by Run, which the partner refused with SFNA or EFNA.
+type RefusalError struct {
+	StartFile oftp2.StartFileInput
+	Err       error
+}
+
+func (e RefusalError) Error() string {
+	return fmt.Sprintf("%v refused: %v", e.StartFile.Name, e.Err)
+}
+
+func (e RefusalError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,281 @@
+// Package session implements the OFTP2 protocol phases as a state machine,
+// which both sides of a connection are built upon.
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-9
+package session
+
+import (
+	"github.com/elgohr/go-oftp2/oftp2"
+	"sort"
+)
+
+// Side is the part a station took when the connection was established.
+type Side int
+
+const (
+	Initiator Side = iota
+	Responder
+)
+
+func (s Side) String() string {
+	if s == Initiator {
+		return "initiator"
+	}
+	return "responder"
+}
+
+func (s Side) other() Side {
+	if s == Initiator {
+		return Responder
+	}
+	return Initiator
+}
+
+// Role tells whether a station is currently allowed to send files.
+type Role int
+
+const (
+	Speaker Role = iota
+	Listener
+)
+
+func (r Role) String() string {
+	if r == Speaker {
+		return "speaker"
+	}
+	return "listener"
+}
+
+func (r Role) other() Role {
+	if r == Speaker {
+		return Listener
+	}
+	return Speaker
+}
+
+type Phase int
+
+const (
+	PhaseStartSession Phase = iota
+	PhaseStartFile
+	PhaseDataTransfer
+	PhaseEndFile
+	PhaseEndSession
+)
+
+func (p Phase) String() string {
+	return [...]string{"start session", "start file", "data transfer", "end file", "end session"}[p]
+}
+
+type State string
+
+const (
+	// StateStart waits for the responder to send SSRM.
+	StateStart State = "start"
+	// StateWaitSSID waits for the initiator to send SSID.
+	StateWaitSSID State = "waitSSID"
+	// StateWaitSSIDAnswer waits for the responder to answer with SSID.
+	StateWaitSSIDAnswer State = "waitSSIDAnswer"
+	// StateSessionStarted is StateSpeakerIdle, where the initiator may also start the secure authentication.
+	StateSessionStarted State = "sessionStarted"
+	// StateWaitAUCH waits for the responder to challenge the initiator.
+	StateWaitAUCH State = "waitAUCH"
+	// StateWaitAURP waits for the initiator to answer the challenge.
+	StateWaitAURP State = "waitAURP"
+	// StateWaitSECD waits for the responder to hand the authentication back.
+	StateWaitSECD State = "waitSECD"
+	// StateWaitSecondAUCH waits for the initiator to challenge the responder.
+	StateWaitSecondAUCH State = "waitSecondAUCH"
+	// StateWaitSecondAURP waits for the responder to answer the challenge.
+	StateWaitSecondAURP State = "waitSecondAURP"
+	// StateSpeakerIdle waits for the speaker to start a file, send an end to end response, change direction or end the session.
+	StateSpeakerIdle State = "speakerIdle"
+	// StateWaitSFAnswer waits for the listener to accept or refuse the file.
+	StateWaitSFAnswer State = "waitSFAnswer"
+	// StateDataTransfer waits for data or the end of the file.
+	StateDataTransfer State = "dataTransfer"
+	// StateWaitEFAnswer waits for the listener to accept or refuse the received file.
+	StateWaitEFAnswer State = "waitEFAnswer"
+	// StateMustChangeDirection waits for the speaker to change direction, as the listener asked for it in EFPA.
+	StateMustChangeDirection State = "mustChangeDirection"
+	// StateWaitRTR waits for the listener to confirm an end to end response.
+	StateWaitRTR State = "waitRTR"
+	// StateEnded is reached when either side sent ESID.
+	StateEnded State = "ended"
+)
+
+var phases = map[State]Phase{
+	StateStart:               PhaseStartSession,
+	StateWaitSSID:            PhaseStartSession,
+	StateWaitSSIDAnswer:      PhaseStartSession,
+	StateSessionStarted:      PhaseStartSession,
+	StateWaitAUCH:            PhaseStartSession,
+	StateWaitAURP:            PhaseStartSession,
+	StateWaitSECD:            PhaseStartSession,
+	StateWaitSecondAUCH:      PhaseStartSession,
+	StateWaitSecondAURP:      PhaseStartSession,
+	StateSpeakerIdle:         PhaseStartFile,
+	StateWaitSFAnswer:        PhaseStartFile,
+	StateWaitRTR:             PhaseStartFile,
+	StateDataTransfer:        PhaseDataTransfer,
+	StateWaitEFAnswer:        PhaseEndFile,
+	StateMustChangeDirection: PhaseEndFile,
+	StateEnded:               PhaseEndSession,
+}
+
+type transitionKey struct {
+	state  State
+	sender Role
+	id     oftp2.Id
+}
+
+type transition struct {
+	next State
+	// changeDirection swaps speaker and listener
+	changeDirection bool
+}
+
+// transitions lists every legal command by state and sender.
+// ESID is legal from both roles in every state but StateEnded and therefore not listed.
+// During the start session phase the responder starts as speaker.
+var transitions = map[transitionKey]transition{
+	{StateStart, Speaker, oftp2.StartSessionReadyMessage}:     {next: StateWaitSSID},
+	{StateWaitSSID, Listener, oftp2.StartSessionMessage}:      {next: StateWaitSSIDAnswer},
+	{StateWaitSSIDAnswer, Speaker, oftp2.StartSessionMessage}: {next: StateSessionStarted, changeDirection: true},
+
+	{StateSessionStarted, Speaker, oftp2.SecurityChangeDirectionMessage}: {next: StateWaitAUCH, changeDirection: true},
+	{StateWaitAUCH, Speaker, oftp2.AuthenticationChallengeMessage}:       {next: StateWaitAURP},
+	{StateWaitAURP, Listener, oftp2.AuthenticationResponseMessage}:       {next: StateWaitSECD},
+	{StateWaitSECD, Speaker, oftp2.SecurityChangeDirectionMessage}:       {next: StateWaitSecondAUCH, changeDirection: true},
+	{StateWaitSecondAUCH, Speaker, oftp2.AuthenticationChallengeMessage}: {next: StateWaitSecondAURP},
+	{StateWaitSecondAURP, Listener, oftp2.AuthenticationResponseMessage}: {next: StateSpeakerIdle},
+
+	{StateSpeakerIdle, Speaker, oftp2.StartFile}:                  {next: StateWaitSFAnswer},
+	{StateSpeakerIdle, Speaker, oftp2.ChangeDirectionMessage}:     {next: StateSpeakerIdle, changeDirection: true},
+	{StateSpeakerIdle, Speaker, oftp2.EndToEndResponseMessage}:    {next: StateWaitRTR},
+	{StateSpeakerIdle, Speaker, oftp2.NegativeEndResponseMessage}: {next: StateWaitRTR},
+	{StateWaitRTR, Listener, oftp2.ReadyToReceiveMessage}:         {next: StateSpeakerIdle},
+
+	{StateWaitSFAnswer, Listener, oftp2.StartFilePositiveMessage}: {next: StateDataTransfer},
+	{StateWaitSFAnswer, Listener, oftp2.StartFileNegativeMessage}: {next: StateSpeakerIdle},
+
+	{StateDataTransfer, Speaker, oftp2.DataExchangeBufferMessage}: {next: StateDataTransfer},
+	{StateDataTransfer, Listener, oftp2.SetCreditMessage}:         {next: StateDataTransfer},
+	{StateDataTransfer, Speaker, oftp2.EndFile}:                   {next: StateWaitEFAnswer},
+
+	{StateWaitEFAnswer, Listener, oftp2.EndFilePositiveMessage}:       {next: StateSpeakerIdle},
+	{StateWaitEFAnswer, Listener, oftp2.EndFileNegativeMessage}:       {next: StateSpeakerIdle},
+	{StateMustChangeDirection, Speaker, oftp2.ChangeDirectionMessage}: {next: StateSpeakerIdle, changeDirection: true},
+}
+
+func init() {
+	// without secure authentication, the initiator continues as in StateSpeakerIdle
+	started := map[transitionKey]transition{}
+	for key, t := range transitions {
+		if key.state == StateSpeakerIdle {
+			key.state = StateSessionStarted
+			started[key] = t
+		}
+	}
+	for key, t := range started {
+		transitions[key] = t
+	}
+}
+
+// Machine tracks the state of a session from the view of one side.
+// It is not safe for concurrent use.
+type Machine struct {
+	side    Side
+	speaker Side
+	state   State
+}
+
+func NewMachine(side Side) *Machine {
+	return &Machine{
+		side:    side,
+		speaker: Responder,
+		state:   StateStart,
+	}
+}
+
+// Send checks and applies a command sent by this side.
+func (m *Machine) Send(msg oftp2.Message) error {
+	return m.apply(m.Role(), msg)
+}
+
+// Receive checks and applies a command received from the partner.
+func (m *Machine) Receive(msg oftp2.Message) error {
+	return m.apply(m.Role().other(), msg)
+}
+
+// Side is the side of this station.
+func (m *Machine) Side() Side {
+	return m.side
+}
+
+// Role is the current role of this station.
+func (m *Machine) Role() Role {
+	if m.speaker == m.side {
+		return Speaker
+	}
+	return Listener
+}
+
+func (m *Machine) State() State {
+	return m.state
+}
+
+func (m *Machine) Phase() Phase {
+	return phases[m.state]
+}
+
+// Ended tells whether ESID was sent or received.
+func (m *Machine) Ended() bool {
+	return m.state == StateEnded
+}
+
+// Legal lists the commands this station may send in the current state.
+func (m *Machine) Legal() []oftp2.Id {
+	return m.legal(m.Role())
+}
+
+func (m *Machine) legal(sender Role) []oftp2.Id {
+	if m.Ended() {
+		return nil
+	}
+	var ids []oftp2.Id
+	for key := range transitions {
+		if key.state == m.state && key.sender == sender {
+			ids = append(ids, key.id)
+		}
+	}
+	ids = append(ids, oftp2.EndSessionMessage)
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+func (m *Machine) apply(sender Role, msg oftp2.Message) error {
+	id := msg.Id()
+	if id == oftp2.EndSessionMessage && !m.Ended() {
+		m.state = StateEnded
+		return nil
+	}
+	t, legal := transitions[transitionKey{state: m.state, sender: sender, id: id}]
+	if !legal {
+		return oftp2.NewEndSessionError(oftp2.EndSessionProtocolViolation, UnexpectedCommandError{
+			State:  m.state,
+			Sender: sender,
+			Id:     id,
+		})
+	}
+	if efpa, ok := msg.(oftp2.EndFilePositiveAnswerCmd); ok && efpa.ChangeDirection() {
+		t.next = StateMustChangeDirection
+	}
+	m.state = t.next
+	if t.changeDirection {
+		m.speaker = m.speaker.other()
+	}
+	return nil
+}
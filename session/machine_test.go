@@ -0,0 +1,332 @@
+package session_test
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/session"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// command is a message that only carries its id, as the machine doesn't look into most commands
+type command oftp2.Id
+
+func (c command) Id() oftp2.Id {
+	return oftp2.Id(c)
+}
+
+func (c command) Valid() error {
+	return nil
+}
+
+func (c command) Encode() oftp2.Command {
+	return oftp2.Command{byte(c)}
+}
+
+type step struct {
+	from session.Side
+	msg  oftp2.Message
+}
+
+func startSession() []step {
+	return []step{
+		{session.Responder, command(oftp2.StartSessionReadyMessage)},
+		{session.Initiator, command(oftp2.StartSessionMessage)},
+		{session.Responder, command(oftp2.StartSessionMessage)},
+	}
+}
+
+func sendFile(from session.Side, changeDirection bool) []step {
+	to := session.Responder
+	if from == session.Responder {
+		to = session.Initiator
+	}
+	return []step{
+		{from, command(oftp2.StartFile)},
+		{to, command(oftp2.StartFilePositiveMessage)},
+		{from, command(oftp2.DataExchangeBufferMessage)},
+		{to, command(oftp2.SetCreditMessage)},
+		{from, command(oftp2.DataExchangeBufferMessage)},
+		{from, command(oftp2.EndFile)},
+		{to, oftp2.EndFilePositiveAnswerCmd(oftp2.NewEndFilePositiveAnswer(changeDirection))},
+	}
+}
+
+func join(steps ...[]step) []step {
+	var joined []step
+	for _, s := range steps {
+		joined = append(joined, s...)
+	}
+	return joined
+}
+
+func exchange(t *testing.T, initiator, responder *session.Machine, steps []step) {
+	for _, s := range steps {
+		sender, receiver := initiator, responder
+		if s.from == session.Responder {
+			sender, receiver = responder, initiator
+		}
+		require.NoError(t, sender.Send(s.msg), s.msg.Id())
+		require.NoError(t, receiver.Receive(s.msg), s.msg.Id())
+	}
+}
+
+func TestMachine(t *testing.T) {
+	for _, scenario := range []struct {
+		with    string
+		steps   []step
+		state   session.State
+		phase   session.Phase
+		speaker session.Side
+	}{
+		{
+			with:    "a new session",
+			state:   session.StateStart,
+			phase:   session.PhaseStartSession,
+			speaker: session.Responder,
+		},
+		{
+			with:    "a started session",
+			steps:   startSession(),
+			state:   session.StateSessionStarted,
+			phase:   session.PhaseStartSession,
+			speaker: session.Initiator,
+		},
+		{
+			with: "secure authentication",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.SecurityChangeDirectionMessage)},
+				{session.Responder, command(oftp2.AuthenticationChallengeMessage)},
+				{session.Initiator, command(oftp2.AuthenticationResponseMessage)},
+				{session.Responder, command(oftp2.SecurityChangeDirectionMessage)},
+				{session.Initiator, command(oftp2.AuthenticationChallengeMessage)},
+				{session.Responder, command(oftp2.AuthenticationResponseMessage)},
+			}),
+			state:   session.StateSpeakerIdle,
+			phase:   session.PhaseStartFile,
+			speaker: session.Initiator,
+		},
+		{
+			with: "a started file",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.StartFile)},
+			}),
+			state:   session.StateWaitSFAnswer,
+			phase:   session.PhaseStartFile,
+			speaker: session.Initiator,
+		},
+		{
+			with: "a refused file",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.StartFile)},
+				{session.Responder, command(oftp2.StartFileNegativeMessage)},
+			}),
+			state:   session.StateSpeakerIdle,
+			phase:   session.PhaseStartFile,
+			speaker: session.Initiator,
+		},
+		{
+			with: "a file being transferred",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.StartFile)},
+				{session.Responder, command(oftp2.StartFilePositiveMessage)},
+				{session.Initiator, command(oftp2.DataExchangeBufferMessage)},
+			}),
+			state:   session.StateDataTransfer,
+			phase:   session.PhaseDataTransfer,
+			speaker: session.Initiator,
+		},
+		{
+			with: "an ended file",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.StartFile)},
+				{session.Responder, command(oftp2.StartFilePositiveMessage)},
+				{session.Initiator, command(oftp2.EndFile)},
+			}),
+			state:   session.StateWaitEFAnswer,
+			phase:   session.PhaseEndFile,
+			speaker: session.Initiator,
+		},
+		{
+			with:    "a sent file",
+			steps:   join(startSession(), sendFile(session.Initiator, false)),
+			state:   session.StateSpeakerIdle,
+			phase:   session.PhaseStartFile,
+			speaker: session.Initiator,
+		},
+		{
+			with: "a refused end of file",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.StartFile)},
+				{session.Responder, command(oftp2.StartFilePositiveMessage)},
+				{session.Initiator, command(oftp2.EndFile)},
+				{session.Responder, command(oftp2.EndFileNegativeMessage)},
+			}),
+			state:   session.StateSpeakerIdle,
+			phase:   session.PhaseStartFile,
+			speaker: session.Initiator,
+		},
+		{
+			with:    "a requested change of direction",
+			steps:   join(startSession(), sendFile(session.Initiator, true)),
+			state:   session.StateMustChangeDirection,
+			phase:   session.PhaseEndFile,
+			speaker: session.Initiator,
+		},
+		{
+			with: "a changed direction",
+			steps: join(startSession(), sendFile(session.Initiator, true), []step{
+				{session.Initiator, command(oftp2.ChangeDirectionMessage)},
+			}),
+			state:   session.StateSpeakerIdle,
+			phase:   session.PhaseStartFile,
+			speaker: session.Responder,
+		},
+		{
+			with: "files in both directions",
+			steps: join(startSession(), sendFile(session.Initiator, false), []step{
+				{session.Initiator, command(oftp2.ChangeDirectionMessage)},
+			}, sendFile(session.Responder, false), []step{
+				{session.Responder, command(oftp2.EndToEndResponseMessage)},
+				{session.Initiator, command(oftp2.ReadyToReceiveMessage)},
+				{session.Responder, command(oftp2.NegativeEndResponseMessage)},
+			}),
+			state:   session.StateWaitRTR,
+			phase:   session.PhaseStartFile,
+			speaker: session.Responder,
+		},
+		{
+			with: "an ended session",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.ChangeDirectionMessage)},
+				{session.Responder, command(oftp2.EndSessionMessage)},
+			}),
+			state:   session.StateEnded,
+			phase:   session.PhaseEndSession,
+			speaker: session.Responder,
+		},
+		{
+			with: "a session ended by the listener",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.StartFile)},
+				{session.Responder, command(oftp2.EndSessionMessage)},
+			}),
+			state:   session.StateEnded,
+			phase:   session.PhaseEndSession,
+			speaker: session.Initiator,
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			initiator := session.NewMachine(session.Initiator)
+			responder := session.NewMachine(session.Responder)
+			exchange(t, initiator, responder, scenario.steps)
+			for _, m := range []*session.Machine{initiator, responder} {
+				require.Equal(t, scenario.state, m.State())
+				require.Equal(t, scenario.phase, m.Phase())
+				require.Equal(t, scenario.state == session.StateEnded, m.Ended())
+				if m.Side() == scenario.speaker {
+					require.Equal(t, session.Speaker, m.Role())
+				} else {
+					require.Equal(t, session.Listener, m.Role())
+				}
+			}
+		})
+	}
+}
+
+func TestMachine_UnexpectedCommand(t *testing.T) {
+	for _, scenario := range []struct {
+		with  string
+		steps []step
+		next  step
+		error string
+	}{
+		{
+			with:  "SSID before SSRM",
+			next:  step{session.Initiator, command(oftp2.StartSessionMessage)},
+			error: "unexpected command X from listener in state start",
+		},
+		{
+			with:  "a file before the session started",
+			steps: startSession()[:2],
+			next:  step{session.Initiator, command(oftp2.StartFile)},
+			error: "unexpected command H from listener in state waitSSIDAnswer",
+		},
+		{
+			with:  "a file from the listener",
+			steps: startSession(),
+			next:  step{session.Responder, command(oftp2.StartFile)},
+			error: "unexpected command H from listener in state sessionStarted",
+		},
+		{
+			with: "data before the file was accepted",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.StartFile)},
+			}),
+			next:  step{session.Initiator, command(oftp2.DataExchangeBufferMessage)},
+			error: "unexpected command D from speaker in state waitSFAnswer",
+		},
+		{
+			with:  "a file instead of the requested change of direction",
+			steps: join(startSession(), sendFile(session.Initiator, true)),
+			next:  step{session.Initiator, command(oftp2.StartFile)},
+			error: "unexpected command H from speaker in state mustChangeDirection",
+		},
+		{
+			with: "secure authentication after a file",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.StartFile)},
+				{session.Responder, command(oftp2.StartFileNegativeMessage)},
+			}),
+			next:  step{session.Initiator, command(oftp2.SecurityChangeDirectionMessage)},
+			error: "unexpected command J from speaker in state speakerIdle",
+		},
+		{
+			with: "a command after the session ended",
+			steps: join(startSession(), []step{
+				{session.Initiator, command(oftp2.EndSessionMessage)},
+			}),
+			next:  step{session.Initiator, command(oftp2.EndSessionMessage)},
+			error: "unexpected command F from speaker in state ended",
+		},
+	} {
+		t.Run(scenario.with, func(t *testing.T) {
+			initiator := session.NewMachine(session.Initiator)
+			responder := session.NewMachine(session.Responder)
+			exchange(t, initiator, responder, scenario.steps)
+			sender, receiver := initiator, responder
+			if scenario.next.from == session.Responder {
+				sender, receiver = responder, initiator
+			}
+			state := sender.State()
+			for _, err := range []error{sender.Send(scenario.next.msg), receiver.Receive(scenario.next.msg)} {
+				require.EqualError(t, err, scenario.error)
+				var endSessionErr oftp2.EndSessionError
+				require.True(t, errors.As(err, &endSessionErr))
+				require.Equal(t, oftp2.EndSessionProtocolViolation, endSessionErr.Reason)
+				var unexpectedErr session.UnexpectedCommandError
+				require.True(t, errors.As(err, &unexpectedErr))
+				require.Equal(t, scenario.next.msg.Id(), unexpectedErr.Id)
+			}
+			require.Equal(t, state, sender.State())
+		})
+	}
+}
+
+func TestMachine_Legal(t *testing.T) {
+	initiator := session.NewMachine(session.Initiator)
+	require.Equal(t, []oftp2.Id{oftp2.EndSessionMessage}, initiator.Legal())
+
+	responder := session.NewMachine(session.Responder)
+	require.Equal(t, []oftp2.Id{oftp2.EndSessionMessage, oftp2.StartSessionReadyMessage}, responder.Legal())
+
+	exchange(t, initiator, responder, startSession())
+	require.Equal(t, []oftp2.Id{
+		oftp2.EndToEndResponseMessage,
+		oftp2.EndSessionMessage,
+		oftp2.StartFile,
+		oftp2.SecurityChangeDirectionMessage,
+		oftp2.NegativeEndResponseMessage,
+		oftp2.ChangeDirectionMessage,
+	}, initiator.Legal())
+}
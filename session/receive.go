@@ -0,0 +1,392 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/fileservices"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/record"
+	"github.com/elgohr/go-oftp2/storage"
+	"hash"
+)
+
+func (s *Session) receiveFile(sfid oftp2.StartFileCmd) error {
+	file, checkpoint, err := s.createFile(sfid)
+	if err != nil {
+		return s.refuseFile(err)
+	}
+	sfpa, err := oftp2.NewStartFilePositiveAnswer(int(checkpoint.Position))
+	if err != nil {
+		_ = file.Abort()
+		return err
+	}
+	if err := s.conn.Send(oftp2.StartFilePositiveAnswerCmd(sfpa)); err != nil {
+		_ = file.Abort()
+		return err
+	}
+	receiver, err := newFileReceiver(sfid, file, checkpoint, s.negotiated.DataExchangeBufferSize, s.negotiated.Credit, s.negotiated.Restart)
+	if err != nil {
+		_ = file.Abort()
+		return err
+	}
+	if !receiver.services.None() {
+		receiver.keys = s.keys()
+	}
+	for {
+		msg, err := s.conn.Receive()
+		if err != nil {
+			receiver.interrupt()
+			return err
+		}
+		switch m := msg.(type) {
+		case oftp2.DataExchangeBuffer:
+			if err := receiver.receive(m); err != nil {
+				receiver.interrupt()
+				return err
+			}
+			if receiver.window.Exhausted() {
+				receiver.window.Reset()
+				receiver.checkpoint()
+				if err := s.conn.Send(oftp2.SetCreditCmd(oftp2.NewSetCredit())); err != nil {
+					receiver.interrupt()
+					return err
+				}
+			}
+		case oftp2.EndFileCmd:
+			return s.endFile(sfid, receiver, m)
+		default:
+			receiver.interrupt()
+			return endedByPartner(msg)
+		}
+	}
+}
+
+// createFile opens the local file for a received SFID.
+// A restarted file continues at the checkpoint of the Store, if restart was agreed for the session.
+// Files whose checkpoint exceeds the restart position start from scratch.
+func (s *Session) createFile(sfid oftp2.StartFileCmd) (storage.Writer, record.Checkpoint, error) {
+	services := fileservices.ServicesOf(sfid)
+	if err := services.Valid(); err != nil {
+		return nil, record.Checkpoint{}, err
+	} else if !services.None() && record.HasRecords(sfid.Format()) {
+		return nil, record.Checkpoint{}, oftp2.NewStartFileError(oftp2.AnswerStorageRecordFormatNotSupported, fmt.Errorf("file services need an unstructured or text file, but got %v", string(sfid.Format())))
+	} else if services.Encrypted() && s.config.KeyStore == nil {
+		return nil, record.Checkpoint{}, oftp2.NewStartFileError(oftp2.AnswerEncryptedFileNotAllowed, errors.New("encrypted files aren't accepted"))
+	} else if services.Signed() && s.config.KeyStore == nil {
+		return nil, record.Checkpoint{}, oftp2.NewStartFileError(oftp2.AnswerSignedFileNotAllowed, errors.New("signed files aren't accepted"))
+	} else if err := s.signedReceipts(sfid); err != nil {
+		return nil, record.Checkpoint{}, err
+	} else if !s.negotiated.Receive {
+		return nil, record.Checkpoint{}, oftp2.NewStartFileError(oftp2.AnswerFileDirectionRefused, errors.New("receiving files wasn't agreed"))
+	} else if s.config.Store == nil {
+		return nil, record.Checkpoint{}, oftp2.NewStartFileError(oftp2.AnswerFileDirectionRefused, errors.New("files aren't accepted"))
+	}
+	id := storage.IdentityOf(sfid)
+	// files with services or signed receipts are received from scratch, as they are processed as a whole
+	if !s.negotiated.Restart || sfid.RestartPosition() == 0 || !services.None() || sfid.SignedEERPRequested() {
+		file, err := s.config.Store.Create(id)
+		return file, record.Checkpoint{}, err
+	}
+	file, checkpoint, err := s.config.Store.Resume(id)
+	if err != nil {
+		return nil, record.Checkpoint{}, err
+	}
+	if checkpoint.Position > sfid.RestartPosition() {
+		if err := file.Abort(); err != nil {
+			return nil, record.Checkpoint{}, oftp2.NewStartFileError(oftp2.AnswerAccessMethodFailure, err)
+		}
+		file, err = s.config.Store.Create(id)
+		return file, record.Checkpoint{}, err
+	}
+	return file, checkpoint, nil
+}
+
+// signedReceipts checks that a signed EERP can be sent, if the file asks for it.
+func (s *Session) signedReceipts(sfid oftp2.StartFileCmd) error {
+	if !sfid.SignedEERPRequested() {
+		return nil
+	} else if _, err := cms.SuiteOf(sfid.Cipher()); err != nil {
+		return oftp2.NewStartFileError(oftp2.AnswerCipherSuiteNotSupported, fmt.Errorf("signed receipts need a cipher suite: %w", err))
+	} else if s.config.KeyStore == nil {
+		return oftp2.NewStartFileError(oftp2.AnswerUnspecified, errors.New("signed receipts aren't supported"))
+	} else if _, _, err := s.config.KeyStore.Key(); err != nil {
+		return oftp2.NewStartFileError(oftp2.AnswerUnspecified, fmt.Errorf("signed receipts aren't supported: %w", err))
+	}
+	return nil
+}
+
+func (s *Session) refuseFile(err error) error {
+	refusal := oftp2.NewStartFileError(oftp2.AnswerUnspecified, err)
+	errors.As(err, &refusal)
+	sfna, sfnaErr := oftp2.NewStartFileNegativeAnswer(oftp2.NegativeFileInput{
+		Reason:     refusal.Reason,
+		Retry:      refusal.Retry,
+		ReasonText: reasonText(err),
+	})
+	if sfnaErr != nil {
+		return sfnaErr
+	}
+	return s.conn.Send(oftp2.StartFileNegativeAnswerCmd(sfna))
+}
+
+func (s *Session) endFile(sfid oftp2.StartFileCmd, receiver *fileReceiver, efid oftp2.EndFileCmd) error {
+	if err := receiver.complete(efid); err != nil {
+		_ = receiver.file.Abort()
+		refusal := oftp2.NewEndFileError(oftp2.EndFileAnswerUnspecified, err)
+		errors.As(err, &refusal)
+		efna, efnaErr := oftp2.NewEndFileNegativeAnswer(oftp2.NegativeEndFileInput{
+			Reason:     refusal.Reason,
+			ReasonText: reasonText(err),
+		})
+		if efnaErr != nil {
+			return efnaErr
+		}
+		return s.conn.Send(oftp2.EndFileNegativeAnswerCmd(efna))
+	}
+	// a station with own files or end to end responses asks to become speaker
+	changeDirection := len(s.queue) > 0 || len(s.responses) > 0
+	if err := s.conn.Send(oftp2.EndFilePositiveAnswerCmd(oftp2.NewEndFilePositiveAnswer(changeDirection))); err != nil {
+		return err
+	}
+	eerp, err := s.receipt(sfid, receiver)
+	if err != nil {
+		return err
+	}
+	s.responses = append(s.responses, eerp)
+	s.received = append(s.received, sfid)
+	return nil
+}
+
+// receipt is the EERP for a received file, which carries the hash of the virtual file and its signature, if the file asks for it.
+func (s *Session) receipt(sfid oftp2.StartFileCmd, receiver *fileReceiver) (oftp2.EndToEndResponseCmd, error) {
+	if !sfid.SignedEERPRequested() {
+		eerp, err := oftp2.NewEndToEndResponse(oftp2.EndToEndResponseInputFor(sfid))
+		return oftp2.EndToEndResponseCmd(eerp), err
+	}
+	return fileservices.SignReceipt(oftp2.EndToEndResponseInputFor(sfid), receiver.digest.Sum(nil), sfid.Cipher(), s.keys())
+}
+
+// fileReceiver turns received DATA into the local file.
+type fileReceiver struct {
+	file       storage.Writer
+	writer     *record.Writer
+	decoder    *oftp2.SubrecordDecoder
+	window     *oftp2.CreditWindow
+	bufferSize int
+	records    bool
+	services   fileservices.Services
+	keys       fileservices.Keys
+	// transmitted keeps the received data of files with file services, which are unwrapped at the end of the file
+	transmitted *bytes.Buffer
+	// stream decompresses files that are only compressed while they are received
+	stream *fileservices.Decompressor
+	// units counts the received octets of files with file services
+	units int64
+	// digest hashes the virtual file as it is transmitted for a signed receipt
+	digest hash.Hash
+	// transmittedSize and originalSize are the sizes announced in SFID
+	transmittedSize int64
+	originalSize    int64
+	// restart keeps an interrupted file for a restart, if the file supports it
+	restart bool
+	// failure keeps the first error, which is reported by EFNA at the end of the file
+	failure error
+}
+
+func newFileReceiver(sfid oftp2.StartFileCmd, file storage.Writer, checkpoint record.Checkpoint, bufferSize int, credit int, restart bool) (*fileReceiver, error) {
+	window, err := oftp2.NewCreditWindow(credit)
+	if err != nil {
+		return nil, err
+	}
+	receiver := &fileReceiver{
+		file:            file,
+		decoder:         oftp2.NewSubrecordDecoder(),
+		window:          window,
+		bufferSize:      bufferSize,
+		records:         record.HasRecords(sfid.Format()),
+		services:        fileservices.ServicesOf(sfid),
+		restart:         restart,
+		transmittedSize: sfid.FileSize(),
+		originalSize:    sfid.OriginalFileSize(),
+	}
+	receiver.writer, receiver.failure = record.ResumeWriter(file, record.Config{
+		Format:        sfid.Format(),
+		MaxRecordSize: sfid.MaxRecordSize(),
+		LocalForm:     record.DefaultLocalForm(sfid.Format()),
+	}, checkpoint)
+	if receiver.failure != nil {
+		receiver.failure = oftp2.NewEndFileError(oftp2.EndFileAnswerMaximumRecordLengthNotSupported, receiver.failure)
+		return receiver, nil
+	}
+	if sfid.SignedEERPRequested() {
+		if receiver.digest, err = fileservices.NewDigest(sfid.Cipher()); err != nil {
+			return nil, err
+		}
+		receiver.restart = false
+	}
+	if !receiver.services.None() {
+		receiver.records = false
+		receiver.restart = false
+		if receiver.services.Streamed() {
+			receiver.stream = fileservices.NewDecompressor(receiver.writer)
+		} else {
+			receiver.transmitted = &bytes.Buffer{}
+		}
+	}
+	return receiver, nil
+}
+
+func (r *fileReceiver) receive(data oftp2.DataExchangeBuffer) error {
+	if err := r.window.Consume(); err != nil {
+		return err
+	}
+	if l := len(data); l > r.bufferSize {
+		return oftp2.NewEndSessionError(oftp2.EndSessionExchangeBufferSizeError, fmt.Errorf("buffer of %d octets exceeds %d", l, r.bufferSize))
+	}
+	records, err := r.decoder.Decode(data)
+	if err != nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionCommandContainedInvalidData, err)
+	}
+	if r.failure != nil {
+		return nil
+	}
+	if !r.services.None() {
+		for _, data := range append(records, r.decoder.Flush()) {
+			r.units += int64(len(data))
+			r.hash(data)
+			if r.stream == nil {
+				r.transmitted.Write(data)
+			} else if _, err := r.stream.Write(data); err != nil {
+				r.failure = oftp2.NewEndFileError(oftp2.EndFileAnswerFileDecompressionFailure, err)
+				return nil
+			}
+		}
+		return nil
+	}
+	if r.records {
+		for _, rec := range records {
+			if err := r.writer.WriteRecord(rec); err != nil {
+				r.failure = oftp2.NewEndFileError(oftp2.EndFileAnswerInvalidRecordCount, err)
+				return nil
+			}
+			r.hash(rec)
+		}
+		return nil
+	}
+	// unstructured and text files don't rely on the end of record flag
+	for _, data := range append(records, r.decoder.Flush()) {
+		if _, err := r.writer.Write(data); err != nil {
+			r.failure = oftp2.NewEndFileError(oftp2.EndFileAnswerAccessMethodFailure, err)
+			return nil
+		}
+		r.hash(data)
+	}
+	return nil
+}
+
+// checkpoint persists a file that can be restarted at the end of each credit window,
+// so that the partner can restart it, even if the session ends without interrupting it.
+func (r *fileReceiver) checkpoint() {
+	if !r.restart || r.failure != nil {
+		return
+	}
+	if err := r.file.Checkpoint(r.writer.Checkpoint()); err != nil {
+		r.failure = oftp2.NewEndFileError(oftp2.EndFileAnswerAccessMethodFailure, err)
+	}
+}
+
+// interrupt keeps a file that broke off up to its last checkpoint, so that the partner can restart it.
+// Files that can't be restarted are discarded.
+func (r *fileReceiver) interrupt() {
+	_ = r.drain()
+	if r.restart && r.failure == nil {
+		if err := r.file.Suspend(r.writer.Checkpoint()); err == nil {
+			return
+		}
+	}
+	_ = r.file.Abort()
+}
+
+func (r *fileReceiver) complete(efid oftp2.EndFileCmd) error {
+	if r.failure != nil {
+		_ = r.drain()
+		return r.failure
+	}
+	if pending := r.decoder.Flush(); len(pending) > 0 {
+		return oftp2.NewEndFileError(oftp2.EndFileAnswerInvalidRecordCount, fmt.Errorf("last record of %d octets isn't ended", len(pending)))
+	}
+	if !r.services.None() {
+		return r.completeServices(efid)
+	}
+	if err := r.writer.Close(); err != nil {
+		return oftp2.NewEndFileError(oftp2.EndFileAnswerAccessMethodFailure, err)
+	}
+	if err := r.writer.Verify(efid); err != nil {
+		reason := oftp2.EndFileAnswerInvalidByteCount
+		if r.writer.RecordCount() != efid.RecordCount() {
+			reason = oftp2.EndFileAnswerInvalidRecordCount
+		}
+		return oftp2.NewEndFileError(reason, err)
+	}
+	if err := r.file.Commit(); err != nil {
+		return oftp2.NewEndFileError(oftp2.EndFileAnswerAccessMethodFailure, err)
+	}
+	return nil
+}
+
+// completeServices checks the received data of a file with file services and stores the unwrapped file.
+// The sizes of SFID are checked as well, as they are calculated from the content for these files.
+func (r *fileReceiver) completeServices(efid oftp2.EndFileCmd) error {
+	if r.units != efid.UnitCount() {
+		_ = r.drain()
+		return oftp2.NewEndFileError(oftp2.EndFileAnswerInvalidByteCount, fmt.Errorf("expected %d units, but got %d", efid.UnitCount(), r.units))
+	} else if size := kilobytes(r.units); size != r.transmittedSize {
+		_ = r.drain()
+		return oftp2.NewEndFileError(oftp2.EndFileAnswerInvalidByteCount, fmt.Errorf("expected a transmitted size of %d blocks, but got %d", r.transmittedSize, size))
+	}
+	if r.stream != nil {
+		if err := r.drain(); err != nil {
+			return oftp2.NewEndFileError(oftp2.EndFileAnswerFileDecompressionFailure, err)
+		}
+	} else {
+		content, err := r.services.Unwrap(r.transmitted.Bytes(), r.keys)
+		if err != nil {
+			return err
+		}
+		if _, err := r.writer.Write(content); err != nil {
+			return oftp2.NewEndFileError(oftp2.EndFileAnswerAccessMethodFailure, err)
+		}
+	}
+	if err := r.writer.Close(); err != nil {
+		return oftp2.NewEndFileError(oftp2.EndFileAnswerAccessMethodFailure, err)
+	}
+	if size := kilobytes(r.writer.UnitCount()); size != r.originalSize {
+		return oftp2.NewEndFileError(oftp2.EndFileAnswerInvalidByteCount, fmt.Errorf("expected an original size of %d blocks, but got %d", r.originalSize, size))
+	}
+	if err := r.file.Commit(); err != nil {
+		return oftp2.NewEndFileError(oftp2.EndFileAnswerAccessMethodFailure, err)
+	}
+	return nil
+}
+
+// hash passes received data to the digest of a signed receipt.
+// Files with file services are hashed as they are transmitted, before they are unwrapped.
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.13
+func (r *fileReceiver) hash(virtual []byte) {
+	if r.digest != nil {
+		r.digest.Write(virtual)
+	}
+}
+
+// drain ends the decompression of a streamed file.
+func (r *fileReceiver) drain() error {
+	if r.stream == nil {
+		return nil
+	}
+	stream := r.stream
+	r.stream = nil
+	return stream.Close()
+}
@@ -0,0 +1,352 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/fileservices"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/record"
+	"hash"
+	"io"
+	"os"
+)
+
+// VirtualFile is a local file to be sent to the partner.
+type VirtualFile struct {
+	// StartFile describes the file. Sizes are taken from Content when they are left empty.
+	StartFile oftp2.StartFileInput
+	// LocalForm is the form Content is stored in.
+	LocalForm record.LocalForm
+	Content   io.Reader
+}
+
+// Send transmits a file as speaker.
+// A file the partner refuses is returned as oftp2.StartFileError or oftp2.EndFileError and doesn't end the session.
+// A transfer that breaks off is returned as InterruptedError, if restart was agreed for the session.
+func (s *Session) Send(file VirtualFile) error {
+	if s.MustChangeDirection() {
+		return errors.New("partner asked to change direction")
+	} else if !s.negotiated.Send {
+		return errors.New("sending files wasn't agreed")
+	}
+	input := file.StartFile
+	if !s.negotiated.Restart {
+		input.RestartPosition = 0
+	}
+	var digest hash.Hash
+	if input.SignedReceipt {
+		var err error
+		if digest, err = fileservices.NewDigest(input.Cipher); err != nil {
+			return fmt.Errorf("signed receipts need a cipher suite: %w", err)
+		}
+	}
+	reader, release, err := s.open(&input, file, digest)
+	if err != nil {
+		return err
+	}
+	defer release()
+	sfid, err := oftp2.NewStartFile(input)
+	if err != nil {
+		return err
+	}
+	if err := s.conn.Send(oftp2.StartFileCmd(sfid)); err != nil {
+		return err
+	}
+	s.changedDirection = false
+	msg, err := s.conn.Receive()
+	if err != nil {
+		return s.conn.Abort(err)
+	}
+	switch m := msg.(type) {
+	case oftp2.StartFilePositiveAnswerCmd:
+		if err := restartAt(reader, input.RestartPosition, int64(m.AnswerCount())); err != nil {
+			return s.conn.Abort(err)
+		}
+	case oftp2.StartFileNegativeAnswerCmd:
+		refusal := oftp2.NewStartFileError(m.ReasonCode(), fmt.Errorf("file refused by partner with reason %d: %s", m.ReasonCode(), m.ReasonText()))
+		refusal.Retry = m.Retry()
+		return refusal
+	default:
+		return refusedByPartner(msg)
+	}
+	records := record.HasRecords(input.Format) && servicesOf(input).None()
+	if err := s.sendData(reader, records); err != nil {
+		err = s.conn.Abort(err)
+		if s.negotiated.Restart {
+			return InterruptedError{Position: reader.Position(), Err: err}
+		}
+		return err
+	}
+	if err := s.sendEndFile(reader); err != nil {
+		return err
+	}
+	if digest != nil {
+		s.sent = append(s.sent, sentFile{startFile: oftp2.StartFileCmd(sfid), digest: digest.Sum(nil)})
+	}
+	return nil
+}
+
+// open reads the virtual file as it is transmitted. Sizes are taken from the content when they are left empty.
+// Files with file services are transmitted as unstructured data, whose sizes are always filled in.
+// Files that are only compressed are streamed through a temporary file, other services are applied in memory.
+// The file as it is transmitted, after signing, compression and encryption, is passed to digest, if it is set.
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.13
+// The returned function releases what the transmission needed.
+func (s *Session) open(input *oftp2.StartFileInput, file VirtualFile, digest io.Writer) (*record.Reader, func(), error) {
+	config := record.Config{
+		Format:        input.Format,
+		MaxRecordSize: input.MaxRecordSize,
+		LocalForm:     file.LocalForm,
+	}
+	services := servicesOf(*input)
+	if services.None() {
+		if input.TransmittedSize == 0 && input.OriginalSize == 0 {
+			input.TransmittedSize = blocks(file.Content)
+			input.OriginalSize = input.TransmittedSize
+		}
+		reader, err := record.NewReader(file.Content, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		if digest != nil {
+			reader.Digest(digest)
+		}
+		return reader, func() {}, nil
+	}
+	if record.HasRecords(input.Format) {
+		return nil, nil, fmt.Errorf("file services need an unstructured or text file, but got %v", string(input.Format))
+	}
+	reader, err := record.NewReader(file.Content, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	input.RestartPosition = 0
+	virtual := &virtualContent{reader: reader}
+	if services.Streamed() {
+		transmitted, release, err := compress(input, virtual)
+		if err == nil && digest != nil {
+			transmitted.Digest(digest)
+		}
+		return transmitted, release, err
+	}
+	content, err := io.ReadAll(virtual)
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := services.Wrap(content, s.keys())
+	if err != nil {
+		return nil, nil, err
+	}
+	input.OriginalSize = kilobytes(int64(len(content)))
+	input.TransmittedSize = kilobytes(int64(len(wrapped)))
+	reader, err = record.NewReader(bytes.NewReader(wrapped), record.Config{Format: oftp2.FileFormatUnstructured})
+	if err != nil {
+		return nil, nil, err
+	}
+	if digest != nil {
+		reader.Digest(digest)
+	}
+	return reader, func() {}, nil
+}
+
+// compress writes the compressed file to a temporary file first, so that its size is known before it is announced.
+func compress(input *oftp2.StartFileInput, virtual *virtualContent) (*record.Reader, func(), error) {
+	compressed, err := os.CreateTemp("", "oftp2-*.zlib")
+	if err != nil {
+		return nil, nil, err
+	}
+	remove := func() {
+		_ = compressed.Close()
+		_ = os.Remove(compressed.Name())
+	}
+	if err := fileservices.Compress(compressed, virtual); err != nil {
+		remove()
+		return nil, nil, err
+	}
+	size, err := compressed.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = compressed.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		remove()
+		return nil, nil, err
+	}
+	input.OriginalSize = kilobytes(virtual.size)
+	input.TransmittedSize = kilobytes(size)
+	reader, err := record.NewReader(compressed, record.Config{Format: oftp2.FileFormatUnstructured})
+	if err != nil {
+		remove()
+		return nil, nil, err
+	}
+	return reader, remove, nil
+}
+
+// virtualContent reads the virtual form of a file as octets.
+type virtualContent struct {
+	reader *record.Reader
+	rest   []byte
+	// size counts the octets read so far
+	size int64
+}
+
+func (v *virtualContent) Read(p []byte) (int, error) {
+	for len(v.rest) == 0 {
+		next, err := v.reader.Next()
+		if err != nil {
+			return 0, err
+		}
+		v.rest = next
+	}
+	n := copy(p, v.rest)
+	v.rest = v.rest[n:]
+	v.size += int64(n)
+	return n, nil
+}
+
+func servicesOf(input oftp2.StartFileInput) fileservices.Services {
+	return fileservices.Services{
+		Security:    input.Security,
+		Cipher:      input.Cipher,
+		Compression: input.Compression,
+		Envelope:    input.Envelope,
+	}
+}
+
+// keys are the keys for the file services of files exchanged with the partner, as far as they are known.
+func (s *Session) keys() fileservices.Keys {
+	var keys fileservices.Keys
+	if s.config.KeyStore == nil {
+		return keys
+	}
+	keys.Key, keys.Certificate, _ = s.config.KeyStore.Key()
+	keys.Partner, _ = s.config.KeyStore.Certificate(s.remote.IdentificationCode())
+	return keys
+}
+
+// restartAt skips what the partner already received.
+// The answer count of SFPA must not exceed the restart position of SFID.
+func restartAt(reader *record.Reader, requested int64, answered int64) error {
+	if answered > requested {
+		return oftp2.NewEndSessionError(oftp2.EndSessionCommandContainedInvalidData, fmt.Errorf("answer count %d exceeds the restart position %d", answered, requested))
+	}
+	if err := reader.Skip(answered); err != nil {
+		return oftp2.NewEndSessionError(oftp2.EndSessionResourcesNotAvailable, err)
+	}
+	return nil
+}
+
+func (s *Session) sendData(reader *record.Reader, records bool) error {
+	encoder, err := oftp2.NewSubrecordEncoder(s.negotiated.DataExchangeBufferSize, s.negotiated.BufferCompression)
+	if err != nil {
+		return err
+	}
+	window, err := oftp2.NewCreditWindow(s.negotiated.Credit)
+	if err != nil {
+		return err
+	}
+	for {
+		next, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return oftp2.NewEndSessionError(oftp2.EndSessionResourcesNotAvailable, err)
+		}
+		var buffers []oftp2.Command
+		if records {
+			buffers = encoder.WriteRecord(next)
+		} else {
+			buffers = encoder.Write(next)
+		}
+		if err := s.sendBuffers(window, buffers); err != nil {
+			return err
+		}
+	}
+	if last := encoder.Flush(); last != nil {
+		if err := s.sendBuffers(window, []oftp2.Command{last}); err != nil {
+			return err
+		}
+	}
+	// the listener grants new credit after the last buffer of the window, even at the end of the file
+	if window.Exhausted() {
+		return s.awaitCredit(window)
+	}
+	return nil
+}
+
+// sendBuffers sends DATA within the credit window and waits for CDT once it is exhausted.
+func (s *Session) sendBuffers(window *oftp2.CreditWindow, buffers []oftp2.Command) error {
+	for _, buffer := range buffers {
+		if window.Exhausted() {
+			if err := s.awaitCredit(window); err != nil {
+				return err
+			}
+		}
+		if err := window.Consume(); err != nil {
+			return err
+		}
+		if err := s.conn.Send(oftp2.DataExchangeBuffer(buffer)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Session) awaitCredit(window *oftp2.CreditWindow) error {
+	msg, err := s.conn.Receive()
+	if err != nil {
+		return err
+	}
+	if _, ok := msg.(oftp2.SetCreditCmd); !ok {
+		return refusedByPartner(msg)
+	}
+	window.Reset()
+	return nil
+}
+
+func (s *Session) sendEndFile(reader *record.Reader) error {
+	efid, err := oftp2.NewEndFile(oftp2.EndFileInput{
+		RecordCount: reader.RecordCount(),
+		UnitCount:   reader.UnitCount(),
+	})
+	if err != nil {
+		return s.conn.Abort(oftp2.NewEndSessionError(oftp2.EndSessionUnspecified, err))
+	}
+	if err := s.conn.Send(oftp2.EndFileCmd(efid)); err != nil {
+		return err
+	}
+	msg, err := s.conn.Receive()
+	if err != nil {
+		return s.conn.Abort(err)
+	}
+	switch m := msg.(type) {
+	case oftp2.EndFilePositiveAnswerCmd:
+		return nil
+	case oftp2.EndFileNegativeAnswerCmd:
+		return oftp2.NewEndFileError(m.ReasonCode(), fmt.Errorf("file refused by partner with reason %d: %s", m.ReasonCode(), m.ReasonText()))
+	default:
+		return refusedByPartner(msg)
+	}
+}
+
+// blocks is the size of content in 1K blocks, if it can be told without reading it.
+func blocks(content io.Reader) int64 {
+	var size int64
+	switch c := content.(type) {
+	case interface{ Len() int }:
+		size = int64(c.Len())
+	case interface{ Stat() (os.FileInfo, error) }:
+		info, err := c.Stat()
+		if err != nil {
+			return 0
+		}
+		size = info.Size()
+	}
+	return kilobytes(size)
+}
+
+// kilobytes is a size in 1K blocks.
+func kilobytes(size int64) int64 {
+	return (size + 1023) / 1024
+}
@@ -0,0 +1,473 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/elgohr/go-oftp2/cms"
+	"github.com/elgohr/go-oftp2/fileservices"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/storage"
+	"io"
+)
+
+// Config describes the local station.
+type Config struct {
+	IdentificationCode     oftp2.IdentificationCode
+	Password               string
+	DataExchangeBufferSize int
+	Credit                 int
+	BufferCompression      bool
+	// Restart offers to restart interrupted transfers, which keeps interrupted files in the Store.
+	Restart bool
+	// SecureAuthentication proves the identities of both sides with AUCH and AURP. The partner must ask for it as well.
+	SecureAuthentication bool
+	// KeyStore provides the own key and the partner certificates for secure authentication.
+	KeyStore cms.KeyStore
+	// AuthenticationCipher is the cipher suite of the challenges, which defaults to oftp2.CipherAes256Cbc.
+	AuthenticationCipher oftp2.Cipher
+	// Authenticate checks the SSID of the partner.
+	// An oftp2.EndSessionError refuses the session with its reason.
+	Authenticate func(ssid oftp2.StartSessionCmd) error
+	// Store keeps the files received from the partner.
+	Store storage.Storage
+	// Capabilities restricts the direction of files, which defaults to oftp2.CapabilityBoth.
+	Capabilities oftp2.SsidCapability
+	// Profile chooses the config for the partner as responder, e.g. from a partner registry.
+	// It is called with the SSID of the partner before Authenticate.
+	// An oftp2.EndSessionError refuses the session with its reason.
+	Profile func(remote oftp2.StartSessionCmd) (Config, error)
+	// Receipt is called with every end to end response of the partner, that passed the check of its signature,
+	// e.g. to acknowledge the files of a queue. An error ends the session before the response is confirmed by RTR,
+	// so that the partner sends it again.
+	Receipt func(msg oftp2.Message) error
+	// InvalidReceipt is called with every EERP of the partner, that failed the check of its signature or hash,
+	// e.g. to fail the files of a queue. An error ends the session like one of Receipt.
+	InvalidReceipt func(invalid ReceiptError) error
+	// SentDigest looks up the hash of a file sent in an earlier session, which its signed EERP is checked against.
+	SentDigest func(eerp oftp2.EndToEndResponseCmd) []byte
+}
+
+// Session is an established OFTP2 session.
+type Session struct {
+	conn   *Conn
+	config Config
+	local  oftp2.StartSessionCmd
+	remote oftp2.StartSessionCmd
+	// negotiated is what both SSIDs agreed on
+	negotiated oftp2.NegotiatedSession
+	// responses are the EERPs and NERPs to send once this station becomes speaker
+	responses []oftp2.Message
+	// changedDirection is set when this station became speaker by CD and didn't send anything since
+	changedDirection bool
+	// queue are the files to send once this station becomes speaker
+	queue []VirtualFile
+	// received are the files received since the last change of direction
+	received []oftp2.StartFileCmd
+	// receipts are the EERPs and NERPs received from the partner
+	receipts []oftp2.Message
+	// invalidReceipts are the EERPs received from the partner, whose signature or hash didn't match
+	invalidReceipts []ReceiptError
+	// refusals are the files sent by Run, which the partner refused
+	refusals []RefusalError
+	// sent are the files sent in this session, that asked for a signed receipt
+	sent []sentFile
+}
+
+// sentFile keeps the hash of a sent virtual file to check its signed receipt.
+type sentFile struct {
+	startFile oftp2.StartFileCmd
+	digest    []byte
+}
+
+// Initiate runs the start session phase as initiator.
+func Initiate(connection io.ReadWriter, config Config) (*Session, error) {
+	conn := NewConn(connection, Initiator)
+	msg, err := conn.Receive()
+	if err != nil {
+		return nil, conn.Abort(err)
+	}
+	if _, ok := msg.(oftp2.StartSessionReadyMessageCmd); !ok {
+		return nil, refusedByPartner(msg)
+	}
+	local, err := offer(config)
+	if err != nil {
+		return nil, conn.Abort(oftp2.NewEndSessionError(oftp2.EndSessionUnspecified, err))
+	}
+	if err := conn.Send(local); err != nil {
+		return nil, err
+	}
+	msg, err = conn.Receive()
+	if err != nil {
+		return nil, conn.Abort(err)
+	}
+	remote, ok := msg.(oftp2.StartSessionCmd)
+	if !ok {
+		return nil, refusedByPartner(msg)
+	}
+	negotiated, err := oftp2.Negotiate(local, remote)
+	if err != nil {
+		return nil, conn.Abort(err)
+	}
+	if config.Authenticate != nil {
+		if err := config.Authenticate(remote); err != nil {
+			return nil, conn.Abort(err)
+		}
+	}
+	if err := authenticate(conn, config, remote); err != nil {
+		return nil, conn.Abort(err)
+	}
+	return &Session{
+		conn:       conn,
+		config:     config,
+		local:      local,
+		remote:     remote,
+		negotiated: negotiated,
+	}, nil
+}
+
+// Accept runs the start session phase as responder.
+func Accept(connection io.ReadWriter, config Config) (*Session, error) {
+	conn := NewConn(connection, Responder)
+	if err := conn.Send(oftp2.StartSessionReadyMessageCmd(oftp2.NewStartSessionReadyMessage())); err != nil {
+		return nil, err
+	}
+	msg, err := conn.Receive()
+	if err != nil {
+		return nil, conn.Abort(err)
+	}
+	remote, ok := msg.(oftp2.StartSessionCmd)
+	if !ok {
+		return nil, refusedByPartner(msg)
+	}
+	if config.Profile != nil {
+		profile, err := config.Profile(remote)
+		if err != nil {
+			return nil, conn.Abort(err)
+		}
+		config = profile
+	}
+	if config.Authenticate != nil {
+		if err := config.Authenticate(remote); err != nil {
+			return nil, conn.Abort(err)
+		}
+	}
+	local, err := offer(config)
+	if err != nil {
+		return nil, conn.Abort(oftp2.NewEndSessionError(oftp2.EndSessionUnspecified, err))
+	}
+	negotiated, err := oftp2.Negotiate(local, remote)
+	if err != nil {
+		return nil, conn.Abort(err)
+	}
+	if err := agreeAuthentication(config); err != nil {
+		return nil, conn.Abort(err)
+	}
+	if local, err = answer(config, negotiated); err != nil {
+		return nil, conn.Abort(oftp2.NewEndSessionError(oftp2.EndSessionUnspecified, err))
+	}
+	if err := conn.Send(local); err != nil {
+		return nil, err
+	}
+	if err := authenticate(conn, config, remote); err != nil {
+		return nil, conn.Abort(err)
+	}
+	return &Session{
+		conn:       conn,
+		config:     config,
+		local:      local,
+		remote:     remote,
+		negotiated: negotiated,
+	}, nil
+}
+
+// offer is the SSID of this station as configured.
+func offer(config Config) (oftp2.StartSessionCmd, error) {
+	ssid, err := oftp2.NewStartSession(oftp2.StartSessionInput{
+		IdentificationCode:     config.IdentificationCode,
+		Password:               config.Password,
+		DataExchangeBufferSize: config.DataExchangeBufferSize,
+		Capabilities:           capabilitiesOf(config),
+		BufferCompression:      config.BufferCompression,
+		Restart:                config.Restart,
+		SecureAuthentication:   config.SecureAuthentication,
+		Credit:                 config.Credit,
+	})
+	return oftp2.StartSessionCmd(ssid), err
+}
+
+// answer is the SSID of the responder, which carries what was negotiated with the offer of the initiator.
+func answer(config Config, negotiated oftp2.NegotiatedSession) (oftp2.StartSessionCmd, error) {
+	ssid, err := oftp2.NewStartSession(oftp2.StartSessionInput{
+		IdentificationCode:     config.IdentificationCode,
+		Password:               config.Password,
+		DataExchangeBufferSize: negotiated.DataExchangeBufferSize,
+		Capabilities:           negotiated.Capabilities(),
+		BufferCompression:      negotiated.BufferCompression,
+		Restart:                negotiated.Restart,
+		SpecialLogic:           negotiated.SpecialLogic,
+		SecureAuthentication:   negotiated.SecureAuthentication,
+		Credit:                 negotiated.Credit,
+	})
+	return oftp2.StartSessionCmd(ssid), err
+}
+
+func capabilitiesOf(config Config) oftp2.SsidCapability {
+	if config.Capabilities == "" {
+		return oftp2.CapabilityBoth
+	}
+	return config.Capabilities
+}
+
+// Run exchanges files until the session ends.
+// As speaker, queued responses are sent first. Without anything left to send, the speaker hands over with CD,
+// unless it just became speaker by CD itself, which ends the session.
+// Queued files the partner refuses don't end the session, they are kept in Refusals.
+func (s *Session) Run() error {
+	for !s.conn.Machine().Ended() {
+		var err error
+		if s.conn.Machine().Role() == Speaker {
+			err = s.speak()
+		} else {
+			err = s.listen()
+		}
+		if err != nil {
+			return s.conn.Abort(err)
+		}
+	}
+	return nil
+}
+
+// Receive hands over to the partner with CD and receives files until the partner hands back or ends the session.
+// It returns the files that were received completely.
+func (s *Session) Receive() ([]oftp2.StartFileCmd, error) {
+	s.received = nil
+	if err := s.conn.Send(oftp2.ChangeDirectionCmd(oftp2.NewChangeDirection())); err != nil {
+		return nil, err
+	}
+	for !s.conn.Machine().Ended() && s.conn.Machine().Role() == Listener {
+		if err := s.listen(); err != nil {
+			return s.received, s.conn.Abort(err)
+		}
+	}
+	return s.received, nil
+}
+
+// Queue adds files that Run sends once this station is speaker.
+func (s *Session) Queue(files ...VirtualFile) {
+	s.queue = append(s.queue, files...)
+}
+
+// End sends the pending end to end responses and ends the session normally, unless it already ended.
+func (s *Session) End() error {
+	for len(s.responses) > 0 && !s.conn.Machine().Ended() {
+		if err := s.sendResponse(); err != nil {
+			return s.conn.Abort(err)
+		}
+	}
+	if s.conn.Machine().Ended() {
+		return nil
+	}
+	esid, err := oftp2.NewEndSession(oftp2.EndSessionInput{Reason: oftp2.EndSessionNormalTermination})
+	if err != nil {
+		return err
+	}
+	return s.conn.Send(oftp2.EndSessionCmd(esid))
+}
+
+// MustChangeDirection tells whether the partner asked to become speaker by EFPA.
+// Files can't be sent, until Receive handed over to the partner.
+func (s *Session) MustChangeDirection() bool {
+	return s.conn.Machine().State() == StateMustChangeDirection
+}
+
+// Ended tells whether either side ended the session.
+func (s *Session) Ended() bool {
+	return s.conn.Machine().Ended()
+}
+
+// Receipts are the end to end responses received from the partner, as EndToEndResponseCmd or NegativeEndResponseCmd.
+func (s *Session) Receipts() []oftp2.Message {
+	return s.receipts
+}
+
+// InvalidReceipts are the end to end responses received from the partner, that failed the check of their signature.
+func (s *Session) InvalidReceipts() []ReceiptError {
+	return s.invalidReceipts
+}
+
+// Refusals are the files sent by Run, which the partner refused with SFNA or EFNA.
+func (s *Session) Refusals() []RefusalError {
+	return s.refusals
+}
+
+// Digest is the hash of a file sent in this session as it was transmitted, if the file asked for a signed receipt.
+func (s *Session) Digest(sfid oftp2.StartFileCmd) []byte {
+	for _, sent := range s.sent {
+		if sameFile(sent.startFile, sfid) {
+			return sent.digest
+		}
+	}
+	return nil
+}
+
+// verifyReceipt checks the signature of a signed receipt with the certificate of the partner.
+// The receipt of a file, that asked for a signed receipt in this session or in an earlier one known to SentDigest,
+// must be signed and match the hash of the sent file.
+func (s *Session) verifyReceipt(eerp oftp2.EndToEndResponseCmd) error {
+	var digest []byte
+	for _, sent := range s.sent {
+		if eerp.Matches(sent.startFile) {
+			digest = sent.digest
+		}
+	}
+	if digest == nil && s.config.SentDigest != nil {
+		digest = s.config.SentDigest(eerp)
+	}
+	if digest == nil && len(eerp.Signature()) == 0 {
+		return nil
+	}
+	return fileservices.VerifyReceipt(eerp, digest, s.keys().Partner)
+}
+
+// Remote is the SSID of the partner.
+func (s *Session) Remote() oftp2.StartSessionCmd {
+	return s.remote
+}
+
+// Negotiated is what both SSIDs agreed on.
+func (s *Session) Negotiated() oftp2.NegotiatedSession {
+	return s.negotiated
+}
+
+// Local is the SSID of this station.
+func (s *Session) Local() oftp2.StartSessionCmd {
+	return s.local
+}
+
+func (s *Session) speak() error {
+	if s.MustChangeDirection() {
+		return s.conn.Send(oftp2.ChangeDirectionCmd(oftp2.NewChangeDirection()))
+	}
+	if len(s.responses) > 0 {
+		s.changedDirection = false
+		return s.sendResponse()
+	}
+	if len(s.queue) > 0 {
+		file := s.queue[0]
+		s.queue = s.queue[1:]
+		return s.refusalsOnly(file, s.Send(file))
+	}
+	if s.changedDirection {
+		esid, err := oftp2.NewEndSession(oftp2.EndSessionInput{Reason: oftp2.EndSessionNormalTermination})
+		if err != nil {
+			return err
+		}
+		return s.conn.Send(oftp2.EndSessionCmd(esid))
+	}
+	return s.conn.Send(oftp2.ChangeDirectionCmd(oftp2.NewChangeDirection()))
+}
+
+func (s *Session) sendResponse() error {
+	if err := s.conn.Send(s.responses[0]); err != nil {
+		return err
+	}
+	msg, err := s.conn.Receive()
+	if err != nil {
+		return err
+	}
+	if _, ok := msg.(oftp2.ReadyToReceiveCmd); !ok {
+		return endedByPartner(msg)
+	}
+	s.responses = s.responses[1:]
+	return nil
+}
+
+func (s *Session) listen() error {
+	msg, err := s.conn.Receive()
+	if err != nil {
+		return err
+	}
+	switch m := msg.(type) {
+	case oftp2.StartFileCmd:
+		return s.receiveFile(m)
+	case oftp2.ChangeDirectionCmd:
+		s.changedDirection = true
+		return nil
+	case oftp2.EndToEndResponseCmd:
+		if err := s.verifyReceipt(m); err != nil {
+			return s.rejectReceipt(ReceiptError{Receipt: m, Err: err})
+		}
+		return s.acceptReceipt(m)
+	case oftp2.NegativeEndResponseCmd:
+		return s.acceptReceipt(m)
+	case oftp2.SecurityChangeDirectionCmd:
+		return oftp2.NewEndSessionError(oftp2.EndSessionSecureAuthenticationIncompatible, fmt.Errorf("secure authentication wasn't agreed"))
+	default:
+		return endedByPartner(msg)
+	}
+}
+
+// acceptReceipt keeps an end to end response of the partner and passes it to Receipt of the config, before it is confirmed.
+func (s *Session) acceptReceipt(msg oftp2.Message) error {
+	s.receipts = append(s.receipts, msg)
+	if s.config.Receipt != nil {
+		if err := s.config.Receipt(msg); err != nil {
+			return oftp2.NewEndSessionError(oftp2.EndSessionResourcesNotAvailable, err)
+		}
+	}
+	return s.conn.Send(oftp2.ReadyToReceiveCmd(oftp2.NewReadyToReceive()))
+}
+
+// rejectReceipt keeps an EERP of the partner, that failed its check, and passes it to InvalidReceipt of the config,
+// before it is confirmed.
+func (s *Session) rejectReceipt(invalid ReceiptError) error {
+	s.invalidReceipts = append(s.invalidReceipts, invalid)
+	if s.config.InvalidReceipt != nil {
+		if err := s.config.InvalidReceipt(invalid); err != nil {
+			return oftp2.NewEndSessionError(oftp2.EndSessionResourcesNotAvailable, err)
+		}
+	}
+	return s.conn.Send(oftp2.ReadyToReceiveCmd(oftp2.NewReadyToReceive()))
+}
+
+// sameFile tells whether two SFIDs start the same virtual file.
+func sameFile(a, b oftp2.StartFileCmd) bool {
+	return a.Name() == b.Name() &&
+		a.Date().Equal(b.Date().Time) &&
+		bytes.Equal(a.UserData(), b.UserData()) &&
+		bytes.Equal(a.Origin(), b.Origin()) &&
+		bytes.Equal(a.Destination(), b.Destination())
+}
+
+// endedByPartner is the error for a session the partner ended with ESID.
+// A normal termination isn't an error.
+func endedByPartner(msg oftp2.Message) error {
+	esid, ok := msg.(oftp2.EndSessionCmd)
+	if !ok {
+		return fmt.Errorf("unhandled command: %v", msg.Id())
+	}
+	if esid.ReasonCode() == oftp2.EndSessionNormalTermination {
+		return nil
+	}
+	return fmt.Errorf("session ended by partner with reason %d: %s", esid.ReasonCode(), esid.ReasonText())
+}
+
+// refusedByPartner is the error for a session the partner ended before it was started.
+func refusedByPartner(msg oftp2.Message) error {
+	if err := endedByPartner(msg); err != nil {
+		return err
+	}
+	return errors.New("session ended by partner")
+}
+
+// refusalsOnly keeps errors of files the partner refused in Refusals, as they don't end the session.
+func (s *Session) refusalsOnly(file VirtualFile, err error) error {
+	var startFileErr oftp2.StartFileError
+	var endFileErr oftp2.EndFileError
+	if errors.As(err, &startFileErr) || errors.As(err, &endFileErr) {
+		s.refusals = append(s.refusals, RefusalError{StartFile: file.StartFile, Err: err})
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/record"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Filesystem keeps virtual files in a directory, named by their virtual file name and the key of their identity,
+// so that files of the same name from different partners or dates don't collide.
+// Files are written to hidden part files, which are linked to their final name once they are committed,
+// so that a crash never leaves a partial file under the final name and an existing file is never overwritten.
+// Part files keep a checkpoint, which is updated while they are written, until the partner restarts them.
+// A part file is written by one session at a time, so that concurrent sessions don't clobber each other.
+type Filesystem struct {
+	directory string
+	mutex     sync.Mutex
+	// writing are the keys of the identities, whose part files are being written
+	writing map[string]bool
+}
+
+func NewFilesystem(directory string) (*Filesystem, error) {
+	if err := os.MkdirAll(directory, 0o750); err != nil {
+		return nil, err
+	}
+	return &Filesystem{
+		directory: directory,
+		writing:   map[string]bool{},
+	}, nil
+}
+
+func (f *Filesystem) Create(id Identity) (Writer, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.lock(id); err != nil {
+		return nil, err
+	}
+	w, err := f.create(id, path)
+	if err != nil {
+		f.unlock(id)
+	}
+	return w, err
+}
+
+func (f *Filesystem) create(id Identity, path string) (Writer, error) {
+	part := f.part(id)
+	_ = os.Remove(part + checkpointSuffix)
+	file, err := os.OpenFile(part, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, oftp2.NewStartFileError(oftp2.AnswerAccessMethodFailure, err)
+	}
+	return &fileWriter{
+		File:       file,
+		filesystem: f,
+		id:         id,
+		path:       path,
+	}, nil
+}
+
+// Resume continues the part file of an interrupted file at its checkpoint.
+// Without a usable checkpoint the file starts from scratch.
+func (f *Filesystem) Resume(id Identity) (Writer, record.Checkpoint, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return nil, record.Checkpoint{}, err
+	}
+	if err := f.lock(id); err != nil {
+		return nil, record.Checkpoint{}, err
+	}
+	w, checkpoint, err := f.resume(id, path)
+	if err != nil {
+		f.unlock(id)
+	}
+	return w, checkpoint, err
+}
+
+func (f *Filesystem) resume(id Identity, path string) (Writer, record.Checkpoint, error) {
+	part := f.part(id)
+	checkpoint, err := readCheckpoint(part + checkpointSuffix)
+	if err != nil {
+		w, err := f.create(id, path)
+		return w, record.Checkpoint{}, err
+	}
+	file, err := os.OpenFile(part, os.O_WRONLY, 0o600)
+	if err != nil {
+		w, err := f.create(id, path)
+		return w, record.Checkpoint{}, err
+	}
+	if err := file.Truncate(checkpoint.Offset); err != nil {
+		_ = file.Close()
+		return nil, record.Checkpoint{}, oftp2.NewStartFileError(oftp2.AnswerAccessMethodFailure, err)
+	}
+	if _, err := file.Seek(checkpoint.Offset, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, record.Checkpoint{}, oftp2.NewStartFileError(oftp2.AnswerAccessMethodFailure, err)
+	}
+	return &fileWriter{
+		File:       file,
+		filesystem: f,
+		id:         id,
+		path:       path,
+	}, checkpoint, nil
+}
+
+func (f *Filesystem) Open(id Identity) (io.ReadCloser, error) {
+	if err := validName(id.Name); err != nil {
+		return nil, err
+	}
+	return os.Open(f.committed(id))
+}
+
+func (f *Filesystem) Remove(id Identity) error {
+	if err := validName(id.Name); err != nil {
+		return err
+	}
+	return os.Remove(f.committed(id))
+}
+
+// path is where a file is kept once it is committed.
+func (f *Filesystem) path(id Identity) (string, error) {
+	if err := validName(id.Name); err != nil {
+		return "", err
+	}
+	path := f.committed(id)
+	if _, err := os.Stat(path); err == nil {
+		return "", duplicate(id)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", oftp2.NewStartFileError(oftp2.AnswerAccessMethodFailure, err)
+	}
+	return path, nil
+}
+
+// lock refuses a file, whose part file is written by another session.
+func (f *Filesystem) lock(id Identity) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.writing[id.key()] {
+		return busy(id)
+	}
+	f.writing[id.key()] = true
+	return nil
+}
+
+func (f *Filesystem) unlock(id Identity) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.writing, id.key())
+}
+
+// committed is the name of a committed file.
+func (f *Filesystem) committed(id Identity) string {
+	return filepath.Join(f.directory, id.Name+"."+id.key())
+}
+
+// part is the hidden file a file is written to.
+// It is named by the identity of the virtual file, so that a restart finds it again.
+func (f *Filesystem) part(id Identity) string {
+	return filepath.Join(f.directory, "."+id.Name+"."+id.key()+".part")
+}
+
+const checkpointSuffix = ".checkpoint"
+
+func readCheckpoint(path string) (record.Checkpoint, error) {
+	var checkpoint record.Checkpoint
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return checkpoint, err
+	}
+	err = json.Unmarshal(content, &checkpoint)
+	return checkpoint, err
+}
+
+// writeCheckpoint replaces the checkpoint of a part file at once, so that a crash keeps the previous one.
+func writeCheckpoint(path string, checkpoint record.Checkpoint) error {
+	content, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
+
+// fileWriter writes to a hidden part file, which is linked to its final name once the file is committed.
+// It holds the lock of its identity, until it is committed, suspended or aborted.
+// A failed commit or suspension keeps the lock, as the part file is still aborted afterwards.
+type fileWriter struct {
+	*os.File
+	filesystem *Filesystem
+	id         Identity
+	path       string
+	released   bool
+}
+
+// release passes the identity on to the next session once.
+func (w *fileWriter) release() {
+	if !w.released {
+		w.released = true
+		w.filesystem.unlock(w.id)
+	}
+}
+
+// Commit syncs the part file before it is linked, so that the committed file is complete after a crash.
+// Linking fails on an existing file, which was committed in the meantime, so that it is never overwritten.
+func (w *fileWriter) Commit() error {
+	if err := w.File.Sync(); err != nil {
+		_ = w.File.Close()
+		return err
+	}
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+	if err := os.Link(w.File.Name(), w.path); errors.Is(err, os.ErrExist) {
+		return duplicate(w.id)
+	} else if err != nil {
+		return err
+	}
+	if err := os.Remove(w.File.Name()); err != nil {
+		return err
+	}
+	_ = os.Remove(w.File.Name() + checkpointSuffix)
+	syncDirectory(filepath.Dir(w.path))
+	w.release()
+	return nil
+}
+
+func (w *fileWriter) Abort() error {
+	defer w.release()
+	_ = w.File.Close()
+	_ = os.Remove(w.File.Name() + checkpointSuffix)
+	return os.Remove(w.File.Name())
+}
+
+// Checkpoint syncs the part file before its checkpoint is written, so that the checkpoint never points beyond its content.
+func (w *fileWriter) Checkpoint(checkpoint record.Checkpoint) error {
+	if err := w.File.Sync(); err != nil {
+		return err
+	}
+	return writeCheckpoint(w.File.Name()+checkpointSuffix, checkpoint)
+}
+
+// Suspend keeps the part file with its checkpoint for a restart.
+func (w *fileWriter) Suspend(checkpoint record.Checkpoint) error {
+	if err := w.Checkpoint(checkpoint); err != nil {
+		_ = w.File.Close()
+		return err
+	}
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+	w.release()
+	return nil
+}
+
+// syncDirectory persists a link, where the platform supports syncing directories.
+func syncDirectory(directory string) {
+	d, err := os.Open(directory)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
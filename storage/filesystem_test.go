@@ -0,0 +1,311 @@
+package storage_test
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/record"
+	"github.com/elgohr/go-oftp2/storage"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFilesystem(t *testing.T) {
+	directory := t.TempDir()
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+
+	file, err := store.Create(identity("STORED"))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("CONTENT"))
+	require.NoError(t, err)
+	_, err = store.Open(identity("STORED"))
+	require.True(t, errors.Is(err, os.ErrNotExist))
+
+	require.NoError(t, file.Commit())
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.True(t, strings.HasPrefix(entries[0].Name(), "STORED."))
+	require.Equal(t, "CONTENT", stored(t, store, "STORED"))
+
+	require.NoError(t, store.Remove(identity("STORED")))
+	_, err = store.Open(identity("STORED"))
+	require.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestFilesystem_Abort(t *testing.T) {
+	directory := t.TempDir()
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+
+	file, err := store.Create(identity("ABORTED"))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("CONTENT"))
+	require.NoError(t, err)
+	require.NoError(t, file.Abort())
+
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestFilesystem_Resume(t *testing.T) {
+	directory := t.TempDir()
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+
+	file, err := store.Create(identity("RESUMED"))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("AAAA"))
+	require.NoError(t, err)
+	require.NoError(t, file.Suspend(record.Checkpoint{Position: 2, Units: 2, Offset: 2}))
+
+	file, checkpoint, err := store.Resume(identity("RESUMED"))
+	require.NoError(t, err)
+	require.Equal(t, record.Checkpoint{Position: 2, Units: 2, Offset: 2}, checkpoint)
+	_, err = file.Write([]byte("BB"))
+	require.NoError(t, err)
+	require.NoError(t, file.Commit())
+
+	require.Equal(t, "AABB", stored(t, store, "RESUMED"))
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestFilesystem_ResumeAfterCrash(t *testing.T) {
+	directory := t.TempDir()
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+
+	file, err := store.Create(identity("CRASHED"))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("AA"))
+	require.NoError(t, err)
+	require.NoError(t, file.Checkpoint(record.Checkpoint{Position: 2, Units: 2, Offset: 2}))
+	_, err = file.Write([]byte("AA"))
+	require.NoError(t, err)
+
+	// the process ends without suspending the file
+	store, err = storage.NewFilesystem(directory)
+	require.NoError(t, err)
+	file, checkpoint, err := store.Resume(identity("CRASHED"))
+	require.NoError(t, err)
+	require.Equal(t, record.Checkpoint{Position: 2, Units: 2, Offset: 2}, checkpoint)
+	_, err = file.Write([]byte("BB"))
+	require.NoError(t, err)
+	require.NoError(t, file.Commit())
+	require.Equal(t, "AABB", stored(t, store, "CRASHED"))
+}
+
+func TestFilesystem_ResumeFromScratch(t *testing.T) {
+	directory := t.TempDir()
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+
+	file, err := store.Create(identity("RESTARTED"))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("AAAA"))
+	require.NoError(t, err)
+	require.NoError(t, file.Suspend(record.Checkpoint{Position: 4, Units: 4, Offset: 4}))
+
+	// another file of the same name doesn't continue the suspended one
+	other := identity("RESTARTED")
+	other.Date = other.Date.Add(time.Second)
+	file, checkpoint, err := store.Resume(other)
+	require.NoError(t, err)
+	require.Equal(t, record.Checkpoint{}, checkpoint)
+	_, err = file.Write([]byte("BB"))
+	require.NoError(t, err)
+	require.NoError(t, file.Commit())
+
+	r, err := store.Open(other)
+	require.NoError(t, err)
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "BB", string(content))
+}
+
+func TestFilesystem_Refused(t *testing.T) {
+	store, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	file, err := store.Create(identity("DUPLICATE"))
+	require.NoError(t, err)
+	require.NoError(t, file.Commit())
+	refused(t, store)
+}
+
+func TestFilesystem_SameName(t *testing.T) {
+	store, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	sameName(t, store)
+}
+
+func TestFilesystem_CommitDuplicate(t *testing.T) {
+	directory := t.TempDir()
+	store, err := storage.NewFilesystem(directory)
+	require.NoError(t, err)
+	file, err := store.Create(identity("COMMITTED"))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("FIRST"))
+	require.NoError(t, err)
+	require.NoError(t, file.Commit())
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	committed := filepath.Join(directory, entries[0].Name())
+
+	// the same file is committed by another session, while it is written
+	require.NoError(t, store.Remove(identity("COMMITTED")))
+	file, err = store.Create(identity("COMMITTED"))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("SECOND"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(committed, []byte("FIRST"), 0o600))
+
+	err = file.Commit()
+	require.EqualError(t, err, "duplicate file: COMMITTED")
+	var startFileErr oftp2.StartFileError
+	require.True(t, errors.As(err, &startFileErr))
+	require.Equal(t, oftp2.AnswerDuplicateFile, startFileErr.Reason)
+	require.Equal(t, "FIRST", stored(t, store, "COMMITTED"))
+}
+
+func TestFilesystem_Concurrent(t *testing.T) {
+	store, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	concurrent(t, store)
+}
+
+func refused(t *testing.T, store storage.Storage) {
+	t.Helper()
+	for _, scenario := range []struct {
+		name   string
+		reason oftp2.AnswerReason
+		error  string
+	}{
+		{
+			name:   "DUPLICATE",
+			reason: oftp2.AnswerDuplicateFile,
+			error:  "duplicate file: DUPLICATE",
+		},
+		{
+			name:   "..",
+			reason: oftp2.AnswerInvalidFilename,
+			error:  `invalid filename: ".."`,
+		},
+		{
+			name:   "DIR/FILE",
+			reason: oftp2.AnswerInvalidFilename,
+			error:  `invalid filename: "DIR/FILE"`,
+		},
+	} {
+		t.Run(scenario.name, func(t *testing.T) {
+			file, err := store.Create(identity(scenario.name))
+			require.EqualError(t, err, scenario.error)
+			require.Nil(t, file)
+			var startFileErr oftp2.StartFileError
+			require.True(t, errors.As(err, &startFileErr))
+			require.Equal(t, scenario.reason, startFileErr.Reason)
+		})
+	}
+}
+
+// sameName keeps files of the same name, which differ in their identity.
+func sameName(t *testing.T, store storage.Storage) {
+	t.Helper()
+	for _, originator := range []string{"O0177PARTNER", "O0177OTHER"} {
+		id := identity("SAME")
+		id.Originator = originator
+		file, err := store.Create(id)
+		require.NoError(t, err)
+		_, err = file.Write([]byte(originator))
+		require.NoError(t, err)
+		require.NoError(t, file.Commit())
+	}
+	for _, originator := range []string{"O0177PARTNER", "O0177OTHER"} {
+		id := identity("SAME")
+		id.Originator = originator
+		r, err := store.Open(id)
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, originator, string(content))
+	}
+}
+
+// concurrent refuses a file for a retry, while another session writes the same identity.
+func concurrent(t *testing.T, store storage.Storage) {
+	t.Helper()
+	const sessions = 10
+	writers := make(chan storage.Writer, sessions)
+	refusals := make(chan error, sessions)
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if file, err := store.Create(identity("CONCURRENT")); err != nil {
+				refusals <- err
+			} else {
+				writers <- file
+			}
+		}()
+	}
+	wg.Wait()
+	close(writers)
+	close(refusals)
+
+	require.Len(t, writers, 1)
+	for err := range refusals {
+		require.EqualError(t, err, "file is being received: CONCURRENT")
+		var startFileErr oftp2.StartFileError
+		require.True(t, errors.As(err, &startFileErr))
+		require.Equal(t, oftp2.AnswerAccessMethodFailure, startFileErr.Reason)
+		require.True(t, startFileErr.Retry)
+	}
+	file := <-writers
+	_, err := file.Write([]byte("FIRST"))
+	require.NoError(t, err)
+	_, _, err = store.Resume(identity("CONCURRENT"))
+	require.EqualError(t, err, "file is being received: CONCURRENT")
+
+	// the partner restarts the file, once the first session is interrupted
+	require.NoError(t, file.Suspend(record.Checkpoint{Units: 5, Offset: 5}))
+	file, checkpoint, err := store.Resume(identity("CONCURRENT"))
+	require.NoError(t, err)
+	require.Equal(t, int64(5), checkpoint.Offset)
+	_, err = store.Create(identity("CONCURRENT"))
+	require.EqualError(t, err, "file is being received: CONCURRENT")
+	_, err = file.Write([]byte("SECOND"))
+	require.NoError(t, err)
+	require.NoError(t, file.Commit())
+	require.Equal(t, "FIRSTSECOND", stored(t, store, "CONCURRENT"))
+}
+
+func identity(name string) storage.Identity {
+	return storage.Identity{
+		Name:        name,
+		Date:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Originator:  "O0177PARTNER",
+		Destination: "O0177SERVER",
+	}
+}
+
+func stored(t *testing.T, store storage.Storage, name string) string {
+	t.Helper()
+	r, err := store.Open(identity(name))
+	require.NoError(t, err)
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(content)
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"bytes"
+	"github.com/elgohr/go-oftp2/record"
+	"io"
+	"os"
+	"sync"
+)
+
+// Memory keeps virtual files in memory, e.g. for tests.
+// It is safe for concurrent use and refuses a file, whose part is written by another session, like Filesystem does.
+type Memory struct {
+	mutex sync.Mutex
+	// files are the committed files by the key of their identity
+	files map[string][]byte
+	// parts are the files being written by the key of their identity
+	parts map[string]*memoryPart
+}
+
+type memoryPart struct {
+	content    bytes.Buffer
+	checkpoint *record.Checkpoint
+	// writing is set, while a session writes the part
+	writing bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		files: map[string][]byte{},
+		parts: map[string]*memoryPart{},
+	}
+}
+
+func (m *Memory) Create(id Identity) (Writer, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if err := m.available(id); err != nil {
+		return nil, err
+	} else if err := m.idle(id); err != nil {
+		return nil, err
+	}
+	part := &memoryPart{writing: true}
+	m.parts[id.key()] = part
+	return &memoryWriter{memory: m, id: id, part: part}, nil
+}
+
+// Resume continues a suspended file at its checkpoint.
+// Without a checkpoint the file starts from scratch.
+func (m *Memory) Resume(id Identity) (Writer, record.Checkpoint, error) {
+	m.mutex.Lock()
+	part, known := m.parts[id.key()]
+	if !known || part.checkpoint == nil {
+		m.mutex.Unlock()
+		w, err := m.Create(id)
+		return w, record.Checkpoint{}, err
+	}
+	defer m.mutex.Unlock()
+	if err := m.available(id); err != nil {
+		return nil, record.Checkpoint{}, err
+	} else if err := m.idle(id); err != nil {
+		return nil, record.Checkpoint{}, err
+	}
+	checkpoint := *part.checkpoint
+	part.checkpoint = nil
+	part.writing = true
+	part.content.Truncate(int(checkpoint.Offset))
+	return &memoryWriter{memory: m, id: id, part: part}, checkpoint, nil
+}
+
+func (m *Memory) Open(id Identity) (io.ReadCloser, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	content, known := m.files[id.key()]
+	if !known {
+		return nil, &os.PathError{Op: "open", Path: id.Name, Err: os.ErrNotExist}
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (m *Memory) Remove(id Identity) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, known := m.files[id.key()]; !known {
+		return &os.PathError{Op: "remove", Path: id.Name, Err: os.ErrNotExist}
+	}
+	delete(m.files, id.key())
+	return nil
+}
+
+// available checks that a file can be written under id, like Filesystem does.
+func (m *Memory) available(id Identity) error {
+	if err := validName(id.Name); err != nil {
+		return err
+	} else if _, committed := m.files[id.key()]; committed {
+		return duplicate(id)
+	}
+	return nil
+}
+
+// idle refuses a file, whose part is written by another session.
+func (m *Memory) idle(id Identity) error {
+	if part, known := m.parts[id.key()]; known && part.writing {
+		return busy(id)
+	}
+	return nil
+}
+
+// memoryWriter writes a part of Memory, which is moved to the files once it is committed.
+type memoryWriter struct {
+	memory *Memory
+	id     Identity
+	part   *memoryPart
+}
+
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	w.memory.mutex.Lock()
+	defer w.memory.mutex.Unlock()
+	return w.part.content.Write(p)
+}
+
+func (w *memoryWriter) Commit() error {
+	w.memory.mutex.Lock()
+	defer w.memory.mutex.Unlock()
+	if err := w.memory.available(w.id); err != nil {
+		return err
+	}
+	w.memory.files[w.id.key()] = w.part.content.Bytes()
+	w.memory.drop(w.id, w.part)
+	return nil
+}
+
+func (w *memoryWriter) Abort() error {
+	w.memory.mutex.Lock()
+	defer w.memory.mutex.Unlock()
+	w.memory.drop(w.id, w.part)
+	return nil
+}
+
+// Checkpoint keeps the checkpoint of the part, which is still written.
+func (w *memoryWriter) Checkpoint(checkpoint record.Checkpoint) error {
+	w.memory.mutex.Lock()
+	defer w.memory.mutex.Unlock()
+	w.part.checkpoint = &checkpoint
+	return nil
+}
+
+func (w *memoryWriter) Suspend(checkpoint record.Checkpoint) error {
+	w.memory.mutex.Lock()
+	defer w.memory.mutex.Unlock()
+	w.part.checkpoint = &checkpoint
+	w.part.writing = false
+	return nil
+}
+
+// drop removes a part, unless it was replaced by a newer file of the same identity.
+func (m *Memory) drop(id Identity, part *memoryPart) {
+	if m.parts[id.key()] == part {
+		delete(m.parts, id.key())
+	}
+}
@@ -0,0 +1,74 @@
+package storage_test
+
+import (
+	"errors"
+	"github.com/elgohr/go-oftp2/record"
+	"github.com/elgohr/go-oftp2/storage"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	store := storage.NewMemory()
+
+	file, err := store.Create(identity("STORED"))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("CONTENT"))
+	require.NoError(t, err)
+	_, err = store.Open(identity("STORED"))
+	require.True(t, errors.Is(err, os.ErrNotExist))
+
+	require.NoError(t, file.Commit())
+	require.Equal(t, "CONTENT", stored(t, store, "STORED"))
+	require.NoError(t, store.Remove(identity("STORED")))
+	_, err = store.Open(identity("STORED"))
+	require.True(t, errors.Is(err, os.ErrNotExist))
+
+	aborted, err := store.Create(identity("ABORTED"))
+	require.NoError(t, err)
+	_, err = aborted.Write([]byte("CONTENT"))
+	require.NoError(t, err)
+	require.NoError(t, aborted.Abort())
+	_, err = store.Open(identity("ABORTED"))
+	require.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestMemory_Resume(t *testing.T) {
+	store := storage.NewMemory()
+
+	file, err := store.Create(identity("RESUMED"))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("AAAA"))
+	require.NoError(t, err)
+	require.NoError(t, file.Suspend(record.Checkpoint{Position: 2, Units: 2, Offset: 2}))
+
+	file, checkpoint, err := store.Resume(identity("RESUMED"))
+	require.NoError(t, err)
+	require.Equal(t, record.Checkpoint{Position: 2, Units: 2, Offset: 2}, checkpoint)
+	_, err = file.Write([]byte("BB"))
+	require.NoError(t, err)
+	require.NoError(t, file.Commit())
+	require.Equal(t, "AABB", stored(t, store, "RESUMED"))
+
+	file, checkpoint, err = store.Resume(identity("FRESH"))
+	require.NoError(t, err)
+	require.Equal(t, record.Checkpoint{}, checkpoint)
+	require.NoError(t, file.Abort())
+}
+
+func TestMemory_Refused(t *testing.T) {
+	store := storage.NewMemory()
+	file, err := store.Create(identity("DUPLICATE"))
+	require.NoError(t, err)
+	require.NoError(t, file.Commit())
+	refused(t, store)
+}
+
+func TestMemory_SameName(t *testing.T) {
+	sameName(t, storage.NewMemory())
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	concurrent(t, storage.NewMemory())
+}
@@ -0,0 +1,92 @@
+// Package storage keeps virtual files, so that sessions don't deal with local files themselves.
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/elgohr/go-oftp2/oftp2"
+	"github.com/elgohr/go-oftp2/record"
+	"io"
+	"strings"
+	"time"
+)
+
+// Storage keeps virtual files by their identity.
+// A committed file is never replaced, so that another file of the same identity is refused as duplicate.
+type Storage interface {
+	// Create writes a virtual file from scratch and drops an interrupted one of the same identity.
+	// An oftp2.StartFileError refuses the file with its reason, e.g. while another session writes the same identity.
+	Create(id Identity) (Writer, error)
+	// Resume continues an interrupted virtual file at its last checkpoint.
+	// Without an interrupted file it starts from scratch with a zero checkpoint.
+	Resume(id Identity) (Writer, record.Checkpoint, error)
+	// Open reads a committed virtual file.
+	Open(id Identity) (io.ReadCloser, error)
+	// Remove drops a committed virtual file.
+	Remove(id Identity) error
+}
+
+// Writer writes a virtual file, which isn't visible until it is committed.
+type Writer interface {
+	io.Writer
+	// Commit keeps the complete file, before EFPA confirms it to the partner.
+	Commit() error
+	// Abort drops the file, e.g. when it is refused by EFNA.
+	Abort() error
+	// Checkpoint persists the file up to the checkpoint while it is written,
+	// so that it can be resumed, even if the process ends without suspending it.
+	Checkpoint(checkpoint record.Checkpoint) error
+	// Suspend keeps the file up to the checkpoint, so that it can be resumed when the partner restarts it.
+	Suspend(checkpoint record.Checkpoint) error
+}
+
+// Identity identifies a virtual file, as in SFID and EERP.
+//
+// https://datatracker.ietf.org/doc/html/rfc5024#section-5.3.3
+type Identity struct {
+	Name        string
+	Date        time.Time
+	Originator  string
+	Destination string
+}
+
+// IdentityOf is the identity of the virtual file started by sfid.
+func IdentityOf(sfid oftp2.StartFileCmd) Identity {
+	return Identity{
+		Name:        sfid.Name(),
+		Date:        sfid.Date().Time,
+		Originator:  strings.TrimSpace(string(sfid.Origin())),
+		Destination: strings.TrimSpace(string(sfid.Destination())),
+	}
+}
+
+func (id Identity) String() string {
+	return fmt.Sprintf("%s from %s to %s at %s", id.Name, id.Originator, id.Destination, id.Date.Format("2006-01-02 15:04:05.0000"))
+}
+
+// key distinguishes virtual files of the same name.
+func (id Identity) key() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s", id.Name, id.Date.Format("20060102150405.0000"), id.Originator, id.Destination)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// duplicate refuses a file, whose identity was committed already.
+func duplicate(id Identity) error {
+	return oftp2.NewStartFileError(oftp2.AnswerDuplicateFile, fmt.Errorf("duplicate file: %v", id.Name))
+}
+
+// busy refuses a file, which is received by another session, so that the partner sends it again later.
+func busy(id Identity) error {
+	err := oftp2.NewStartFileError(oftp2.AnswerAccessMethodFailure, fmt.Errorf("file is being received: %v", id.Name))
+	err.Retry = true
+	return err
+}
+
+// validName checks that the name of a virtual file can be used as a local name.
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return oftp2.NewStartFileError(oftp2.AnswerInvalidFilename, fmt.Errorf("invalid filename: %q", name))
+	}
+	return nil
+}